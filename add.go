@@ -0,0 +1,185 @@
+package main
+
+import (
+    "cmp"
+    "fmt"
+    "os"
+    "path/filepath"
+    "strings"
+)
+
+// crossrefItemTypes maps Crossref work types to Zotero item types
+var crossrefItemTypes = map[string]string{
+    "journal-article":     "journalArticle",
+    "proceedings-article": "conferencePaper",
+    "book-chapter":        "bookSection",
+    "book":                "book",
+    "monograph":           "book",
+    "posted-content":      "preprint",
+    "report":              "report",
+    "dissertation":        "thesis",
+}
+
+// crossrefTypeFields maps Crossref values to the Zotero fields of each item
+// type, as the API rejects fields an item type does not have; the first
+// value set wins for fields listed more than once
+var crossrefTypeFields = map[string][][2]string{
+    "journalArticle": {
+        {"container", "publicationTitle"},
+        {"volume", "volume"},
+        {"issue", "issue"},
+        {"page", "pages"},
+        {"ISSN", "ISSN"},
+    },
+    "conferencePaper": {
+        {"container", "proceedingsTitle"},
+        {"volume", "volume"},
+        {"page", "pages"},
+        {"publisher", "publisher"},
+    },
+    "bookSection": {
+        {"container", "bookTitle"},
+        {"volume", "volume"},
+        {"page", "pages"},
+        {"publisher", "publisher"},
+    },
+    "book": {
+        {"volume", "volume"},
+        {"publisher", "publisher"},
+    },
+    "preprint": {
+        {"container", "repository"},
+        {"publisher", "repository"},
+    },
+    "report": {
+        {"page", "pages"},
+        {"publisher", "institution"},
+    },
+    "thesis": {
+        {"publisher", "university"},
+    },
+    "document": {
+        {"publisher", "publisher"},
+    },
+}
+
+// crossrefItemData converts a Crossref work into Zotero Web API item data
+func crossrefItemData(work *crossrefWork) map[string]interface{} {
+    itemType := cmp.Or(crossrefItemTypes[work.Type], "document")
+
+    creators := []map[string]string{}
+    for _, a := range work.Author {
+        if a.Family == "" {
+            creators = append(creators, map[string]string{"creatorType": "author", "name": a.Name})
+            continue
+        }
+        creators = append(creators, map[string]string{
+            "creatorType": "author",
+            "firstName":   a.Given,
+            "lastName":    a.Family,
+        })
+    }
+
+    data := map[string]interface{}{
+        "itemType": itemType,
+        "title":    strings.Join(work.Title, " "),
+        "creators": creators,
+        "DOI":      work.DOI,
+    }
+    if len(work.Issued.DateParts) > 0 {
+        var parts []string
+        for i, part := range work.Issued.DateParts[0] {
+            if i == 0 {
+                parts = append(parts, fmt.Sprintf("%04d", part))
+            } else {
+                parts = append(parts, fmt.Sprintf("%02d", part))
+            }
+        }
+        data["date"] = strings.Join(parts, "-")
+    }
+
+    values := map[string]string{
+        "publisher": work.Publisher,
+        "volume":    work.Volume,
+        "issue":     work.Issue,
+        "page":      work.Page,
+        "ISSN":      strings.Join(work.ISSN, ", "),
+    }
+    if len(work.ContainerTitle) > 0 {
+        values["container"] = work.ContainerTitle[0]
+    }
+    for _, mapping := range crossrefTypeFields[itemType] {
+        if _, set := data[mapping[1]]; !set && values[mapping[0]] != "" {
+            data[mapping[1]] = values[mapping[0]]
+        }
+    }
+    // empty fields are noise
+    for field, value := range data {
+        if value == "" {
+            delete(data, field)
+        }
+    }
+    return data
+}
+
+const addItemScript = `
+const item = new Zotero.Item(params.item.itemType);
+item.libraryID = params.libraryID || Zotero.Libraries.userLibraryID;
+item.fromJSON(params.item);
+await item.saveTx();
+await Zotero.Attachments.importFromFile({ file: params.path, parentItemID: item.id });
+return item.key;
+`
+
+// Add creates an item for a PDF from the Crossref metadata of its DOI,
+// found in the file unless given, and attaches the file to it. The item
+// is created through the running Zotero client, or through the Web API
+// when remote is set. It prints the new item's key.
+func (c *CLI) Add(path, doi string, remote, force bool) error {
+    path, err := filepath.Abs(path)
+    if err != nil {
+        return fmt.Errorf("resolving path: %w", err)
+    }
+    if _, err := os.Stat(path); err != nil {
+        return fmt.Errorf("reading pdf: %w", err)
+    }
+    if doi == "" {
+        if doi, err = findDOIInPDF(path); err != nil {
+            return err
+        }
+        if doi == "" {
+            return fmt.Errorf("no DOI found in %s, pass one with -doi", path)
+        }
+    }
+    doi = normalizeDOI(doi)
+
+    index, err := c.repo.DOIIndex()
+    if err != nil {
+        return err
+    }
+    if key, ok := index[doi]; ok && !force {
+        return fmt.Errorf("%s is already in the library as %s, use -force to add it again", doi, key)
+    }
+
+    work, err := c.crossrefLookup(doi, "")
+    if err != nil {
+        return err
+    }
+    data := crossrefItemData(work)
+    if c.pretend("add %s as a %s item for %s", path, data["itemType"], doi) {
+        return nil
+    }
+
+    var key string
+    if remote {
+        key, err = c.remoteAddItem(data, path)
+    } else {
+        params := map[string]interface{}{"item": data, "path": path, "libraryID": c.repo.libraryID}
+        err = c.connectorExecute(addItemScript, params, &key)
+    }
+    if err != nil {
+        return fmt.Errorf("creating item: %w", err)
+    }
+    fmt.Println(key)
+    return nil
+}
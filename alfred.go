@@ -0,0 +1,58 @@
+package main
+
+import (
+    "path/filepath"
+    "strings"
+)
+
+// alfredItem is a single result in the Alfred Script Filter JSON format,
+// which Raycast script commands understand as well
+type alfredItem struct {
+    UID          string     `json:"uid"`
+    Title        string     `json:"title"`
+    Subtitle     string     `json:"subtitle"`
+    Arg          string     `json:"arg"`
+    Autocomplete string     `json:"autocomplete"`
+    QuicklookURL string     `json:"quicklookurl,omitempty"`
+    Text         alfredText `json:"text"`
+}
+
+// alfredText holds the values used for copying and large type display
+type alfredText struct {
+    Copy      string `json:"copy"`
+    LargeType string `json:"largetype"`
+}
+
+// printItemsAlfred prints items as an Alfred Script Filter document. The
+// stable ID is passed on as the argument so that workflow actions can call
+// open or reference with it.
+func (c *CLI) printItemsAlfred(items []*Item) error {
+    results := make([]alfredItem, 0, len(items))
+    for _, item := range items {
+        path := c.getStoragePath(item)
+
+        var subtitle []string
+        if len(item.Tags) > 0 {
+            subtitle = append(subtitle, strings.Join(item.displayTags(), ","))
+        }
+        if path != "" {
+            subtitle = append(subtitle, filepath.Base(path))
+        }
+
+        results = append(results, alfredItem{
+            UID:          item.StableID,
+            Title:        markedTitle(item),
+            Subtitle:     strings.Join(subtitle, " · "),
+            Arg:          item.StableID,
+            Autocomplete: item.Title,
+            QuicklookURL: path,
+            Text: alfredText{
+                Copy:      item.StableID,
+                LargeType: item.Title,
+            },
+        })
+    }
+    return writeJSON(struct {
+        Items []alfredItem `json:"items"`
+    }{results})
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+    "bufio"
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "strings"
+)
+
+// aliasesPath returns the location of the item aliases file
+func aliasesPath() (string, error) {
+    dir, err := stateDir()
+    if err != nil {
+        return "", err
+    }
+    return filepath.Join(dir, "aliases.txt"), nil
+}
+
+// readAliases returns the stable IDs of items by alias, read from lines of
+// "alias<TAB>key"
+func readAliases() (map[string]string, error) {
+    aliases := make(map[string]string)
+    path, err := aliasesPath()
+    if err != nil {
+        return nil, err
+    }
+    f, err := os.Open(path)
+    if errors.Is(err, os.ErrNotExist) {
+        return aliases, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("opening aliases: %w", err)
+    }
+    defer f.Close()
+
+    scanner := bufio.NewScanner(f)
+    for scanner.Scan() {
+        if name, key, ok := strings.Cut(scanner.Text(), "\t"); ok && name != "" {
+            aliases[name] = strings.TrimSpace(key)
+        }
+    }
+    if err := scanner.Err(); err != nil {
+        return nil, fmt.Errorf("reading aliases: %w", err)
+    }
+    return aliases, nil
+}
+
+// writeAliases replaces the aliases file with aliases, ordered by alias
+func writeAliases(aliases map[string]string) error {
+    path, err := aliasesPath()
+    if err != nil {
+        return err
+    }
+    if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+        return fmt.Errorf("creating state folder: %w", err)
+    }
+    var content strings.Builder
+    for _, name := range sortedKeys(aliases) {
+        content.WriteString(name + "\t" + aliases[name] + "\n")
+    }
+    if err := os.WriteFile(path, []byte(content.String()), 0644); err != nil {
+        return fmt.Errorf("writing aliases: %w", err)
+    }
+    return nil
+}
+
+// lookupAlias returns the stable ID an alias stands for, empty when name
+// is not an alias
+func lookupAlias(name string) (string, error) {
+    aliases, err := readAliases()
+    if err != nil {
+        return "", err
+    }
+    return aliases[name], nil
+}
+
+// SetAlias makes name stand for the item wherever an item is expected
+func (c *CLI) SetAlias(name, stableID string) error {
+    if name == lastKeyword || strings.ContainsFunc(name, func(r rune) bool { return r <= ' ' }) {
+        return fmt.Errorf("invalid alias %q: must not be %q or contain spaces", name, lastKeyword)
+    }
+    aliases, err := readAliases()
+    if err != nil {
+        return err
+    }
+    if c.pretend("make %s an alias of %s", name, stableID) {
+        return nil
+    }
+    aliases[name] = stableID
+    return writeAliases(aliases)
+}
+
+// RemoveAlias forgets an alias
+func (c *CLI) RemoveAlias(name string) error {
+    aliases, err := readAliases()
+    if err != nil {
+        return err
+    }
+    if _, ok := aliases[name]; !ok {
+        return fmt.Errorf("alias %w: %s", errNotFound, name)
+    }
+    if c.pretend("remove the alias %s", name) {
+        return nil
+    }
+    delete(aliases, name)
+    return writeAliases(aliases)
+}
+
+// Aliases prints every alias with the key and title of its item; items
+// deleted since are marked
+func (c *CLI) Aliases() error {
+    aliases, err := readAliases()
+    if err != nil {
+        return err
+    }
+    for _, name := range sortedKeys(aliases) {
+        key := aliases[name]
+        title := "(missing)"
+        item, err := c.repo.GetByStableID(key)
+        switch {
+        case err == nil:
+            title = item.Title
+        case !errors.Is(err, errNotFound):
+            return fmt.Errorf("getting item: %w", err)
+        }
+        fmt.Printf("%s\t%s\t%s\n", name, key, title)
+    }
+    return nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+    "fmt"
+    "html"
+    "io"
+    "strings"
+)
+
+// ankiDeckTagPrefix marks Zotero tags naming the sub-deck an item's cards
+// go to, e.g. "anki:Distributed Systems"
+const ankiDeckTagPrefix = "anki:"
+
+// ankiTag turns a Zotero tag into an Anki tag, which cannot hold spaces
+func ankiTag(tag string) string {
+    return strings.Join(strings.Fields(tag), "_")
+}
+
+// ankiField escapes text for a field of a tab separated Anki import
+func ankiField(s string) string {
+    s = html.EscapeString(s)
+    s = strings.ReplaceAll(s, "\t", " ")
+    return strings.ReplaceAll(s, "\n", "<br>")
+}
+
+// ExportAnki writes highlights and comments of items matching filter as an
+// Anki import file: the highlight on the front, the comment and source on
+// the back. Cards go to deck, or to a sub-deck named by an "anki:" tag,
+// and carry the item's other tags.
+func (c *CLI) ExportAnki(w io.Writer, filter Filter, deck string) error {
+    items, err := c.repo.ListItems(filter)
+    if err != nil {
+        return fmt.Errorf("listing items: %w", err)
+    }
+
+    fmt.Fprintln(w, "#separator:tab")
+    fmt.Fprintln(w, "#html:true")
+    fmt.Fprintln(w, "#guid column:1")
+    fmt.Fprintln(w, "#deck column:4")
+    fmt.Fprintln(w, "#tags column:5")
+    for _, item := range items {
+        annotations, err := c.repo.Annotations(item.ID)
+        if err != nil {
+            return fmt.Errorf("fetching annotations: %w", err)
+        }
+
+        itemDeck := deck
+        var cardTags []string
+        for _, tag := range item.displayTags() {
+            if sub, ok := strings.CutPrefix(tag, ankiDeckTagPrefix); ok {
+                itemDeck = deck + "::" + sub
+                continue
+            }
+            cardTags = append(cardTags, ankiTag(tag))
+        }
+
+        for _, annotation := range annotations {
+            if annotation.Text == "" {
+                continue
+            }
+            source := item.Title
+            if annotation.PageLabel != "" {
+                source += ", p. " + annotation.PageLabel
+            }
+            back := ankiField(annotation.Comment)
+            if back != "" {
+                back += "<br>"
+            }
+            back += "<small>" + ankiField(source) + "</small>"
+            // the annotation key as guid lets re-imports update cards
+            // instead of duplicating them
+            fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+                annotation.StableID,
+                ankiField(annotation.Text),
+                back,
+                itemDeck,
+                strings.Join(cardTags, " "))
+        }
+    }
+    return nil
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+    "database/sql"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "log/slog"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "regexp"
+    "strconv"
+)
+
+// imageResolution is the DPI annotation images are rendered at when
+// Zotero has not cached them
+const imageResolution = 150
+
+const groupIDQuery = `SELECT groupID FROM groups WHERE libraryID = ?`
+
+// pageSizePattern finds the page height in pdfinfo's output
+var pageSizePattern = regexp.MustCompile(`Page\s+\d+\s+size:\s+([\d.]+) x ([\d.]+) pts`)
+
+// annotationCachePath returns where Zotero's reader caches the rendered
+// image of an image annotation, below the data folder holding the
+// database
+func (c *CLI) annotationCachePath(annotation *Annotation) (string, error) {
+    dir := filepath.Join(filepath.Dir(c.cfg.DBPath), "cache")
+    var groupID int64
+    err := c.repo.queryRow(groupIDQuery, annotation.LibraryID).Scan(&groupID)
+    switch {
+    case errors.Is(err, sql.ErrNoRows):
+        dir = filepath.Join(dir, "library")
+    case err != nil:
+        return "", fmt.Errorf("fetching group: %w", err)
+    default:
+        dir = filepath.Join(dir, "groups", strconv.FormatInt(groupID, 10))
+    }
+    return filepath.Join(dir, annotation.StableID+".png"), nil
+}
+
+// renderAnnotation renders the region of an image annotation from the
+// PDF with poppler's pdftoppm into target
+func renderAnnotation(annotation *Annotation, pdf, target string) error {
+    var pos struct {
+        PageIndex int         `json:"pageIndex"`
+        Rects     [][]float64 `json:"rects"`
+    }
+    if err := json.Unmarshal([]byte(annotation.Position), &pos); err != nil {
+        return fmt.Errorf("parsing annotation position: %w", err)
+    }
+    if len(pos.Rects) == 0 || len(pos.Rects[0]) != 4 {
+        return fmt.Errorf("annotation %s has no region", annotation.StableID)
+    }
+    page := strconv.Itoa(pos.PageIndex + 1)
+
+    // PDF points run up from the bottom of the page, pixels down from the
+    // top, so the page height is needed to flip the region
+    out, err := exec.Command("pdfinfo", "-f", page, "-l", page, pdf).Output()
+    if err != nil {
+        return fmt.Errorf("running pdfinfo: %w", err)
+    }
+    size := pageSizePattern.FindSubmatch(out)
+    if size == nil {
+        return fmt.Errorf("no page size for page %s of %s", page, pdf)
+    }
+    height, _ := strconv.ParseFloat(string(size[2]), 64)
+
+    scale := float64(imageResolution) / 72
+    rect := pos.Rects[0]
+    px := func(v float64) string { return strconv.Itoa(int(v * scale)) }
+    cmd := exec.Command("pdftoppm", "-png", "-singlefile",
+        "-r", strconv.Itoa(imageResolution),
+        "-f", page, "-l", page,
+        "-x", px(rect[0]), "-y", px(height-rect[3]),
+        "-W", px(rect[2]-rect[0]), "-H", px(rect[3]-rect[1]),
+        pdf, trimExt(target))
+    if out, err := cmd.CombinedOutput(); err != nil {
+        return fmt.Errorf("running pdftoppm: %w: %s", err, out)
+    }
+    return nil
+}
+
+// trimExt returns path without its extension, the output prefix pdftoppm
+// expects
+func trimExt(path string) string {
+    return path[:len(path)-len(filepath.Ext(path))]
+}
+
+// copyFile copies the file at src to dst
+func copyFile(src, dst string) error {
+    in, err := os.Open(src)
+    if err != nil {
+        return err
+    }
+    defer in.Close()
+    out, err := os.Create(dst)
+    if err != nil {
+        return err
+    }
+    if _, err := io.Copy(out, in); err != nil {
+        out.Close()
+        return err
+    }
+    return out.Close()
+}
+
+// extractAnnotationImages writes the images of the item's image
+// annotations to dir as <annotation key>.png, taking Zotero's cached
+// rendering when there is one. It returns the written paths by annotation
+// key; annotations that cannot be rendered are reported and skipped.
+func (c *CLI) extractAnnotationImages(item *Item, annotations []*Annotation, dir string) (map[string]string, error) {
+    images := make(map[string]string)
+    for _, annotation := range annotations {
+        if annotation.Type != annotationImage {
+            continue
+        }
+        if c.pretend("save image annotation %s to %s", annotation.StableID, filepath.Join(dir, annotation.StableID+".png")) {
+            continue
+        }
+        if err := os.MkdirAll(dir, 0755); err != nil {
+            return nil, fmt.Errorf("creating image folder: %w", err)
+        }
+        target := filepath.Join(dir, annotation.StableID+".png")
+
+        cache, err := c.annotationCachePath(annotation)
+        if err != nil {
+            return nil, err
+        }
+        err = copyFile(cache, target)
+        if errors.Is(err, os.ErrNotExist) {
+            err = fmt.Errorf("%w for annotation %s", errNoAttachment, annotation.StableID)
+            for _, att := range item.Attachments {
+                if att.StableID == annotation.AttachmentKey && att.Exists() {
+                    err = renderAnnotation(annotation, att.Path, target)
+                }
+            }
+        }
+        if err != nil {
+            slog.Warn("skipping annotation image", "key", annotation.StableID, "error", err)
+            continue
+        }
+        images[annotation.StableID] = target
+    }
+    return images, nil
+}
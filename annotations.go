@@ -0,0 +1,122 @@
+package main
+
+import (
+    "database/sql"
+    "fmt"
+)
+
+// Zotero annotation types as stored in itemAnnotations.type
+const (
+    annotationHighlight = 1
+    annotationNote      = 2
+    annotationImage     = 3
+    annotationInk       = 4
+    annotationUnderline = 5
+    annotationText      = 6
+)
+
+// annotationTypeNames maps annotation types to the names used by the
+// Zotero API
+var annotationTypeNames = map[int]string{
+    annotationHighlight: "highlight",
+    annotationNote:      "note",
+    annotationImage:     "image",
+    annotationInk:       "ink",
+    annotationUnderline: "underline",
+    annotationText:      "text",
+}
+
+// Annotation is a highlight, note or drawing made in Zotero's reader on
+// one of an item's attachments
+type Annotation struct {
+    ID            int64
+    StableID      string
+    AttachmentKey string
+    Type          int
+    Text          string
+    Comment       string
+    Color         string
+    PageLabel     string
+    Tags          []string
+    // Position is the reader's JSON location of the annotation, with the
+    // page index and rectangles in PDF points
+    Position      string
+    LibraryID     int64
+}
+
+// TypeName returns the Zotero API name of the annotation's type
+func (a *Annotation) TypeName() string {
+    if name, ok := annotationTypeNames[a.Type]; ok {
+        return name
+    }
+    return "unknown"
+}
+
+const annotationsQuery = `
+    SELECT a.itemID, annotation.key, attachment.key, a.type, a.text, a.comment, a.color, a.pageLabel,
+        a.position, annotation.libraryID
+    FROM itemAnnotations a
+    JOIN items annotation ON a.itemID = annotation.itemID
+    JOIN items attachment ON a.parentItemID = attachment.itemID
+    JOIN itemAttachments ia ON a.parentItemID = ia.itemID
+    WHERE ia.parentItemID = ?
+    ORDER BY attachment.key, a.sortIndex, annotation.key`
+
+// Annotations retrieves the annotations on an item's attachments in
+// reading order, along with their tags
+func (r *Repository) Annotations(itemID int64) ([]*Annotation, error) {
+    if !r.hasTable("itemAnnotations") {
+        // Zotero 5 kept no annotations in the database
+        return nil, nil
+    }
+    rows, err := r.query(annotationsQuery, itemID)
+    if err != nil {
+        return nil, fmt.Errorf("executing query: %w", err)
+    }
+    defer rows.Close()
+
+    var annotations []*Annotation
+    for rows.Next() {
+        var annotation Annotation
+        var text, comment, color, pageLabel sql.NullString
+        if err := rows.Scan(
+            &annotation.ID,
+            &annotation.StableID,
+            &annotation.AttachmentKey,
+            &annotation.Type,
+            &text,
+            &comment,
+            &color,
+            &pageLabel,
+            &annotation.Position,
+            &annotation.LibraryID,
+        ); err != nil {
+            return nil, fmt.Errorf("scanning row: %w", err)
+        }
+        annotation.Text = text.String
+        annotation.Comment = comment.String
+        annotation.Color = color.String
+        annotation.PageLabel = pageLabel.String
+        annotations = append(annotations, &annotation)
+    }
+
+    if err = rows.Err(); err != nil {
+        return nil, fmt.Errorf("iterating rows: %w", err)
+    }
+
+    if len(annotations) == 0 {
+        return nil, nil
+    }
+    ids := make([]int64, len(annotations))
+    for i, annotation := range annotations {
+        ids[i] = annotation.ID
+    }
+    tags, err := r.Tags(ids)
+    if err != nil {
+        return nil, fmt.Errorf("fetching annotation tags: %w", err)
+    }
+    for _, annotation := range annotations {
+        annotation.Tags = tags[annotation.ID]
+    }
+    return annotations, nil
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+    "fmt"
+    "slices"
+    "strings"
+    "unicode"
+)
+
+const creatorItemsQuery = `
+    SELECT c.creatorID, c.firstName, c.lastName, i.key
+    FROM creators c
+    JOIN itemCreators ic ON c.creatorID = ic.creatorID
+    JOIN items i ON ic.itemID = i.itemID
+    JOIN itemTypes it ON i.itemTypeID = it.itemTypeID
+    WHERE it.display = 1
+    AND i.itemID NOT IN (SELECT itemID FROM deletedItems)`
+
+// creatorVariant is one spelling of a person's name together with the
+// items it appears on
+type creatorVariant struct {
+    Author
+    Items []string
+
+    // last and given are the folded family name and given name parts
+    last  string
+    given []string
+}
+
+// nameParts splits a creator's name into its folded family name and given
+// name parts, "J. A." giving "j" and "a". Single-field names written
+// "Last, First" are split at the comma; others are kept whole, as they
+// are mostly institutions.
+func nameParts(first, last string) (string, []string) {
+    if first == "" {
+        if before, after, ok := strings.Cut(last, ","); ok {
+            last, first = before, after
+        }
+    }
+    isSeparator := func(r rune) bool {
+        return unicode.IsSpace(r) || r == '.' || r == '-'
+    }
+    return strings.Join(strings.Fields(fold(last)), " "), strings.FieldsFunc(fold(first), isSeparator)
+}
+
+// sameGiven reports whether two given names may be the same person's:
+// every part present in both agrees, an initial agreeing with any part
+// starting with it, so that "J." matches "John A." but not "Jane"
+func sameGiven(a, b []string) bool {
+    if (len(a) == 0) != (len(b) == 0) {
+        return false
+    }
+    for i := range min(len(a), len(b)) {
+        x, y := a[i], b[i]
+        switch {
+        case x == y:
+        case len([]rune(x)) == 1 && strings.HasPrefix(y, x):
+        case len([]rune(y)) == 1 && strings.HasPrefix(x, y):
+        default:
+            return false
+        }
+    }
+    return true
+}
+
+// creatorVariants reads the creators of the library with the items they
+// appear on, leaving out trashed items
+func (r *Repository) creatorVariants() ([]*creatorVariant, error) {
+    query := creatorItemsQuery
+    conditions, args := r.scopeConditions()
+    for _, condition := range conditions {
+        query += " AND " + condition
+    }
+    query += " ORDER BY c.lastName, c.firstName, c.creatorID, i.key"
+
+    rows, err := r.query(query, args...)
+    if err != nil {
+        return nil, fmt.Errorf("executing query: %w", err)
+    }
+    defer rows.Close()
+
+    var variants []*creatorVariant
+    byID := make(map[int64]*creatorVariant)
+    for rows.Next() {
+        var author Author
+        var key string
+        if err := rows.Scan(&author.ID, &author.FirstName, &author.LastName, &key); err != nil {
+            return nil, fmt.Errorf("scanning row: %w", err)
+        }
+        variant := byID[author.ID]
+        if variant == nil {
+            variant = &creatorVariant{Author: author}
+            variant.last, variant.given = nameParts(author.FirstName, author.LastName)
+            byID[author.ID] = variant
+            variants = append(variants, variant)
+        }
+        if !slices.Contains(variant.Items, key) {
+            variant.Items = append(variant.Items, key)
+            variant.ItemCount++
+        }
+    }
+
+    if err = rows.Err(); err != nil {
+        return nil, fmt.Errorf("iterating rows: %w", err)
+    }
+
+    return variants, nil
+}
+
+// creatorClusters groups the variants that probably name the same person:
+// those sharing a family name whose given names agree, directly or through
+// another variant. Only groups of several variants are returned, the
+// fullest spelling first.
+func creatorClusters(variants []*creatorVariant) [][]*creatorVariant {
+    byLast := make(map[string][]*creatorVariant)
+    var lasts []string
+    for _, variant := range variants {
+        if variant.last == "" {
+            continue
+        }
+        if byLast[variant.last] == nil {
+            lasts = append(lasts, variant.last)
+        }
+        byLast[variant.last] = append(byLast[variant.last], variant)
+    }
+    slices.Sort(lasts)
+
+    var clusters [][]*creatorVariant
+    for _, last := range lasts {
+        candidates := byLast[last]
+        done := make([]bool, len(candidates))
+        for i := range candidates {
+            if done[i] {
+                continue
+            }
+            done[i] = true
+            cluster := []*creatorVariant{candidates[i]}
+            for k := 0; k < len(cluster); k++ {
+                for j, other := range candidates {
+                    if !done[j] && sameGiven(cluster[k].given, other.given) {
+                        done[j] = true
+                        cluster = append(cluster, other)
+                    }
+                }
+            }
+            if len(cluster) < 2 {
+                continue
+            }
+            slices.SortStableFunc(cluster, func(a, b *creatorVariant) int {
+                return len(b.FirstName+b.LastName) - len(a.FirstName+a.LastName)
+            })
+            clusters = append(clusters, cluster)
+        }
+    }
+    return clusters
+}
+
+// ambiguous reports whether a cluster holds given names that disagree,
+// joined only through an initial, as "John" and "Jane" through "J."
+func ambiguous(cluster []*creatorVariant) bool {
+    for i, a := range cluster {
+        for _, b := range cluster[i+1:] {
+            if !sameGiven(a.given, b.given) {
+                return true
+            }
+        }
+    }
+    return false
+}
+
+// LintAuthors reports the creators probably spelled several ways, such as
+// "Smith, J.", "Smith, John" and "Smith, John A.", with the items each
+// spelling appears on. Clusters joined only through an initial are marked
+// ambiguous. It fails when there is anything to report.
+func (c *CLI) LintAuthors() error {
+    variants, err := c.repo.creatorVariants()
+    if err != nil {
+        return fmt.Errorf("listing creators: %w", err)
+    }
+    clusters := creatorClusters(variants)
+    if len(clusters) == 0 {
+        fmt.Println("No creators spelled inconsistently")
+        return nil
+    }
+
+    for _, cluster := range clusters {
+        header := cluster[0].Name()
+        if ambiguous(cluster) {
+            header += "\tambiguous"
+        }
+        fmt.Println(header)
+        for _, variant := range cluster {
+            fmt.Printf("\t%s\t%d\t%s\n", variant.Name(), variant.ItemCount, strings.Join(variant.Items, ","))
+        }
+    }
+    return fmt.Errorf("%d creators spelled inconsistently", len(clusters))
+}
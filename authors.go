@@ -0,0 +1,196 @@
+package main
+
+import (
+    "fmt"
+)
+
+// Author represents a Zotero creator together with the number of
+// top-level items they appear on
+type Author struct {
+    ID        int64
+    FirstName string
+    LastName  string
+    ItemCount int
+}
+
+// Name formats the creator as "Last, First", or just the last name for
+// single-field creators such as institutions
+func (a *Author) Name() string {
+    if a.FirstName == "" {
+        return a.LastName
+    }
+    return a.LastName + ", " + a.FirstName
+}
+
+const authorsQuery = `
+    SELECT
+        c.creatorID,
+        c.firstName,
+        c.lastName,
+        COUNT(DISTINCT i.itemID) as items
+    FROM creators c
+    JOIN itemCreators ic ON c.creatorID = ic.creatorID
+    JOIN items i ON ic.itemID = i.itemID
+    JOIN itemTypes it ON i.itemTypeID = it.itemTypeID
+    WHERE it.display = 1`
+
+// ListAuthors retrieves creators whose name contains nameFilter, ordered by
+// the number of items they appear on
+func (r *Repository) ListAuthors(nameFilter string) ([]*Author, error) {
+    query := authorsQuery
+    conditions, args := r.scopeConditions()
+    for _, condition := range conditions {
+        query += " AND " + condition
+    }
+    if nameFilter != "" {
+        query += ` AND (fold(c.firstName || ' ' || c.lastName) LIKE fold(?)
+            OR fold(c.lastName || ', ' || c.firstName) LIKE fold(?))`
+        args = append(args, "%"+nameFilter+"%", "%"+nameFilter+"%")
+    }
+    query += " GROUP BY c.creatorID ORDER BY items DESC, c.lastName, c.firstName, c.creatorID"
+
+    rows, err := r.query(query, args...)
+    if err != nil {
+        return nil, fmt.Errorf("executing query: %w", err)
+    }
+    defer rows.Close()
+
+    var authors []*Author
+    for rows.Next() {
+        var author Author
+        if err := rows.Scan(
+            &author.ID,
+            &author.FirstName,
+            &author.LastName,
+            &author.ItemCount,
+        ); err != nil {
+            return nil, fmt.Errorf("scanning row: %w", err)
+        }
+        authors = append(authors, &author)
+    }
+
+    if err = rows.Err(); err != nil {
+        return nil, fmt.Errorf("iterating rows: %w", err)
+    }
+
+    return authors, nil
+}
+
+// Authors prints creators matching name along with their item counts
+func (c *CLI) Authors(name string) error {
+    authors, err := c.repo.ListAuthors(name)
+    if err != nil {
+        return fmt.Errorf("listing authors: %w", err)
+    }
+
+    for _, author := range authors {
+        fmt.Printf("%d\t%s\n", author.ItemCount, author.Name())
+    }
+    return nil
+}
+
+// authorGroup is the JSON representation of a --by-author group
+type authorGroup struct {
+    Author string     `json:"author"`
+    Items  []JSONItem `json:"items"`
+}
+
+// ListByAuthor displays items matching filter grouped under every creator
+// whose name contains name
+func (c *CLI) ListByAuthor(name string, filter Filter, opts ListOptions) error {
+    authors, err := c.repo.ListAuthors(name)
+    if err != nil {
+        return fmt.Errorf("listing authors: %w", err)
+    }
+
+    var groups []authorGroup
+    var flattened []*Item
+    for _, author := range authors {
+        authorFilter := filter
+        authorFilter.CreatorID = author.ID
+        items, err := c.repo.ListItems(authorFilter)
+        if err != nil {
+            return fmt.Errorf("listing items: %w", err)
+        }
+        if len(items) == 0 {
+            continue
+        }
+
+        if opts.Format == "json" {
+            group := authorGroup{Author: author.Name()}
+            for _, item := range items {
+                encoded, err := c.itemJSON(item, opts)
+                if err != nil {
+                    return err
+                }
+                group.Items = append(group.Items, encoded)
+            }
+            groups = append(groups, group)
+            continue
+        }
+        if opts.Format == "alfred" {
+            // result lists have no notion of headings
+            flattened = append(flattened, items...)
+            continue
+        }
+
+        fmt.Printf("%s (%d)\n", author.Name(), len(items))
+        if err := c.printItems(items, opts); err != nil {
+            return err
+        }
+    }
+
+    switch opts.Format {
+    case "json":
+        return writeJSON(groups)
+    case "alfred":
+        return c.printItemsAlfred(flattened)
+    }
+    return nil
+}
+
+// Creator is a creator as listed on a single item
+type Creator struct {
+    FirstName   string `json:"firstName,omitempty"`
+    LastName    string `json:"lastName"`
+    CreatorType string `json:"creatorType"`
+}
+
+const creatorsQuery = `
+    SELECT ic.itemID, c.firstName, c.lastName, ct.creatorType
+    FROM itemCreators ic
+    JOIN creators c ON ic.creatorID = c.creatorID
+    JOIN creatorTypes ct ON ic.creatorTypeID = ct.creatorTypeID
+    WHERE ic.itemID IN (SELECT value FROM json_each(?))
+    ORDER BY ic.itemID, ic.orderIndex`
+
+// Creators retrieves the creators of the items with the given IDs in a
+// single query, keyed by item ID and in their listed order
+func (r *Repository) Creators(itemIDs []int64) (map[int64][]Creator, error) {
+    rows, err := r.query(creatorsQuery, idList(itemIDs))
+    if err != nil {
+        return nil, fmt.Errorf("executing query: %w", err)
+    }
+    defer rows.Close()
+
+    creators := make(map[int64][]Creator)
+    for rows.Next() {
+        var itemID int64
+        var creator Creator
+        if err := rows.Scan(
+            &itemID,
+            &creator.FirstName,
+            &creator.LastName,
+            &creator.CreatorType,
+        ); err != nil {
+            return nil, fmt.Errorf("scanning row: %w", err)
+        }
+        creators[itemID] = append(creators[itemID], creator)
+    }
+
+    if err = rows.Err(); err != nil {
+        return nil, fmt.Errorf("iterating rows: %w", err)
+    }
+
+    return creators, nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+    "fmt"
+    "io"
+    "os"
+    "regexp"
+    "slices"
+    "strings"
+)
+
+// citationPattern finds Pandoc citations such as [@key, p. 3], @key and
+// @{key}. Keys may contain internal punctuation, trailing punctuation
+// belongs to the sentence. An @ right after a word character is part of
+// an email address, not a citation.
+var citationPattern = regexp.MustCompile(`(?:^|[^\w@])@(?:\{([^}]+)\}|(\w(?:[\w:.#$%&+?<>~/-]*\w)?))`)
+
+// codePattern matches fenced code blocks and inline code, which may hold
+// @ signs that are not citations
+var codePattern = regexp.MustCompile("(?s)```.*?```|~~~.*?~~~|`[^`\n]*`")
+
+// citedKeys returns the citation keys used in a Markdown document, in
+// order of first use
+func citedKeys(markdown string) []string {
+    markdown = codePattern.ReplaceAllString(markdown, "")
+    var keys []string
+    for _, match := range citationPattern.FindAllStringSubmatch(markdown, -1) {
+        key := match[1]
+        if key == "" {
+            key = match[2]
+        }
+        if !slices.Contains(keys, key) {
+            keys = append(keys, key)
+        }
+    }
+    return keys
+}
+
+// Bibliography writes a BibTeX file holding exactly the items cited in
+// the Markdown document at from, to out or stdout when out is empty.
+// Citation keys are resolved as in the library, falling back to stable
+// IDs, and any key that cannot be resolved fails the whole run so that no
+// incomplete bibliography is written.
+func (c *CLI) Bibliography(from, out string) error {
+    data, err := os.ReadFile(from)
+    if err != nil {
+        return fmt.Errorf("reading document: %w", err)
+    }
+    keys := citedKeys(string(data))
+
+    index, err := c.repo.citationKeyIndex()
+    if err != nil {
+        return err
+    }
+    byStableID := make(map[string]DumpItem)
+    for _, item := range index {
+        byStableID[item.Key] = item
+    }
+
+    var unresolved []string
+    var entries []string
+    for _, key := range keys {
+        if _, ok := index[key]; ok {
+            entries = append(entries, key)
+            continue
+        }
+        if item, ok := byStableID[key]; ok {
+            index[key] = item
+            entries = append(entries, key)
+            continue
+        }
+        unresolved = append(unresolved, key)
+    }
+    if len(unresolved) > 0 {
+        return fmt.Errorf("citation keys %w: %s", errNotFound, strings.Join(unresolved, ", "))
+    }
+    slices.Sort(entries)
+    if err := c.warnRetracted(entries, index); err != nil {
+        return err
+    }
+
+    write := func(w io.Writer) error {
+        for _, key := range entries {
+            if err := writeBibTeX(w, key, index[key]); err != nil {
+                return fmt.Errorf("writing bibliography: %w", err)
+            }
+        }
+        return nil
+    }
+    if out == "" {
+        return write(os.Stdout)
+    }
+    if c.pretend("write %d entries to %s", len(entries), out) {
+        return nil
+    }
+    f, err := os.Create(out)
+    if err != nil {
+        return fmt.Errorf("creating %s: %w", out, err)
+    }
+    if err := write(f); err != nil {
+        f.Close()
+        return err
+    }
+    if err := f.Close(); err != nil {
+        return fmt.Errorf("writing %s: %w", out, err)
+    }
+    return nil
+}
+
+// ExportBibTeX writes items matching filter as BibTeX entries, keyed by
+// the same citation keys bib uses and in key order
+func (c *CLI) ExportBibTeX(w io.Writer, filter Filter) error {
+    items, err := c.repo.ListItems(filter)
+    if err != nil {
+        return fmt.Errorf("listing items: %w", err)
+    }
+    index, err := c.repo.citationKeyIndex()
+    if err != nil {
+        return err
+    }
+    keys := make(map[string]string, len(index))
+    for key, item := range index {
+        keys[item.Key] = key
+    }
+
+    var entries []string
+    for _, item := range items {
+        if key, ok := keys[item.StableID]; ok {
+            entries = append(entries, key)
+        }
+    }
+    slices.Sort(entries)
+
+    for _, key := range entries {
+        if err := writeBibTeX(w, key, index[key]); err != nil {
+            return fmt.Errorf("writing bibliography: %w", err)
+        }
+    }
+    return nil
+}
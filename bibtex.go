@@ -0,0 +1,122 @@
+package main
+
+import (
+    "fmt"
+    "io"
+    "strings"
+)
+
+// bibtexTypes maps Zotero item types to BibTeX entry types
+var bibtexTypes = map[string]string{
+    "journalArticle":   "article",
+    "magazineArticle":  "article",
+    "newspaperArticle": "article",
+    "book":             "book",
+    "bookSection":      "incollection",
+    "conferencePaper":  "inproceedings",
+    "thesis":           "phdthesis",
+    "report":           "techreport",
+    "manuscript":       "unpublished",
+}
+
+// bibtexFields maps Zotero fields to BibTeX fields, in output order
+var bibtexFields = [][2]string{
+    {"title", "title"},
+    {"publicationTitle", "journal"},
+    {"proceedingsTitle", "booktitle"},
+    {"bookTitle", "booktitle"},
+    {"edition", "edition"},
+    {"volume", "volume"},
+    {"issue", "number"},
+    {"pages", "pages"},
+    {"publisher", "publisher"},
+    {"university", "school"},
+    {"institution", "institution"},
+    {"place", "address"},
+    {"series", "series"},
+    {"DOI", "doi"},
+    {"ISBN", "isbn"},
+    {"ISSN", "issn"},
+    {"url", "url"},
+    {"language", "langid"},
+}
+
+// bibtexCreatorTypes maps Zotero creator roles to BibTeX name fields.
+// bookauthor is biblatex's author of the book containing a chapter.
+var bibtexCreatorTypes = [][2]string{
+    {"author", "author"},
+    {"editor", "editor"},
+    {"translator", "translator"},
+    {"bookAuthor", "bookauthor"},
+}
+
+// bibtexEscaper protects the characters BibTeX and LaTeX treat specially
+var bibtexEscaper = strings.NewReplacer(
+    `\`, `\textbackslash{}`,
+    "{", `\{`,
+    "}", `\}`,
+    "&", `\&`,
+    "%", `\%`,
+    "$", `\$`,
+    "#", `\#`,
+    "_", `\_`,
+    "~", `\textasciitilde{}`,
+    "^", `\textasciicircum{}`,
+)
+
+// bibtexName formats a creator as BibTeX expects, protecting single-field
+// names such as institutions from being split
+func bibtexName(creator Creator) string {
+    if creator.FirstName == "" {
+        return "{" + bibtexEscaper.Replace(creator.LastName) + "}"
+    }
+    return bibtexEscaper.Replace(creator.LastName + ", " + creator.FirstName)
+}
+
+// writeBibTeX writes a BibTeX entry for item under the given key
+func writeBibTeX(w io.Writer, key string, item DumpItem) error {
+    entryType, ok := bibtexTypes[item.ItemType]
+    if !ok {
+        entryType = "misc"
+    }
+
+    var b strings.Builder
+    fmt.Fprintf(&b, "@%s{%s,\n", entryType, key)
+    field := func(name, value string) {
+        fmt.Fprintf(&b, "  %s = {%s},\n", name, value)
+    }
+
+    for _, role := range bibtexCreatorTypes {
+        var names []string
+        for _, creator := range item.Creators {
+            if creator.CreatorType == role[0] {
+                names = append(names, bibtexName(creator))
+            }
+        }
+        if len(names) > 0 {
+            field(role[1], strings.Join(names, " and "))
+        }
+    }
+    for _, mapping := range bibtexFields {
+        value := item.Fields[mapping[0]]
+        if value == "" {
+            continue
+        }
+        switch mapping[1] {
+        case "pages":
+            value = strings.NewReplacer("–", "--", "-", "--").Replace(strings.ReplaceAll(value, "--", "-"))
+        case "url", "doi":
+            // verbatim fields
+            field(mapping[1], value)
+            continue
+        }
+        field(mapping[1], bibtexEscaper.Replace(value))
+    }
+    if year := yearPattern.FindString(item.Fields["date"]); year != "" {
+        field("year", year)
+    }
+    b.WriteString("}\n\n")
+
+    _, err := io.WriteString(w, b.String())
+    return err
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+    "archive/zip"
+    "bytes"
+    "compress/flate"
+    "fmt"
+    "hash/crc32"
+    "io"
+    "log/slog"
+    "os"
+    "path/filepath"
+)
+
+// bundleEntry describes one item of a bundle in its manifest
+type bundleEntry struct {
+    CitationKey string `json:"citekey"`
+    Key         string `json:"key"`
+    Title       string `json:"title"`
+    // File is the path of the item's attachment inside the bundle, empty
+    // when it has none on disk
+    File string `json:"file,omitempty"`
+}
+
+// Bundle writes a zip archive to out holding the best attachment of each
+// item matching filter under files/, named by citation key, along with
+// references.json, the items as CSL-JSON with citation keys as IDs, and
+// manifest.json, which ties citation keys, stable IDs and files together.
+// jobs attachments are downloaded and read ahead at once.
+func (c *CLI) Bundle(out string, filter Filter, jobs int) error {
+    items, err := c.repo.ListItems(filter)
+    if err != nil {
+        return fmt.Errorf("listing items: %w", err)
+    }
+    index, err := c.repo.citationKeyIndex()
+    if err != nil {
+        return err
+    }
+    keys := make(map[string]string, len(index))
+    for key, item := range index {
+        keys[item.Key] = key
+    }
+    if c.pretend("write a bundle of %d items to %s", len(items), out) {
+        // with the attachments that would be downloaded for it, as
+        // export tree reports them
+        for _, item := range items {
+            if _, ok := keys[item.StableID]; !ok {
+                continue
+            }
+            if att := storageAttachment(item); att != nil {
+                if err := c.ensureLocal(att); err != nil {
+                    return err
+                }
+            }
+        }
+        return nil
+    }
+
+    f, err := os.Create(out)
+    if err != nil {
+        return fmt.Errorf("creating %s: %w", out, err)
+    }
+    written, err := c.writeBundle(f, items, index, keys, jobs)
+    if err != nil {
+        f.Close()
+        os.Remove(out)
+        return err
+    }
+    if err := f.Close(); err != nil {
+        return fmt.Errorf("writing %s: %w", out, err)
+    }
+    fmt.Printf("%d items written to %s\n", written, out)
+    return nil
+}
+
+// writeBundle writes the archive of Bundle to w, returning the number of
+// items in it. The archive is written one file after the other, while
+// workers download missing attachments and compress the next ones, jobs
+// files at a time to bound the memory held.
+func (c *CLI) writeBundle(w io.Writer, items []*Item, index map[string]DumpItem, keys map[string]string, jobs int) (int, error) {
+    var cited []*Item
+    for _, item := range items {
+        // notes and attachments have no citation key
+        if _, ok := keys[item.StableID]; ok {
+            cited = append(cited, item)
+        }
+    }
+    jobs = max(jobs, 1)
+    var files []chan bundleFile
+
+    archive := zip.NewWriter(w)
+    references := make([]cslItem, 0, len(cited))
+    manifest := make([]bundleEntry, 0, len(cited))
+    for i, item := range cited {
+        key := keys[item.StableID]
+        references = append(references, cslItemOf(key, index[key]))
+        entry := bundleEntry{CitationKey: key, Key: item.StableID, Title: item.Title}
+
+        if i%jobs == 0 {
+            batch := cited[i:min(i+jobs, len(cited))]
+            files = parallel(len(batch), jobs, func(j int) bundleFile {
+                return c.bundleFileOf(batch[j])
+            })
+        }
+        file := <-files[i%jobs]
+        if file.err != nil {
+            return 0, file.err
+        }
+        if file.header != nil {
+            entry.File = "files/" + key + file.ext
+            if err := addBundleFile(archive, entry.File, file); err != nil {
+                return 0, err
+            }
+        }
+        manifest = append(manifest, entry)
+    }
+
+    documents := []struct {
+        name string
+        v    interface{}
+    }{{"references.json", references}, {"manifest.json", manifest}}
+    for _, document := range documents {
+        part, err := archive.Create(document.name)
+        if err != nil {
+            return 0, fmt.Errorf("adding %s: %w", document.name, err)
+        }
+        if err := encodeJSON(part, document.v); err != nil {
+            return 0, err
+        }
+    }
+    if err := archive.Close(); err != nil {
+        return 0, fmt.Errorf("finishing archive: %w", err)
+    }
+    return len(manifest), nil
+}
+
+// bundleFile is an item's attachment compressed for the archive, with no
+// header when the item has none on disk
+type bundleFile struct {
+    header *zip.FileHeader
+    ext    string
+    data   []byte
+    err    error
+}
+
+// bundleFileOf downloads the best attachment of item when it is missing
+// and compresses it
+func (c *CLI) bundleFileOf(item *Item) bundleFile {
+    att := storageAttachment(item)
+    if att == nil {
+        return bundleFile{}
+    }
+    if err := c.ensureLocal(att); err != nil {
+        slog.Warn("could not download attachment", "key", item.StableID, "error", err)
+    }
+    if !att.Exists() {
+        slog.Warn("attachment missing from disk, left out", "key", item.StableID, "path", att.Path)
+        return bundleFile{}
+    }
+
+    data, err := os.ReadFile(att.Path)
+    if err != nil {
+        return bundleFile{err: fmt.Errorf("reading %s: %w", att.Path, err)}
+    }
+    info, err := os.Stat(att.Path)
+    if err != nil {
+        return bundleFile{err: fmt.Errorf("reading %s: %w", att.Path, err)}
+    }
+    header, err := zip.FileInfoHeader(info)
+    if err != nil {
+        return bundleFile{err: fmt.Errorf("adding %s: %w", att.Path, err)}
+    }
+    header.Method = zip.Deflate
+    header.CRC32 = crc32.ChecksumIEEE(data)
+    header.UncompressedSize64 = uint64(len(data))
+
+    var compressed bytes.Buffer
+    fw, err := flate.NewWriter(&compressed, flate.DefaultCompression)
+    if err == nil {
+        _, err = fw.Write(data)
+    }
+    if err == nil {
+        err = fw.Close()
+    }
+    if err != nil {
+        return bundleFile{err: fmt.Errorf("compressing %s: %w", att.Path, err)}
+    }
+    header.CompressedSize64 = uint64(compressed.Len())
+    return bundleFile{header: header, ext: filepath.Ext(att.Path), data: compressed.Bytes()}
+}
+
+// addBundleFile writes a compressed attachment into the archive as name
+func addBundleFile(archive *zip.Writer, name string, file bundleFile) error {
+    file.header.Name = name
+    part, err := archive.CreateRaw(file.header)
+    if err != nil {
+        return fmt.Errorf("adding %s: %w", name, err)
+    }
+    if _, err := part.Write(file.data); err != nil {
+        return fmt.Errorf("adding %s: %w", name, err)
+    }
+    return nil
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+    "fmt"
+    "slices"
+    "sort"
+    "strings"
+)
+
+// Capabilities describes what the installed binary supports so that
+// wrapper tools can adapt to it without parsing help text
+type Capabilities struct {
+    Version        string              `json:"version"`
+    Commands       []string            `json:"commands"`
+    Formats        map[string][]string `json:"formats"`
+    SchemaVersions map[string]int      `json:"schemaVersions"`
+    Features       map[string]bool     `json:"features"`
+}
+
+// supportedCommands lists the subcommands understood by main
+var supportedCommands = []string{
+    "list",
+    "random",
+    "libraries",
+    "authors",
+    "venues",
+    "related",
+    "history",
+    "pin",
+    "unpin",
+    "pins",
+    "alias",
+    "dump",
+    "diff",
+    "du",
+    "graph",
+    "enrich",
+    "citations",
+    "oa",
+    "tag",
+    "tags",
+    "suggest-tags",
+    "status",
+    "collection",
+    "note",
+    "field",
+    "citekey",
+    "add",
+    "export",
+    "bib",
+    "bundle",
+    "match",
+    "coverage",
+    "site",
+    "index",
+    "semsearch",
+    "tui",
+    "verify",
+    "fetch",
+    "serve",
+    "open",
+    "reveal",
+    "reference",
+    "path",
+    "link",
+    "text",
+    "grep",
+    "annotations",
+    "doctor",
+    "capabilities",
+}
+
+// outputFormats lists the output formats available per command
+var outputFormats = map[string][]string{
+    "list":         {"plain", "verbose", "json", "alfred"},
+    "random":       {"plain", "verbose", "json", "alfred"},
+    "libraries":    {"text"},
+    "authors":      {"text"},
+    "venues":       {"text"},
+    "related":      {"plain", "verbose", "json", "alfred"},
+    "verify":       {"text"},
+    "history":      {"text"},
+    "dump":         {"json"},
+    "diff":         {"text"},
+    "du":           {"text"},
+    "graph":        {"dot", "graphml"},
+    "enrich":       {"text", "json"},
+    "citations":    {"text", "json"},
+    "oa":           {"text"},
+    "status":       {"text"},
+    "suggest-tags": {"text", "json"},
+    "export":       slices.Concat(exportFormats, []string{exportTree}),
+    "bib":          {"bibtex"},
+    "bundle":       {"zip"},
+    "match":        {"text", "json"},
+    "coverage":     {"text", "json"},
+    "site":         {"html"},
+    "index":        {"text"},
+    "semsearch":    {"text", "json"},
+    "pins":         {"plain", "verbose", "json", "alfred"},
+    "alias":        {"text"},
+    "reference":    {"markdown", "latex", "wikipedia"},
+    "path":         {"text"},
+    "link":         {"text"},
+    "text":         {"text"},
+    "grep":         {"text"},
+    "annotations":  {"markdown", "json"},
+    "doctor":       {"text"},
+    "capabilities": {"text", "json"},
+}
+
+// schemaVersions tracks the version of every JSON document the tool emits.
+// Bump an entry whenever a field is renamed or removed.
+var schemaVersions = map[string]int{
+    "capabilities": 1,
+    "dump":         dumpVersion,
+    "item":         2,
+    "rpc":          1,
+}
+
+// optionalFeatures records features that depend on how the binary was built
+var optionalFeatures = map[string]bool{
+    "semanticSearch": true,
+    "ocr":            false,
+    "fts5":           fts5Available,
+    "starlark":       true,
+}
+
+// capabilities assembles the capability report for this build
+func (c *CLI) capabilities() Capabilities {
+    return Capabilities{
+        Version:        c.cfg.Version,
+        Commands:       supportedCommands,
+        Formats:        c.withFormatters(outputFormats),
+        SchemaVersions: schemaVersions,
+        Features:       optionalFeatures,
+    }
+}
+
+// Capabilities prints the capability report as text or JSON
+func (c *CLI) Capabilities(asJSON bool) error {
+    caps := c.capabilities()
+    if asJSON {
+        return writeJSON(caps)
+    }
+
+    fmt.Printf("version:\t%s\n", caps.Version)
+    fmt.Printf("commands:\t%s\n", strings.Join(caps.Commands, ", "))
+    for _, name := range sortedKeys(caps.Formats) {
+        fmt.Printf("formats.%s:\t%s\n", name, strings.Join(caps.Formats[name], ", "))
+    }
+    for _, name := range sortedKeys(caps.SchemaVersions) {
+        fmt.Printf("schema.%s:\t%d\n", name, caps.SchemaVersions[name])
+    }
+    for _, name := range sortedKeys(caps.Features) {
+        fmt.Printf("feature.%s:\t%t\n", name, caps.Features[name])
+    }
+    return nil
+}
+
+// sortedKeys returns the keys of m in lexical order
+func sortedKeys[V any](m map[string]V) []string {
+    keys := make([]string, 0, len(m))
+    for k := range m {
+        keys = append(keys, k)
+    }
+    sort.Strings(keys)
+    return keys
+}
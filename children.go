@@ -0,0 +1,266 @@
+package main
+
+import (
+    "fmt"
+    "os"
+    "path/filepath"
+    "slices"
+    "strings"
+)
+
+// Zotero attachment link modes as stored in itemAttachments.linkMode
+const (
+    linkModeImportedFile = 0
+    linkModeImportedURL  = 1
+    linkModeLinkedFile   = 2
+    linkModeLinkedURL    = 3
+)
+
+// linkModeNames maps link modes to the names used by the Zotero API
+var linkModeNames = map[int]string{
+    linkModeImportedFile: "imported_file",
+    linkModeImportedURL:  "imported_url",
+    linkModeLinkedFile:   "linked_file",
+    linkModeLinkedURL:    "linked_url",
+}
+
+// Attachment represents a file or link stored under a top-level item
+type Attachment struct {
+    StableID    string
+    Title       string
+    LinkMode    int
+    ContentType string
+    Path        string
+    StorageHash string
+}
+
+// LinkModeName returns the Zotero API name of the attachment's link mode
+func (a *Attachment) LinkModeName() string {
+    if name, ok := linkModeNames[a.LinkMode]; ok {
+        return name
+    }
+    return "unknown"
+}
+
+// attachmentKinds are the kinds of attachment open can be asked for
+var attachmentKinds = []string{"pdf", "epub", "html"}
+
+// attachmentContentTypes maps attachment kinds to the MIME types Zotero
+// records for them
+var attachmentContentTypes = map[string][]string{
+    "pdf":  {"application/pdf"},
+    "epub": {"application/epub+zip"},
+    "html": {"text/html", "application/xhtml+xml"},
+}
+
+// Kind returns the attachment's kind by content type, empty for other
+// types
+func (a *Attachment) Kind() string {
+    for _, kind := range attachmentKinds {
+        if slices.Contains(attachmentContentTypes[kind], a.ContentType) {
+            return kind
+        }
+    }
+    return ""
+}
+
+// attachmentOfKind returns the item's first attachment of the given kind
+// that is backed by a file
+func attachmentOfKind(item *Item, kind string) *Attachment {
+    for _, att := range item.Attachments {
+        if att.Path != "" && att.Kind() == kind {
+            return att
+        }
+    }
+    return nil
+}
+
+// Exists reports whether the attachment's file is present on disk
+func (a *Attachment) Exists() bool {
+    if a.Path == "" {
+        return false
+    }
+    _, err := os.Stat(a.Path)
+    return err == nil
+}
+
+// Note represents a child note of a top-level item
+type Note struct {
+    StableID string
+    Title    string
+    HTML     string
+}
+
+const attachmentsQuery = `
+    SELECT
+        COALESCE(ia.parentItemID, ia.itemID),
+        child.key,
+        COALESCE(idv.value, '') as title,
+        ia.linkMode,
+        COALESCE(ia.contentType, ''),
+        COALESCE(ia.path, ''),
+        COALESCE(ia.storageHash, '')
+    FROM itemAttachments ia
+    JOIN items child ON ia.itemID = child.itemID
+    LEFT JOIN itemData id ON child.itemID = id.itemID
+        AND id.fieldID = (SELECT fieldID FROM fields WHERE fieldName = 'title')
+    LEFT JOIN itemDataValues idv ON id.valueID = idv.valueID
+    WHERE COALESCE(ia.parentItemID, ia.itemID) IN (SELECT value FROM json_each(?))
+    ORDER BY COALESCE(ia.parentItemID, ia.itemID), child.dateAdded, child.key`
+
+const notesQuery = `
+    SELECT
+        child.key,
+        COALESCE(n.title, ''),
+        COALESCE(n.note, '')
+    FROM itemNotes n
+    JOIN items child ON n.itemID = child.itemID
+    WHERE n.parentItemID = ?
+    ORDER BY child.dateAdded, child.key`
+
+const childNotesQuery = `
+    SELECT
+        n.parentItemID,
+        child.key,
+        COALESCE(n.title, ''),
+        COALESCE(n.note, '')
+    FROM itemNotes n
+    JOIN items child ON n.itemID = child.itemID
+    WHERE n.parentItemID IN (SELECT value FROM json_each(?))
+    ORDER BY n.parentItemID, child.dateAdded, child.key`
+
+// attachmentPath resolves the on-disk location of an attachment, returning
+// an empty string for links and files outside of reach
+func (r *Repository) attachmentPath(stableID string, linkMode int, path string) string {
+    switch {
+    case strings.HasPrefix(path, "storage:"):
+        dir := filepath.Join(r.cfg.StoragePath, stableID)
+        name := strings.TrimPrefix(path, "storage:")
+        if name == "" && linkMode == linkModeImportedURL {
+            // snapshots without a recorded filename point at the folder
+            if snapshot, err := snapshotFile(dir); err == nil {
+                return snapshot
+            }
+        }
+        return filepath.Join(dir, name)
+    case linkMode == linkModeLinkedFile && filepath.IsAbs(path):
+        return path
+    default:
+        return ""
+    }
+}
+
+// snapshotFile finds the main HTML document of a web snapshot in dir
+func snapshotFile(dir string) (string, error) {
+    entries, err := os.ReadDir(dir)
+    if err != nil {
+        return "", fmt.Errorf("reading snapshot folder: %w", err)
+    }
+
+    for _, entry := range entries {
+        name := entry.Name()
+        if entry.IsDir() || strings.HasPrefix(name, ".") {
+            continue
+        }
+        switch strings.ToLower(filepath.Ext(name)) {
+        case ".html", ".htm", ".xhtml":
+            return filepath.Join(dir, name), nil
+        }
+    }
+    return "", fmt.Errorf("no html file in %s", dir)
+}
+
+// Attachments retrieves the attachments of the items with the given IDs
+// in a single query, keyed by item ID. A standalone attachment is listed
+// as its own attachment so that it opens like any other item.
+func (r *Repository) Attachments(itemIDs []int64) (map[int64][]*Attachment, error) {
+    rows, err := r.query(attachmentsQuery, idList(itemIDs))
+    if err != nil {
+        return nil, fmt.Errorf("executing query: %w", err)
+    }
+    defer rows.Close()
+
+    attachments := make(map[int64][]*Attachment)
+    for rows.Next() {
+        var itemID int64
+        var att Attachment
+        if err := rows.Scan(
+            &itemID,
+            &att.StableID,
+            &att.Title,
+            &att.LinkMode,
+            &att.ContentType,
+            &att.Path,
+            &att.StorageHash,
+        ); err != nil {
+            return nil, fmt.Errorf("scanning row: %w", err)
+        }
+        att.Path = r.attachmentPath(att.StableID, att.LinkMode, att.Path)
+        attachments[itemID] = append(attachments[itemID], &att)
+    }
+
+    if err = rows.Err(); err != nil {
+        return nil, fmt.Errorf("iterating rows: %w", err)
+    }
+
+    return attachments, nil
+}
+
+// Notes retrieves the child notes of the item with the given ID
+func (r *Repository) Notes(itemID int64) ([]*Note, error) {
+    rows, err := r.query(notesQuery, itemID)
+    if err != nil {
+        return nil, fmt.Errorf("executing query: %w", err)
+    }
+    defer rows.Close()
+
+    var notes []*Note
+    for rows.Next() {
+        var note Note
+        if err := rows.Scan(
+            &note.StableID,
+            &note.Title,
+            &note.HTML,
+        ); err != nil {
+            return nil, fmt.Errorf("scanning row: %w", err)
+        }
+        notes = append(notes, &note)
+    }
+
+    if err = rows.Err(); err != nil {
+        return nil, fmt.Errorf("iterating rows: %w", err)
+    }
+
+    return notes, nil
+}
+
+// ChildNotes retrieves the child notes of the items with the given IDs in
+// a single query, keyed by item ID
+func (r *Repository) ChildNotes(itemIDs []int64) (map[int64][]*Note, error) {
+    rows, err := r.query(childNotesQuery, idList(itemIDs))
+    if err != nil {
+        return nil, fmt.Errorf("executing query: %w", err)
+    }
+    defer rows.Close()
+
+    notes := make(map[int64][]*Note)
+    for rows.Next() {
+        var itemID int64
+        var note Note
+        if err := rows.Scan(
+            &itemID,
+            &note.StableID,
+            &note.Title,
+            &note.HTML,
+        ); err != nil {
+            return nil, fmt.Errorf("scanning row: %w", err)
+        }
+        notes[itemID] = append(notes[itemID], &note)
+    }
+
+    if err = rows.Err(); err != nil {
+        return nil, fmt.Errorf("iterating rows: %w", err)
+    }
+
+    return notes, nil
+}
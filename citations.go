@@ -0,0 +1,295 @@
+package main
+
+import (
+    "fmt"
+    "net/url"
+    "strings"
+)
+
+// Citation directions
+const (
+    citedBy    = "cited-by"
+    references = "references"
+)
+
+// Citation is a work citing or cited by an item
+type Citation struct {
+    Direction string `json:"direction"`
+    Title     string `json:"title"`
+    Year      int    `json:"year,omitempty"`
+    DOI       string `json:"doi,omitempty"`
+    // Key is the stable ID of the work when it is in the local library
+    Key string `json:"key,omitempty"`
+}
+
+const doiIndexQuery = `
+    SELECT i.key, idv.value
+    FROM items i
+    JOIN itemData id ON i.itemID = id.itemID
+    JOIN itemDataValues idv ON id.valueID = idv.valueID
+    WHERE id.fieldID = (SELECT fieldID FROM fields WHERE fieldName = 'DOI')`
+
+// DOIIndex maps the normalized DOIs of the selected library to item keys
+func (r *Repository) DOIIndex() (map[string]string, error) {
+    query := doiIndexQuery
+    conditions, args := r.scopeConditions()
+    for _, condition := range conditions {
+        query += " AND " + condition
+    }
+
+    rows, err := r.query(query, args...)
+    if err != nil {
+        return nil, fmt.Errorf("executing query: %w", err)
+    }
+    defer rows.Close()
+
+    index := make(map[string]string)
+    for rows.Next() {
+        var key, doi string
+        if err := rows.Scan(&key, &doi); err != nil {
+            return nil, fmt.Errorf("scanning row: %w", err)
+        }
+        index[normalizeDOI(doi)] = key
+    }
+
+    if err = rows.Err(); err != nil {
+        return nil, fmt.Errorf("iterating rows: %w", err)
+    }
+
+    return index, nil
+}
+
+// openAlexWork is the part of an OpenAlex work record used here
+type openAlexWork struct {
+    ID              string   `json:"id"`
+    DOI             string   `json:"doi"`
+    DisplayName     string   `json:"display_name"`
+    PublicationYear int      `json:"publication_year"`
+    ReferencedWorks []string `json:"referenced_works"`
+}
+
+// openAlexBatch is the number of IDs OpenAlex accepts in one filter
+const openAlexBatch = 50
+
+// openAlexURL builds an OpenAlex API URL, adding the polite pool address
+func (c *CLI) openAlexURL(path string, query url.Values) string {
+    if query == nil {
+        query = url.Values{}
+    }
+    if c.cfg.Email != "" {
+        query.Set("mailto", c.cfg.Email)
+    }
+    u := strings.TrimSuffix(c.cfg.OpenAlexURL, "/") + path
+    if len(query) > 0 {
+        u += "?" + query.Encode()
+    }
+    return u
+}
+
+// openAlexList fetches every work matching an OpenAlex filter
+func (c *CLI) openAlexList(filter string) ([]openAlexWork, error) {
+    var works []openAlexWork
+    cursor := "*"
+    for cursor != "" {
+        var resp struct {
+            Results []openAlexWork `json:"results"`
+            Meta    struct {
+                NextCursor string `json:"next_cursor"`
+            } `json:"meta"`
+        }
+        query := url.Values{"filter": {filter}, "per-page": {"200"}, "cursor": {cursor}}
+        if err := c.getJSON(c.openAlexURL("/works", query), &resp); err != nil {
+            return nil, err
+        }
+        works = append(works, resp.Results...)
+        cursor = resp.Meta.NextCursor
+        if len(resp.Results) == 0 {
+            break
+        }
+    }
+    return works, nil
+}
+
+// openAlexCitations lists the works citing and cited by the work with doi
+func (c *CLI) openAlexCitations(doi string, directions []string) ([]Citation, error) {
+    var work openAlexWork
+    if err := c.getJSON(c.openAlexURL("/works/doi:"+doi, nil), &work); err != nil {
+        return nil, fmt.Errorf("looking up DOI: %w", err)
+    }
+    id := strings.TrimPrefix(work.ID, "https://openalex.org/")
+
+    var citations []Citation
+    add := func(direction string, works []openAlexWork) {
+        for _, w := range works {
+            citations = append(citations, Citation{
+                Direction: direction,
+                Title:     w.DisplayName,
+                Year:      w.PublicationYear,
+                DOI:       normalizeDOI(w.DOI),
+            })
+        }
+    }
+
+    for _, direction := range directions {
+        switch direction {
+        case citedBy:
+            works, err := c.openAlexList("cites:" + id)
+            if err != nil {
+                return nil, fmt.Errorf("listing citing works: %w", err)
+            }
+            add(citedBy, works)
+        case references:
+            for start := 0; start < len(work.ReferencedWorks); start += openAlexBatch {
+                end := min(start+openAlexBatch, len(work.ReferencedWorks))
+                var ids []string
+                for _, ref := range work.ReferencedWorks[start:end] {
+                    ids = append(ids, strings.TrimPrefix(ref, "https://openalex.org/"))
+                }
+                works, err := c.openAlexList("openalex_id:" + strings.Join(ids, "|"))
+                if err != nil {
+                    return nil, fmt.Errorf("listing referenced works: %w", err)
+                }
+                add(references, works)
+            }
+        }
+    }
+    return citations, nil
+}
+
+// semanticScholarPaper is the part of a Semantic Scholar paper used here
+type semanticScholarPaper struct {
+    Title       string `json:"title"`
+    Year        int    `json:"year"`
+    ExternalIDs struct {
+        DOI string `json:"DOI"`
+    } `json:"externalIds"`
+}
+
+// semanticScholarCitations lists the works citing and cited by the work
+// with doi
+func (c *CLI) semanticScholarCitations(doi string, directions []string) ([]Citation, error) {
+    var citations []Citation
+    for _, direction := range directions {
+        endpoint := "citations"
+        if direction == references {
+            endpoint = "references"
+        }
+        const limit = 1000
+        for offset := 0; ; offset += limit {
+            var resp struct {
+                Next int `json:"next"`
+                Data []struct {
+                    CitingPaper *semanticScholarPaper `json:"citingPaper"`
+                    CitedPaper  *semanticScholarPaper `json:"citedPaper"`
+                } `json:"data"`
+            }
+            query := url.Values{
+                "fields": {"title,year,externalIds"},
+                "limit":  {fmt.Sprint(limit)},
+                "offset": {fmt.Sprint(offset)},
+            }
+            u := fmt.Sprintf("%s/graph/v1/paper/DOI:%s/%s?%s",
+                strings.TrimSuffix(c.cfg.SemanticScholarURL, "/"), doi, endpoint, query.Encode())
+            if err := c.getJSON(u, &resp); err != nil {
+                return nil, fmt.Errorf("listing %s: %w", endpoint, err)
+            }
+            for _, entry := range resp.Data {
+                paper := entry.CitingPaper
+                if direction == references {
+                    paper = entry.CitedPaper
+                }
+                if paper == nil {
+                    continue
+                }
+                citations = append(citations, Citation{
+                    Direction: direction,
+                    Title:     paper.Title,
+                    Year:      paper.Year,
+                    DOI:       normalizeDOI(paper.ExternalIDs.DOI),
+                })
+            }
+            if resp.Next == 0 {
+                break
+            }
+        }
+    }
+    return citations, nil
+}
+
+// Citations looks up the works citing and cited by an item through
+// OpenAlex or Semantic Scholar and marks those in the local library
+func (c *CLI) Citations(stableID, source string, directions []string) ([]Citation, error) {
+    item, err := c.repo.GetByStableID(stableID)
+    if err != nil {
+        return nil, fmt.Errorf("getting item: %w", err)
+    }
+    doi, err := c.repo.Field(item.ID, "DOI")
+    if err != nil {
+        return nil, err
+    }
+    if doi == "" {
+        return nil, fmt.Errorf("item %s has no DOI", stableID)
+    }
+    doi = normalizeDOI(doi)
+
+    var citations []Citation
+    switch source {
+    case "openalex":
+        citations, err = c.openAlexCitations(doi, directions)
+    case "s2", "semanticscholar":
+        citations, err = c.semanticScholarCitations(doi, directions)
+    default:
+        return nil, fmt.Errorf("unknown citation source: %s", source)
+    }
+    if err != nil {
+        return nil, err
+    }
+
+    index, err := c.repo.DOIIndex()
+    if err != nil {
+        return nil, err
+    }
+    for i := range citations {
+        if citations[i].DOI != "" {
+            citations[i].Key = index[citations[i].DOI]
+        }
+    }
+    return citations, nil
+}
+
+// PrintCitations prints citing and cited works, flagging the ones already
+// in the library with their stable ID
+func (c *CLI) PrintCitations(stableID, source string, directions []string, localOnly bool, format string) error {
+    citations, err := c.Citations(stableID, source, directions)
+    if err != nil {
+        return err
+    }
+    if localOnly {
+        var local []Citation
+        for _, citation := range citations {
+            if citation.Key != "" {
+                local = append(local, citation)
+            }
+        }
+        citations = local
+    }
+
+    if format == "json" {
+        if citations == nil {
+            citations = []Citation{}
+        }
+        return writeJSON(citations)
+    }
+    for _, citation := range citations {
+        key := "-"
+        if citation.Key != "" {
+            key = citation.Key
+        }
+        year := ""
+        if citation.Year != 0 {
+            year = fmt.Sprint(citation.Year)
+        }
+        fmt.Printf("%-10s\t%-8s\t%4s\t%s\n", citation.Direction, key, year, citation.Title)
+    }
+    return nil
+}
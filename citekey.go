@@ -0,0 +1,153 @@
+package main
+
+import (
+    "fmt"
+    "regexp"
+    "slices"
+    "strings"
+    "unicode"
+)
+
+// yearPattern finds the year in Zotero's free-form date field
+var yearPattern = regexp.MustCompile(`\b\d{4}\b`)
+
+// citationKeyStopWords are skipped when picking the title word of a
+// generated citation key
+var citationKeyStopWords = map[string]bool{
+    "a": true, "an": true, "the": true, "on": true, "of": true, "in": true,
+    "and": true, "for": true, "to": true, "with": true, "what": true, "do": true,
+}
+
+// keyPart reduces text to lowercase ASCII letters and digits
+func keyPart(s string) string {
+    var b strings.Builder
+    for _, r := range fold(s) {
+        if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+            b.WriteRune(r)
+        }
+    }
+    return b.String()
+}
+
+// generatedCitationKey builds a key such as "sun2020building" from the
+// first creator, the year and the first significant title word
+func generatedCitationKey(fields map[string]string, creators []Creator) string {
+    key := "anon"
+    if len(creators) > 0 {
+        if name := keyPart(creators[0].LastName); name != "" {
+            key = name
+        }
+    }
+    key += yearPattern.FindString(fields["date"])
+    for _, word := range strings.Fields(fields["title"]) {
+        if part := keyPart(word); part != "" && !citationKeyStopWords[part] {
+            key += part
+            break
+        }
+    }
+    return key
+}
+
+// explicitCitationKey returns the citation key set on an item, either in
+// Zotero's own field or on a "Citation Key:" line of extra
+func explicitCitationKey(fields map[string]string) string {
+    if key := fields["citationKey"]; key != "" {
+        return key
+    }
+    return extraValue(fields["extra"], citationKeyField)
+}
+
+// CitationKey returns the citation key of an item, as bib and the
+// exports would write it
+func (r *Repository) CitationKey(stableID string) (string, error) {
+    item, err := r.GetByStableID(stableID)
+    if err != nil {
+        return "", fmt.Errorf("getting item: %w", err)
+    }
+    if key := explicitCitationKey(item.Fields); key != "" {
+        return key, nil
+    }
+
+    // generated keys depend on the rest of the library through their
+    // clash suffixes
+    index, err := r.citationKeyIndex()
+    if err != nil {
+        return "", err
+    }
+    for key, indexed := range index {
+        if indexed.Key == stableID {
+            return key, nil
+        }
+    }
+    return "", fmt.Errorf("citation key %w for item: %s", errNotFound, stableID)
+}
+
+// citationKeyIndex maps the citation key of every item in the selected
+// library to its full record. Items without an explicit key get a
+// generated one; clashes among generated keys are told apart with a
+// letter suffix, in stable ID order so that keys do not change between
+// runs. The records carry only what the keys and the bibliographies need:
+// the item's key, type, dates, fields and creators.
+func (r *Repository) citationKeyIndex() (map[string]DumpItem, error) {
+    items, err := r.scanItems(Filter{})
+    if err != nil {
+        return nil, fmt.Errorf("listing items: %w", err)
+    }
+    ids := make([]int64, len(items))
+    for i, item := range items {
+        ids[i] = item.ID
+    }
+    fields, err := r.ItemFields(ids)
+    if err != nil {
+        return nil, err
+    }
+    creators, err := r.Creators(ids)
+    if err != nil {
+        return nil, err
+    }
+
+    index := make(map[string]DumpItem)
+    var generated []DumpItem
+    for _, row := range items {
+        item := DumpItem{
+            LibraryID:    row.LibraryID,
+            Key:          row.StableID,
+            ItemType:     row.ItemType,
+            DateAdded:    row.DateAdded,
+            DateModified: row.DateModified,
+            Fields:       fields[row.ID],
+            Creators:     creators[row.ID],
+        }
+        if key := explicitCitationKey(item.Fields); key != "" {
+            index[key] = item
+        } else {
+            generated = append(generated, item)
+        }
+    }
+    slices.SortFunc(generated, func(a, b DumpItem) int {
+        return strings.Compare(a.Key, b.Key)
+    })
+    for _, item := range generated {
+        base := generatedCitationKey(item.Fields, item.Creators)
+        key := base
+        for suffix := 'a'; index[key].Key != ""; suffix++ {
+            key = fmt.Sprintf("%s%c", base, suffix)
+        }
+        index[key] = item
+    }
+    return index, nil
+}
+
+// citationKeysByStableID maps the stable ID of every item in the selected
+// library to its citation key
+func (r *Repository) citationKeysByStableID() (map[string]string, error) {
+    index, err := r.citationKeyIndex()
+    if err != nil {
+        return nil, err
+    }
+    keys := make(map[string]string, len(index))
+    for key, item := range index {
+        keys[item.Key] = key
+    }
+    return keys, nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import "testing"
+
+func TestGeneratedCitationKey(t *testing.T) {
+    tests := []struct {
+        fields   map[string]string
+        creators []Creator
+        key      string
+    }{
+        {map[string]string{"title": "Building real-time collaboration applications", "date": "2020-05-01"},
+            []Creator{{FirstName: "Chengzheng", LastName: "Sun"}, {LastName: "Ng"}}, "sun2020building"},
+        // stop words and punctuation are skipped
+        {map[string]string{"title": `What do you mean by "Event-Driven"?`, "date": "February 7, 2017"},
+            []Creator{{FirstName: "Martin", LastName: "Fowler"}}, "fowler2017you"},
+        {map[string]string{"title": "The Art of Computer Programming", "date": "1968"},
+            []Creator{{LastName: "Knuth"}}, "knuth1968art"},
+        {map[string]string{"title": "Über Bücher"}, []Creator{{LastName: "Müller-Lüdenscheidt"}}, "mullerludenscheidtuber"},
+        {map[string]string{"title": "Anonymous works", "date": "2001"}, nil, "anon2001anonymous"},
+        // a name with no ASCII letters left counts as none
+        {map[string]string{"title": "易经"}, []Creator{{LastName: "李"}}, "anon"},
+        {map[string]string{}, nil, "anon"},
+    }
+    for _, test := range tests {
+        if key := generatedCitationKey(test.fields, test.creators); key != test.key {
+            t.Errorf("generatedCitationKey(%v, %v) = %q, expected %q", test.fields, test.creators, key, test.key)
+        }
+    }
+}
+
+func TestExplicitCitationKey(t *testing.T) {
+    tests := []struct {
+        fields map[string]string
+        key    string
+    }{
+        {map[string]string{}, ""},
+        {map[string]string{"citationKey": "sun2020"}, "sun2020"},
+        {map[string]string{"extra": "PMID: 1\ncitation key:  sun2020 "}, "sun2020"},
+        // Zotero's own field wins over extra
+        {map[string]string{"citationKey": "own", "extra": "Citation Key: extra"}, "own"},
+    }
+    for _, test := range tests {
+        if key := explicitCitationKey(test.fields); key != test.key {
+            t.Errorf("explicitCitationKey(%v) = %q, expected %q", test.fields, key, test.key)
+        }
+    }
+}
+
+func TestCitationKeyIndex(t *testing.T) {
+    repo, _ := benchmarkRepository(t, 3)
+    // every item gets the same first author, so that the generated keys
+    // of the titles "Paper n" clash, and the last one an explicit key
+    // equal to them
+    if _, err := repo.db.Exec(`UPDATE itemCreators SET creatorID = 1 WHERE orderIndex = 0`); err != nil {
+        t.Fatal(err)
+    }
+    for _, statement := range []string{
+        `INSERT INTO itemDataValues VALUES (900, 'Citation Key: author1paper')`,
+        `INSERT INTO itemData VALUES (3, 6, 900)`,
+    } {
+        if _, err := repo.db.Exec(statement); err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    keys, err := repo.citationKeysByStableID()
+    if err != nil {
+        t.Fatal(err)
+    }
+    // clashes get suffixes in stable ID order, after explicit keys
+    expected := map[string]string{
+        "ITEM0001": "author1papera",
+        "ITEM0002": "author1paperb",
+        "ITEM0003": "author1paper",
+    }
+    for stableID, key := range expected {
+        if keys[stableID] != key {
+            t.Errorf("key of %s = %q, expected %q", stableID, keys[stableID], key)
+        }
+    }
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+    "fmt"
+)
+
+// Collection represents a Zotero collection
+type Collection struct {
+    ID        int64
+    Key       string
+    Name      string
+    ParentKey string
+}
+
+const collectionsQuery = `
+    SELECT c.collectionID, c.key, c.collectionName, COALESCE(p.key, '')
+    FROM collections c
+    LEFT JOIN collections p ON c.parentCollectionID = p.collectionID`
+
+const itemCollectionsQuery = `
+    SELECT c.key
+    FROM collectionItems ci
+    JOIN collections c ON ci.collectionID = c.collectionID
+    WHERE ci.itemID = ?
+    ORDER BY c.key`
+
+const collectionKeysQuery = `
+    SELECT ci.itemID, c.key
+    FROM collectionItems ci
+    JOIN collections c ON ci.collectionID = c.collectionID
+    WHERE ci.itemID IN (SELECT value FROM json_each(?))
+    ORDER BY ci.itemID, c.key`
+
+// itemCollectionPathsQuery resolves the slash separated path of every
+// collection holding one of a JSON list of items
+const itemCollectionPathsQuery = `
+    WITH RECURSIVE paths(collectionID, path) AS (
+        SELECT collectionID, collectionName FROM collections
+        WHERE parentCollectionID IS NULL
+        UNION ALL
+        SELECT c.collectionID, p.path || '/' || c.collectionName
+        FROM collections c
+        JOIN paths p ON c.parentCollectionID = p.collectionID
+    )
+    SELECT ci.itemID, p.path
+    FROM collectionItems ci
+    JOIN paths p ON ci.collectionID = p.collectionID
+    WHERE ci.itemID IN (SELECT value FROM json_each(?))
+    ORDER BY ci.itemID, p.path`
+
+// ListCollections retrieves the collections of the selected library,
+// ordered by key
+func (r *Repository) ListCollections() ([]*Collection, error) {
+    query := collectionsQuery
+    var args []interface{}
+    if r.libraryID != 0 {
+        query += " WHERE c.libraryID = ?"
+        args = append(args, r.libraryID)
+    }
+    query += " ORDER BY c.key, c.collectionID"
+
+    rows, err := r.query(query, args...)
+    if err != nil {
+        return nil, fmt.Errorf("executing query: %w", err)
+    }
+    defer rows.Close()
+
+    var collections []*Collection
+    for rows.Next() {
+        var collection Collection
+        if err := rows.Scan(
+            &collection.ID,
+            &collection.Key,
+            &collection.Name,
+            &collection.ParentKey,
+        ); err != nil {
+            return nil, fmt.Errorf("scanning row: %w", err)
+        }
+        collections = append(collections, &collection)
+    }
+
+    if err = rows.Err(); err != nil {
+        return nil, fmt.Errorf("iterating rows: %w", err)
+    }
+
+    return collections, nil
+}
+
+// ItemCollections retrieves the keys of the collections holding an item
+func (r *Repository) ItemCollections(itemID int64) ([]string, error) {
+    rows, err := r.query(itemCollectionsQuery, itemID)
+    if err != nil {
+        return nil, fmt.Errorf("executing query: %w", err)
+    }
+    defer rows.Close()
+
+    var keys []string
+    for rows.Next() {
+        var key string
+        if err := rows.Scan(&key); err != nil {
+            return nil, fmt.Errorf("scanning row: %w", err)
+        }
+        keys = append(keys, key)
+    }
+
+    if err = rows.Err(); err != nil {
+        return nil, fmt.Errorf("iterating rows: %w", err)
+    }
+
+    return keys, nil
+}
+
+// CollectionKeys retrieves the keys of the collections holding the items
+// with the given IDs in a single query, keyed by item ID and in key order
+func (r *Repository) CollectionKeys(itemIDs []int64) (map[int64][]string, error) {
+    rows, err := r.query(collectionKeysQuery, idList(itemIDs))
+    if err != nil {
+        return nil, fmt.Errorf("executing query: %w", err)
+    }
+    defer rows.Close()
+
+    keys := make(map[int64][]string)
+    for rows.Next() {
+        var itemID int64
+        var key string
+        if err := rows.Scan(&itemID, &key); err != nil {
+            return nil, fmt.Errorf("scanning row: %w", err)
+        }
+        keys[itemID] = append(keys[itemID], key)
+    }
+
+    if err = rows.Err(); err != nil {
+        return nil, fmt.Errorf("iterating rows: %w", err)
+    }
+
+    return keys, nil
+}
+
+// CollectionPaths retrieves the full paths of the collections holding the
+// items with the given IDs in a single query, keyed by item ID and in path
+// order
+func (r *Repository) CollectionPaths(itemIDs []int64) (map[int64][]string, error) {
+    rows, err := r.query(itemCollectionPathsQuery, idList(itemIDs))
+    if err != nil {
+        return nil, fmt.Errorf("executing query: %w", err)
+    }
+    defer rows.Close()
+
+    paths := make(map[int64][]string)
+    for rows.Next() {
+        var itemID int64
+        var path string
+        if err := rows.Scan(&itemID, &path); err != nil {
+            return nil, fmt.Errorf("scanning row: %w", err)
+        }
+        paths[itemID] = append(paths[itemID], path)
+    }
+
+    if err = rows.Err(); err != nil {
+        return nil, fmt.Errorf("iterating rows: %w", err)
+    }
+
+    return paths, nil
+}
+
+// findCollection resolves a collection key, name or slash separated path
+// in the selected library
+func (r *Repository) findCollection(nameOrKey string) (*Collection, error) {
+    collections, err := r.ListCollections()
+    if err != nil {
+        return nil, fmt.Errorf("listing collections: %w", err)
+    }
+    paths := collectionPaths(collections)
+    var matches []*Collection
+    for _, collection := range collections {
+        if collection.Key == nameOrKey {
+            return collection, nil
+        }
+        if fold(paths[collection.Key]) == fold(nameOrKey) || fold(collection.Name) == fold(nameOrKey) {
+            matches = append(matches, collection)
+        }
+    }
+    switch len(matches) {
+    case 0:
+        return nil, fmt.Errorf("collection %w: %s", errNotFound, nameOrKey)
+    case 1:
+        return matches[0], nil
+    default:
+        return nil, fmt.Errorf("%d collections are named %s, use the full path or key", len(matches), nameOrKey)
+    }
+}
+
+// collectionTree resolves a collection like findCollection and returns
+// its ID along with those of all its subcollections
+func (r *Repository) collectionTree(nameOrKey string) ([]int64, error) {
+    root, err := r.findCollection(nameOrKey)
+    if err != nil {
+        return nil, err
+    }
+    collections, err := r.ListCollections()
+    if err != nil {
+        return nil, fmt.Errorf("listing collections: %w", err)
+    }
+    children := make(map[string][]*Collection)
+    for _, collection := range collections {
+        children[collection.ParentKey] = append(children[collection.ParentKey], collection)
+    }
+    var ids []int64
+    pending := []*Collection{root}
+    for len(pending) > 0 {
+        collection := pending[0]
+        pending = pending[1:]
+        ids = append(ids, collection.ID)
+        pending = append(pending, children[collection.Key]...)
+    }
+    return ids, nil
+}
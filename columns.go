@@ -0,0 +1,174 @@
+package main
+
+import (
+    "fmt"
+    "os"
+    "slices"
+    "strconv"
+    "strings"
+    "unicode/utf8"
+)
+
+// listColumnWidths lists the columns of verbose listings with the width
+// they are padded and truncated to; 0 leaves the value untouched
+var listColumnWidths = map[string]int{
+    "key":         8,
+    "title":       25,
+    "tags":        15,
+    "year":        4,
+    "citekey":     20,
+    "size":        6,
+    "modified":    16,
+    "collections": 25,
+    "cited":       5,
+    "path":        0,
+}
+
+// defaultColumns is the verbose layout when -columns is not given
+var defaultColumns = []string{"key", "title", "tags", "path"}
+
+// statColumns are the file details -stat adds in front of the path
+var statColumns = []string{"size", "modified"}
+
+// statTimeLayout formats attachment modification times
+const statTimeLayout = "2006-01-02 15:04"
+
+// parseColumns splits a comma separated column list, rejecting unknown
+// names
+func parseColumns(spec string) ([]string, error) {
+    if spec == "" {
+        return defaultColumns, nil
+    }
+    var columns []string
+    for _, name := range strings.Split(spec, ",") {
+        name = strings.TrimSpace(name)
+        if _, ok := listColumnWidths[name]; !ok {
+            return nil, fmt.Errorf("unknown column %q, expected one of: key, title, year, tags, path, citekey, size, modified, collections", name)
+        }
+        columns = append(columns, name)
+    }
+    return columns, nil
+}
+
+// itemTable renders verbose listings in the selected columns
+type itemTable struct {
+    columns []string
+
+    // citeKeys maps stable IDs to generated citation keys, only loaded
+    // when the citekey column is shown
+    citeKeys map[string]string
+
+    // cited holds the local citation counts by stable ID, only loaded
+    // when the cited column is shown
+    cited map[string]int
+
+    // color paints colored tags in their Zotero color
+    color bool
+
+    // verify marks attachments missing from disk in the path column
+    verify bool
+}
+
+// newItemTable prepares the verbose layout requested by opts
+func (c *CLI) newItemTable(opts ListOptions) (*itemTable, error) {
+    columns, err := parseColumns(opts.Columns)
+    if err != nil {
+        return nil, err
+    }
+    if opts.Stat {
+        at := slices.Index(columns, "path")
+        if at < 0 {
+            at = len(columns)
+        }
+        for _, name := range slices.Backward(statColumns) {
+            if !slices.Contains(columns, name) {
+                columns = slices.Insert(slices.Clone(columns), at, name)
+            }
+        }
+    }
+    if opts.Cited && !slices.Contains(columns, "cited") {
+        columns = slices.Insert(slices.Clone(columns), 1, "cited")
+    }
+    table := &itemTable{columns: columns, color: useColor(), verify: opts.Verify}
+    if slices.Contains(columns, "citekey") {
+        table.citeKeys, err = c.repo.citationKeysByStableID()
+        if err != nil {
+            return nil, fmt.Errorf("resolving citation keys: %w", err)
+        }
+    }
+    if slices.Contains(columns, "cited") {
+        table.cited, err = c.repo.LocalCitations()
+        if err != nil {
+            return nil, fmt.Errorf("counting local citations: %w", err)
+        }
+    }
+    return table, nil
+}
+
+// row formats one line of the table for item, with att filling the path
+// and file columns; att is nil for items without stored files
+func (table *itemTable) row(item *Item, att *Attachment) string {
+    var info os.FileInfo
+    if att != nil && slices.ContainsFunc(table.columns, func(name string) bool {
+        return slices.Contains(statColumns, name)
+    }) {
+        info, _ = os.Stat(att.Path)
+    }
+
+    cells := make([]string, len(table.columns))
+    for i, name := range table.columns {
+        var value string
+        switch name {
+        case "key":
+            value = item.StableID
+        case "title":
+            value = markedTitle(item)
+        case "tags":
+            value = strings.Join(item.displayTags(), ",")
+        case "year":
+            value = item.Year
+        case "collections":
+            value = strings.Join(item.Collections, ",")
+        case "cited":
+            value = strconv.Itoa(table.cited[item.StableID])
+        case "citekey":
+            value = item.CitationKey
+            if value == "" {
+                value = table.citeKeys[item.StableID]
+            }
+        case "size":
+            if info != nil {
+                value = formatSize(info.Size(), true)
+            }
+        case "modified":
+            if info != nil {
+                value = info.ModTime().Format(statTimeLayout)
+            }
+        case "path":
+            if att != nil {
+                value = att.Path
+                if table.verify && !att.Exists() {
+                    value += " " + missingMarker
+                }
+            }
+        }
+
+        width := listColumnWidths[name]
+        visible := utf8.RuneCountInString(value)
+        if width > 0 && name != "key" {
+            if visible > width {
+                visible = width - 3
+            }
+            value = truncateString(value, width)
+        }
+        // the last column is not padded to avoid trailing blanks
+        if width > 0 && i < len(table.columns)-1 {
+            value = fmt.Sprintf("%-*s", width, value)
+        }
+        if name == "tags" && table.color {
+            value = colorTagCell(value, visible, item.displayTags(), item.TagColors)
+        }
+        cells[i] = value
+    }
+    return strings.Join(cells, "\t")
+}
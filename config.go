@@ -0,0 +1,84 @@
+package main
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "log/slog"
+    "os"
+    "path/filepath"
+    "strings"
+)
+
+// defaultConfig returns the configuration used when no config file exists
+func defaultConfig() Config {
+    return Config{
+        DBPath:             "/Users/username/data/zotero/zotero.sqlite",
+        StoragePath:        "/Users/username/data/zotero/storage/",
+        Version:            "1.0",
+        BusyTimeout:        defaultBusyTimeout,
+        APIURL:             "https://api.zotero.org",
+        CrossrefURL:        "https://api.crossref.org",
+        OpenAlexURL:        "https://api.openalex.org",
+        SemanticScholarURL: "https://api.semanticscholar.org",
+        UnpaywallURL:       "https://api.unpaywall.org",
+        Connector:          ConnectorConfig{URL: "http://127.0.0.1:23119"},
+        Embeddings: EmbeddingsConfig{
+            URL:   "http://127.0.0.1:11434/v1",
+            Model: "nomic-embed-text",
+        },
+        StatusTags: map[string]string{
+            "toread":  "toread",
+            "reading": "reading",
+            "read":    "read",
+        },
+    }
+}
+
+// defaultConfigPath returns the location of the config file when -config
+// is not given
+func defaultConfigPath() string {
+    dir, err := os.UserConfigDir()
+    if err != nil {
+        return ""
+    }
+    return filepath.Join(dir, "zotero-fetch", "config.json")
+}
+
+// loadConfig overlays the JSON config file at path on top of the defaults.
+// A missing file is only an error when it was asked for explicitly.
+func loadConfig(path string, explicit bool) (Config, error) {
+    cfg := defaultConfig()
+    if path == "" {
+        return cfg, nil
+    }
+
+    data, err := os.ReadFile(path)
+    if errors.Is(err, os.ErrNotExist) && !explicit {
+        return cfg, nil
+    }
+    if err != nil {
+        return cfg, fmt.Errorf("reading config: %w", err)
+    }
+
+    if err := json.Unmarshal(data, &cfg); err != nil {
+        return cfg, fmt.Errorf("parsing config %s: %w", path, err)
+    }
+    slog.Debug("loaded config", "path", path)
+    return cfg, nil
+}
+
+// useProfile overlays the named profile on the configuration. Profiles
+// hold any config keys, typically dbPath, storagePath and apiKey.
+func (cfg *Config) useProfile(name string) error {
+    profile, ok := cfg.Profiles[name]
+    if !ok {
+        names := sortedKeys(cfg.Profiles)
+        return fmt.Errorf("%w: unknown profile %q, configured: %s", errConfig, name, strings.Join(names, ", "))
+    }
+    if err := json.Unmarshal(profile, cfg); err != nil {
+        return fmt.Errorf("%w: parsing profile %s: %w", errConfig, name, err)
+    }
+    cfg.Profile = name
+    return nil
+}
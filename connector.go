@@ -0,0 +1,296 @@
+package main
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "html"
+    "io"
+    "log/slog"
+    "net/http"
+    "net/url"
+    "strings"
+    "time"
+)
+
+// ConnectorConfig locates the HTTP server of the running Zotero client.
+// Editing existing items needs the Debug Bridge plugin, whose password is
+// set as Token.
+type ConnectorConfig struct {
+    URL   string `json:"url"`
+    Token string `json:"token"`
+}
+
+// connectorClient talks to the local Zotero client, which answers
+// immediately or not at all
+var connectorClient = &http.Client{Timeout: 30 * time.Second}
+
+// pingConnector checks that the Zotero client is running
+func (c *CLI) pingConnector() error {
+    resp, err := connectorClient.Get(strings.TrimSuffix(c.cfg.Connector.URL, "/") + "/connector/ping")
+    if err != nil {
+        return fmt.Errorf("%w: %w", errZoteroNotRunning, err)
+    }
+    resp.Body.Close()
+    if resp.StatusCode != http.StatusOK {
+        return fmt.Errorf("%w: ping answered %s", errZoteroNotRunning, resp.Status)
+    }
+    return nil
+}
+
+// connectorExecute runs script inside the Zotero client, where params is
+// available as a JSON value, and decodes what the script returns into
+// result. Changes made this way go through Zotero's own data layer, so
+// they are synced and never race with the client's database writes.
+func (c *CLI) connectorExecute(script string, params, result interface{}) error {
+    if c.cfg.Connector.Token == "" {
+        return fmt.Errorf("%w: connector.token is not set", errConfig)
+    }
+    if err := c.pingConnector(); err != nil {
+        return err
+    }
+    encoded, err := json.Marshal(params)
+    if err != nil {
+        return fmt.Errorf("encoding parameters: %w", err)
+    }
+    body := "const params = " + string(encoded) + ";\n" + script
+
+    u := strings.TrimSuffix(c.cfg.Connector.URL, "/") + "/debug-bridge/execute?" +
+        url.Values{"password": {c.cfg.Connector.Token}}.Encode()
+    req, err := http.NewRequest(http.MethodPost, u, strings.NewReader(body))
+    if err != nil {
+        return fmt.Errorf("creating request: %w", err)
+    }
+    req.Header.Set("Content-Type", "application/javascript")
+
+    start := time.Now()
+    resp, err := connectorClient.Do(req)
+    if err != nil {
+        return fmt.Errorf("%w: %w", errZoteroNotRunning, err)
+    }
+    defer resp.Body.Close()
+    slog.Debug("connector request", "status", resp.StatusCode, "duration", time.Since(start))
+
+    data, err := io.ReadAll(resp.Body)
+    if err != nil {
+        return fmt.Errorf("reading response: %w", err)
+    }
+    if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+        return fmt.Errorf("zotero refused the change: %s: %s", resp.Status, bytes.TrimSpace(data))
+    }
+    if result == nil || len(bytes.TrimSpace(data)) == 0 {
+        return nil
+    }
+    if err := json.Unmarshal(data, result); err != nil {
+        return fmt.Errorf("decoding response: %w", err)
+    }
+    return nil
+}
+
+// connectorItemScript loads the item named by params.libraryID and
+// params.key into `item`
+const connectorItemScript = `
+const item = await Zotero.Items.getByLibraryAndKeyAsync(params.libraryID, params.key);
+if (!item) throw new Error("item not found: " + params.key);
+`
+
+const tagEditScript = connectorItemScript + `
+for (const tag of params.remove || []) item.removeTag(tag);
+for (const tag of params.add || []) item.addTag(tag);
+await item.saveTx();
+return item.getTags().map(t => t.tag);
+`
+
+const collectionAddScript = connectorItemScript + `
+const collection = Zotero.Collections.getByLibraryAndKey(params.libraryID, params.collection);
+if (!collection) throw new Error("collection not found: " + params.collection);
+item.addToCollection(collection.id);
+await item.saveTx();
+return collection.key;
+`
+
+const collectionMoveScript = connectorItemScript + `
+const collection = Zotero.Collections.getByLibraryAndKey(params.libraryID, params.collection);
+if (!collection) throw new Error("collection not found: " + params.collection);
+item.setCollections([collection.id]);
+await item.saveTx();
+return collection.key;
+`
+
+const collectionRemoveScript = connectorItemScript + `
+const collection = Zotero.Collections.getByLibraryAndKey(params.libraryID, params.collection);
+if (!collection) throw new Error("collection not found: " + params.collection);
+item.removeFromCollection(collection.id);
+await item.saveTx();
+return collection.key;
+`
+
+const setFieldScript = connectorItemScript + `
+item.setField(params.field, params.value);
+await item.saveTx();
+return item.getField(params.field);
+`
+
+const noteCreateScript = connectorItemScript + `
+const note = new Zotero.Item("note");
+note.libraryID = params.libraryID;
+note.parentKey = params.key;
+note.setNote(params.html);
+await note.saveTx();
+return note.key;
+`
+
+const itemLibraryQuery = `SELECT libraryID FROM items WHERE itemID = ?`
+
+// connectorParams returns the parameters identifying an item to the
+// connector scripts
+func (c *CLI) connectorParams(stableID string) (map[string]interface{}, error) {
+    item, err := c.repo.GetByStableID(stableID)
+    if err != nil {
+        return nil, fmt.Errorf("getting item: %w", err)
+    }
+    var libraryID int64
+    if err := c.repo.queryRow(itemLibraryQuery, item.ID).Scan(&libraryID); err != nil {
+        return nil, fmt.Errorf("fetching library: %w", err)
+    }
+    return map[string]interface{}{"libraryID": libraryID, "key": stableID}, nil
+}
+
+// editTags removes and then adds tags of an item through the Zotero
+// client, returning the resulting tags
+func (c *CLI) editTags(stableID string, add, remove []string) ([]string, error) {
+    params, err := c.connectorParams(stableID)
+    if err != nil {
+        return nil, err
+    }
+    params["add"] = add
+    params["remove"] = remove
+
+    var result []string
+    if err := c.connectorExecute(tagEditScript, params, &result); err != nil {
+        return nil, err
+    }
+    return result, nil
+}
+
+// TagItem adds or, when remove is set, removes tags of an item through the
+// Zotero client and prints the resulting tags
+func (c *CLI) TagItem(stableID string, tags []string, remove bool) error {
+    add, drop := tags, []string(nil)
+    if remove {
+        add, drop = nil, tags
+    }
+    if c.pretendTags(stableID, add, drop) {
+        return nil
+    }
+    result, err := c.editTags(stableID, add, drop)
+    if err != nil {
+        return err
+    }
+    fmt.Println(strings.Join(result, ", "))
+    return nil
+}
+
+// AddToCollection files an item into a collection through the Zotero
+// client. With move the item leaves every other collection.
+func (c *CLI) AddToCollection(stableID, collectionName string, move bool) error {
+    params, err := c.connectorParams(stableID)
+    if err != nil {
+        return err
+    }
+    collection, err := c.repo.findCollection(collectionName)
+    if err != nil {
+        return err
+    }
+    if c.pretendFiling(stableID, collection.Name, move) {
+        return nil
+    }
+    params["collection"] = collection.Key
+    script := collectionAddScript
+    if move {
+        script = collectionMoveScript
+    }
+    return c.connectorExecute(script, params, nil)
+}
+
+// RemoveFromCollection takes an item out of a collection through the
+// Zotero client, leaving it in the library
+func (c *CLI) RemoveFromCollection(stableID, collectionName string) error {
+    params, err := c.connectorParams(stableID)
+    if err != nil {
+        return err
+    }
+    collection, err := c.repo.findCollection(collectionName)
+    if err != nil {
+        return err
+    }
+    if c.pretend("remove %s from collection %s", stableID, collection.Name) {
+        return nil
+    }
+    params["collection"] = collection.Key
+    return c.connectorExecute(collectionRemoveScript, params, nil)
+}
+
+// SetField sets a metadata field of an item through the Zotero client
+func (c *CLI) SetField(stableID, field, value string) error {
+    params, err := c.connectorParams(stableID)
+    if err != nil {
+        return err
+    }
+    if c.pretend("set %s of %s to %q", field, stableID, value) {
+        return nil
+    }
+    params["field"] = field
+    params["value"] = value
+    return c.connectorExecute(setFieldScript, params, nil)
+}
+
+// SetCitationKey stores a citation key in the extra field through the
+// Zotero client
+func (c *CLI) SetCitationKey(stableID, key string) error {
+    if c.pretend("set the citation key of %s to %s", stableID, key) {
+        return nil
+    }
+    item, err := c.repo.GetByStableID(stableID)
+    if err != nil {
+        return fmt.Errorf("getting item: %w", err)
+    }
+    extra, err := c.repo.Field(item.ID, "extra")
+    if err != nil {
+        return err
+    }
+    return c.SetField(stableID, "extra", setExtraField(extra, citationKeyField, key))
+}
+
+// textToNoteHTML turns plain text into the paragraphs of a Zotero note
+func textToNoteHTML(text string) string {
+    var b strings.Builder
+    for _, paragraph := range strings.Split(strings.TrimSpace(text), "\n\n") {
+        lines := strings.Split(paragraph, "\n")
+        for i, line := range lines {
+            lines[i] = html.EscapeString(line)
+        }
+        b.WriteString("<p>" + strings.Join(lines, "<br>") + "</p>\n")
+    }
+    return b.String()
+}
+
+// CreateNote adds a child note with the given plain text to an item
+// through the Zotero client and prints the note's key
+func (c *CLI) CreateNote(stableID, text string) error {
+    params, err := c.connectorParams(stableID)
+    if err != nil {
+        return err
+    }
+    if c.pretend("add a note to %s", stableID) {
+        return nil
+    }
+    params["html"] = textToNoteHTML(text)
+
+    var key string
+    if err := c.connectorExecute(noteCreateScript, params, &key); err != nil {
+        return err
+    }
+    fmt.Println(key)
+    return nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+    "cmp"
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "regexp"
+    "slices"
+    "strings"
+)
+
+// Coverage statuses of a cited key
+const (
+    coverageOK         = "ok"
+    coverageNoPDF      = "no-pdf"
+    coveragePDFMissing = "pdf-missing"
+    coverageMissing    = "missing"
+)
+
+// latexCitationPattern finds the keys of LaTeX citation commands such as
+// \cite{a,b}, \citep[p.~3]{a} and biblatex's \autocite and \textcite
+var latexCitationPattern = regexp.MustCompile(`\\[A-Za-z]*cite[A-Za-z]*\*?\s*(?:\[[^\]]*\]\s*){0,2}\{([^}]*)\}`)
+
+// latexCommentPattern matches LaTeX comments, which may cite what the
+// document no longer does
+var latexCommentPattern = regexp.MustCompile(`(?m)(^|[^\\])%.*$`)
+
+// latexCitedKeys returns the citation keys used in a LaTeX document, in
+// order of first use
+func latexCitedKeys(tex string) []string {
+    tex = latexCommentPattern.ReplaceAllString(tex, "$1")
+    var keys []string
+    for _, match := range latexCitationPattern.FindAllStringSubmatch(tex, -1) {
+        for _, key := range strings.Split(match[1], ",") {
+            key = strings.TrimSpace(key)
+            // \nocite{*} cites the whole .bib, not an entry
+            if key != "" && key != "*" && !slices.Contains(keys, key) {
+                keys = append(keys, key)
+            }
+        }
+    }
+    return keys
+}
+
+// CoverageEntry reports on one key cited by a document
+type CoverageEntry struct {
+    CitationKey string   `json:"citekey"`
+    Status      string   `json:"status"`
+    Key         string   `json:"key,omitempty"`
+    Title       string   `json:"title,omitempty"`
+    Tags        []string `json:"tags,omitempty"`
+    Collections []string `json:"collections,omitempty"`
+}
+
+// Coverage reports, for every key cited by the Markdown or LaTeX document
+// at from, whether the library has the item, whether its PDF is on disk,
+// and the tags and collections the item is in. format is text or json.
+func (c *CLI) Coverage(from, format string) error {
+    data, err := os.ReadFile(from)
+    if err != nil {
+        return fmt.Errorf("reading document: %w", err)
+    }
+    var keys []string
+    switch strings.ToLower(filepath.Ext(from)) {
+    case ".tex", ".ltx":
+        keys = latexCitedKeys(string(data))
+    default:
+        keys = citedKeys(string(data))
+    }
+
+    index, err := c.repo.citationKeyIndex()
+    if err != nil {
+        return err
+    }
+
+    entries := make([]CoverageEntry, 0, len(keys))
+    counts := make(map[string]int)
+    for _, key := range keys {
+        entry := CoverageEntry{CitationKey: key, Status: coverageMissing}
+        stableID := key
+        if item, ok := index[key]; ok {
+            stableID = item.Key
+        }
+        item, err := c.repo.GetByStableID(stableID)
+        switch {
+        case err == nil:
+            entry.Key = item.StableID
+            entry.Title = item.Title
+            entry.Tags = item.displayTags()
+            entry.Collections = item.Collections
+            pdf := attachmentOfKind(item, "pdf")
+            switch {
+            case pdf == nil:
+                entry.Status = coverageNoPDF
+            case !pdf.Exists():
+                entry.Status = coveragePDFMissing
+            default:
+                entry.Status = coverageOK
+            }
+        case !errors.Is(err, errNotFound):
+            return fmt.Errorf("getting item: %w", err)
+        }
+        counts[entry.Status]++
+        entries = append(entries, entry)
+    }
+
+    if format == "json" {
+        return writeJSON(entries)
+    }
+    for _, entry := range entries {
+        fmt.Printf("%s\t%s\t%s\t%s\t%s\n", entry.CitationKey, entry.Status,
+            cmp.Or(entry.Key, "-"), strings.Join(entry.Tags, ","), strings.Join(entry.Collections, ","))
+    }
+    fmt.Printf("%d cited: %d with PDF, %d without, %d with the PDF missing from disk, %d not in the library\n",
+        len(entries), counts[coverageOK], counts[coverageNoPDF], counts[coveragePDFMissing], counts[coverageMissing])
+    return nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+    "cmp"
+    "strconv"
+    "strings"
+)
+
+// cslTypes maps Zotero item types to CSL types; others become "document"
+var cslTypes = map[string]string{
+    "journalArticle":      "article-journal",
+    "magazineArticle":     "article-magazine",
+    "newspaperArticle":    "article-newspaper",
+    "blogPost":            "post-weblog",
+    "forumPost":           "post",
+    "book":                "book",
+    "bookSection":         "chapter",
+    "conferencePaper":     "paper-conference",
+    "encyclopediaArticle": "entry-encyclopedia",
+    "dictionaryEntry":     "entry-dictionary",
+    "thesis":              "thesis",
+    "report":              "report",
+    "webpage":             "webpage",
+    "manuscript":          "manuscript",
+    "patent":              "patent",
+    "case":                "legal_case",
+    "statute":             "legislation",
+    "film":                "motion_picture",
+    "videoRecording":      "motion_picture",
+    "audioRecording":      "song",
+    "podcast":             "broadcast",
+    "presentation":        "speech",
+    "interview":           "interview",
+    "letter":              "personal_communication",
+    "map":                 "map",
+    "artwork":             "graphic",
+    "computerProgram":     "software",
+    "preprint":            "article",
+    "dataset":             "dataset",
+}
+
+// cslCreatorTypes maps the Zotero creator roles CSL has name variables for
+var cslCreatorTypes = [][2]string{
+    {"author", "author"},
+    {"editor", "editor"},
+    {"translator", "translator"},
+    {"bookAuthor", "container-author"},
+    {"seriesEditor", "collection-editor"},
+}
+
+// cslFields maps Zotero fields to CSL variables; the first Zotero field
+// set wins for variables listed more than once
+var cslFields = [][2]string{
+    {"title", "title"},
+    {"publicationTitle", "container-title"},
+    {"proceedingsTitle", "container-title"},
+    {"bookTitle", "container-title"},
+    {"websiteTitle", "container-title"},
+    {"blogTitle", "container-title"},
+    {"encyclopediaTitle", "container-title"},
+    {"dictionaryTitle", "container-title"},
+    {"conferenceName", "event-title"},
+    {"volume", "volume"},
+    {"issue", "issue"},
+    {"pages", "page"},
+    {"edition", "edition"},
+    {"publisher", "publisher"},
+    {"university", "publisher"},
+    {"institution", "publisher"},
+    {"place", "publisher-place"},
+    {"reportNumber", "number"},
+    {"abstractNote", "abstract"},
+    {"language", "language"},
+    {"DOI", "DOI"},
+    {"ISBN", "ISBN"},
+    {"ISSN", "ISSN"},
+    {"url", "URL"},
+}
+
+// cslName is a CSL name: family and given parts, or a literal for names
+// Zotero keeps in a single field
+type cslName struct {
+    Family  string `json:"family,omitempty"`
+    Given   string `json:"given,omitempty"`
+    Literal string `json:"literal,omitempty"`
+}
+
+// cslDate is a CSL date, made of year, month and day as far as known
+type cslDate struct {
+    DateParts [][]int `json:"date-parts"`
+}
+
+// cslItem is a CSL-JSON item by variable name
+type cslItem map[string]interface{}
+
+// cslItemOf converts an item of the dump to CSL-JSON under the given id,
+// the item's citation key
+func cslItemOf(id string, item DumpItem) cslItem {
+    csl := cslItem{
+        "id":   id,
+        "type": cmp.Or(cslTypes[item.ItemType], "document"),
+    }
+    for _, mapping := range cslFields {
+        if _, set := csl[mapping[1]]; !set && item.Fields[mapping[0]] != "" {
+            csl[mapping[1]] = item.Fields[mapping[0]]
+        }
+    }
+
+    for _, role := range cslCreatorTypes {
+        var names []cslName
+        for _, creator := range item.Creators {
+            switch {
+            case creator.CreatorType != role[0]:
+            case creator.FirstName == "":
+                names = append(names, cslName{Literal: creator.LastName})
+            default:
+                names = append(names, cslName{Family: creator.LastName, Given: creator.FirstName})
+            }
+        }
+        if len(names) > 0 {
+            csl[role[1]] = names
+        }
+    }
+
+    if date := hayagrivaDate(item.Fields["date"]); date != "" {
+        var parts []int
+        for _, part := range strings.Split(date, "-") {
+            n, _ := strconv.Atoi(part)
+            parts = append(parts, n)
+        }
+        csl["issued"] = cslDate{DateParts: [][]int{parts}}
+    }
+    return csl
+}
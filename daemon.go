@@ -0,0 +1,144 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "log/slog"
+    "net"
+    "net/rpc"
+    "net/rpc/jsonrpc"
+    "os"
+    "os/signal"
+    "path/filepath"
+    "syscall"
+)
+
+// Service exposes repository lookups over JSON-RPC. Methods are addressed
+// as "Zotero.<Method>".
+type Service struct {
+    cli   *CLI
+    index *itemIndex
+}
+
+// rpcOptions includes every optional field in RPC replies, leaving it to
+// clients to pick what they need
+var rpcOptions = ListOptions{Abstract: true}
+
+// KeyArgs identifies a single item by its stable ID
+type KeyArgs struct {
+    StableID string `json:"stableID"`
+}
+
+// List returns the items matching the filter
+func (s *Service) List(filter Filter, reply *[]JSONItem) error {
+    encoded, err := s.index.list(filter)
+    if err != nil {
+        return err
+    }
+    *reply = encoded
+    return nil
+}
+
+// Get returns a single item by stable ID
+func (s *Service) Get(args KeyArgs, reply *JSONItem) error {
+    encoded, err := s.index.get(args.StableID)
+    if err != nil {
+        return fmt.Errorf("getting item: %w", err)
+    }
+    *reply = encoded
+    return nil
+}
+
+// Reference returns the reference link for an item
+func (s *Service) Reference(args KeyArgs, reply *string) error {
+    ref, err := s.cli.reference(args.StableID)
+    if err != nil {
+        return err
+    }
+    *reply = ref
+    return nil
+}
+
+// defaultSocketPath returns the socket location used when none is given
+func defaultSocketPath() string {
+    return filepath.Join(os.TempDir(), "zotero-fetch.sock")
+}
+
+// Serve keeps the database open and answers JSON-RPC requests on the unix
+// socket at path until interrupted. Items are held in memory from the
+// start and refreshed as Zotero changes them.
+func (c *CLI) Serve(path string) error {
+    server, err := c.rpcServer()
+    if err != nil {
+        return err
+    }
+
+    if err := removeStaleSocket(path); err != nil {
+        return err
+    }
+    listener, err := net.Listen("unix", path)
+    if err != nil {
+        return fmt.Errorf("listening on %s: %w", path, err)
+    }
+    if err := os.Chmod(path, 0600); err != nil {
+        listener.Close()
+        return fmt.Errorf("restricting socket permissions: %w", err)
+    }
+
+    signals := make(chan os.Signal, 1)
+    signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+    go func() {
+        <-signals
+        listener.Close()
+    }()
+
+    slog.Info("serving", "socket", path)
+    for {
+        conn, err := listener.Accept()
+        if errors.Is(err, net.ErrClosed) {
+            return nil
+        }
+        if err != nil {
+            return fmt.Errorf("accepting connection: %w", err)
+        }
+        go server.ServeCodec(jsonrpc.NewServerCodec(conn))
+    }
+}
+
+// rpcServer loads the item index and registers the service answering
+// requests from it
+func (c *CLI) rpcServer() (*rpc.Server, error) {
+    if err := c.cfg.checkHooks(); err != nil {
+        return nil, err
+    }
+    index, err := newItemIndex(c)
+    if err != nil {
+        return nil, err
+    }
+    if len(c.cfg.Hooks) > 0 {
+        index.added = c.runHooks
+        go index.watchHooks()
+    }
+    server := rpc.NewServer()
+    if err := server.RegisterName("Zotero", &Service{cli: c, index: index}); err != nil {
+        return nil, fmt.Errorf("registering service: %w", err)
+    }
+    return server, nil
+}
+
+// removeStaleSocket deletes a socket file left behind by a previous daemon,
+// refusing to touch one that is still accepting connections
+func removeStaleSocket(path string) error {
+    if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+        return nil
+    }
+
+    if conn, err := net.Dial("unix", path); err == nil {
+        conn.Close()
+        return fmt.Errorf("another daemon is listening on %s", path)
+    }
+    if err := os.Remove(path); err != nil {
+        return fmt.Errorf("removing stale socket: %w", err)
+    }
+    return nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+    "flag"
+    "fmt"
+    "sort"
+    "strings"
+)
+
+// flagDefaults holds the per-command flag defaults of the config file,
+// e.g. {"list": {"v": true}, "reference": {"format": "latex"}}
+var flagDefaults map[string]map[string]interface{}
+
+// flagPresets holds the named flag sets of the config file, given on the
+// command line as @name, e.g. {"thesis": {"collection": "Thesis"}}
+var flagPresets map[string]map[string]interface{}
+
+// expandPresets replaces every @name argument naming a preset with the
+// preset's flags, so that flags given after it override them. Other
+// arguments starting with @ are left alone.
+func expandPresets(args []string) []string {
+    var expanded []string
+    for _, arg := range args {
+        preset, ok := flagPresets[strings.TrimPrefix(arg, "@")]
+        if !strings.HasPrefix(arg, "@") || !ok {
+            expanded = append(expanded, arg)
+            continue
+        }
+        for _, name := range sortedKeys(preset) {
+            expanded = append(expanded, fmt.Sprintf("-%s=%v", name, preset[name]))
+        }
+    }
+    return expanded
+}
+
+// explicitGlobalFlags records the flags given before the command, which
+// are carried into the command's flags and win over configured defaults
+var explicitGlobalFlags = make(map[string]bool)
+
+// applyFlagDefaults sets the configured defaults of the command fs parses,
+// leaving flags given on the command line to override them
+func applyFlagDefaults(fs *flag.FlagSet, command string) {
+    defaults := flagDefaults[command]
+    names := make([]string, 0, len(defaults))
+    for name := range defaults {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+
+    for _, name := range names {
+        if explicitGlobalFlags[name] {
+            continue
+        }
+        if fs.Lookup(name) == nil {
+            fatal("Error loading config", fmt.Errorf("%w: defaults.%s: unknown flag -%s", errConfig, command, name))
+        }
+        if err := fs.Set(name, fmt.Sprint(defaults[name])); err != nil {
+            fatal("Error loading config", fmt.Errorf("%w: defaults.%s.%s: %w", errConfig, command, name, err))
+        }
+    }
+}
+
+// parseFlags parses the flags of a command that takes no positional
+// arguments, after applying its configured defaults and expanding presets
+func parseFlags(fs *flag.FlagSet, args []string) {
+    applyFlagDefaults(fs, fs.Name())
+    fs.Parse(expandPresets(args))
+}
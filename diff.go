@@ -0,0 +1,169 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "os"
+    "reflect"
+    "slices"
+    "sort"
+    "strings"
+)
+
+// liveDump names the live database as a diff operand
+const liveDump = "live"
+
+// loadDump reads a dump written by the dump command, or builds one from
+// the live database when path is "live"
+func (c *CLI) loadDump(path string) (*Dump, error) {
+    if path == liveDump {
+        return c.repo.BuildDump()
+    }
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, fmt.Errorf("reading dump: %w", err)
+    }
+    var dump Dump
+    if err := json.Unmarshal(data, &dump); err != nil {
+        return nil, fmt.Errorf("parsing dump %s: %w", path, err)
+    }
+    if dump.Version != dumpVersion {
+        return nil, fmt.Errorf("dump %s has version %d, expected %d", path, dump.Version, dumpVersion)
+    }
+    return &dump, nil
+}
+
+// dumpItemID identifies an item across dumps; keys are only unique within
+// a library
+func dumpItemID(item DumpItem) string {
+    return fmt.Sprintf("%d/%s", item.LibraryID, item.Key)
+}
+
+// setDifference returns the values of a missing from b, sorted
+func setDifference(a, b []string) []string {
+    var diff []string
+    for _, v := range a {
+        if !slices.Contains(b, v) {
+            diff = append(diff, v)
+        }
+    }
+    sort.Strings(diff)
+    return diff
+}
+
+// itemChanges describes how an item differs between two dumps, one line
+// per changed aspect
+func itemChanges(old, new DumpItem) []string {
+    var changes []string
+    if old.ItemType != new.ItemType {
+        changes = append(changes, fmt.Sprintf("itemType: %s -> %s", old.ItemType, new.ItemType))
+    }
+
+    var names []string
+    for name := range old.Fields {
+        names = append(names, name)
+    }
+    for name := range new.Fields {
+        if _, ok := old.Fields[name]; !ok {
+            names = append(names, name)
+        }
+    }
+    sort.Strings(names)
+    for _, name := range names {
+        before, hadBefore := old.Fields[name]
+        after, hasAfter := new.Fields[name]
+        switch {
+        case !hadBefore:
+            changes = append(changes, fmt.Sprintf("%s: added %q", name, after))
+        case !hasAfter:
+            changes = append(changes, fmt.Sprintf("%s: removed %q", name, before))
+        case before != after:
+            changes = append(changes, fmt.Sprintf("%s: %q -> %q", name, before, after))
+        }
+    }
+
+    if !reflect.DeepEqual(old.Creators, new.Creators) {
+        changes = append(changes, "creators changed")
+    }
+    for _, tag := range setDifference(new.Tags, old.Tags) {
+        changes = append(changes, "tag added: "+tag)
+    }
+    for _, tag := range setDifference(old.Tags, new.Tags) {
+        changes = append(changes, "tag removed: "+tag)
+    }
+    for _, key := range setDifference(new.Collections, old.Collections) {
+        changes = append(changes, "added to collection: "+key)
+    }
+    for _, key := range setDifference(old.Collections, new.Collections) {
+        changes = append(changes, "removed from collection: "+key)
+    }
+
+    oldAttachments := make(map[string]DumpAttachment)
+    for _, att := range old.Attachments {
+        oldAttachments[att.Key] = att
+    }
+    newAttachments := make(map[string]DumpAttachment)
+    for _, att := range new.Attachments {
+        newAttachments[att.Key] = att
+        before, ok := oldAttachments[att.Key]
+        switch {
+        case !ok:
+            changes = append(changes, fmt.Sprintf("attachment added: %s %s", att.Key, att.Title))
+        case before != att:
+            changes = append(changes, fmt.Sprintf("attachment changed: %s %s", att.Key, att.Title))
+        }
+    }
+    for _, att := range old.Attachments {
+        if _, ok := newAttachments[att.Key]; !ok {
+            changes = append(changes, fmt.Sprintf("attachment removed: %s %s", att.Key, att.Title))
+        }
+    }
+
+    if !reflect.DeepEqual(old.Notes, new.Notes) {
+        changes = append(changes, "notes changed")
+    }
+    return changes
+}
+
+// Diff reports the items added, removed and modified between two dumps,
+// either of which may be the live database
+func (c *CLI) Diff(oldPath, newPath string) error {
+    old, err := c.loadDump(oldPath)
+    if err != nil {
+        return err
+    }
+    new, err := c.loadDump(newPath)
+    if err != nil {
+        return err
+    }
+
+    oldItems := make(map[string]DumpItem)
+    for _, item := range old.Items {
+        oldItems[dumpItemID(item)] = item
+    }
+    newItems := make(map[string]DumpItem)
+    for _, item := range new.Items {
+        newItems[dumpItemID(item)] = item
+    }
+
+    // dumps are sorted, so walking both in order keeps the report sorted
+    for _, item := range new.Items {
+        before, ok := oldItems[dumpItemID(item)]
+        if !ok {
+            fmt.Printf("+ %s\t%s\n", item.Key, item.Fields["title"])
+            continue
+        }
+        changes := itemChanges(before, item)
+        if len(changes) == 0 {
+            continue
+        }
+        fmt.Printf("~ %s\t%s\n", item.Key, item.Fields["title"])
+        fmt.Printf("    %s\n", strings.Join(changes, "\n    "))
+    }
+    for _, item := range old.Items {
+        if _, ok := newItems[dumpItemID(item)]; !ok {
+            fmt.Printf("- %s\t%s\n", item.Key, item.Fields["title"])
+        }
+    }
+    return nil
+}
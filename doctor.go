@@ -0,0 +1,88 @@
+package main
+
+import (
+    "fmt"
+    "strings"
+)
+
+// testedUserdataVersion is the userdata schema of the Zotero release the
+// tool is tested with (7.0); later ones usually only add to it
+const testedUserdataVersion = 120
+
+// requiredTables are the tables every command reads, present since
+// Zotero 5
+var requiredTables = []string{
+    "items", "itemData", "itemDataValues", "fields", "itemTypes",
+    "creators", "itemCreators", "creatorTypes", "tags", "itemTags",
+    "itemAttachments", "itemNotes", "collections", "collectionItems",
+    "libraries", "deletedItems",
+}
+
+// optionalTables are the tables of later Zotero versions or of optional
+// data, by the features that need them
+var optionalTables = []struct {
+    table, feature string
+}{
+    {"itemAnnotations", "annotations and review (Zotero 6 or later)"},
+    {"feeds", "-feeds"},
+    {"publicationsItems", "-publications"},
+    {"retractedItems", "retraction flags"},
+    {"groups", "group libraries"},
+    {"syncedSettings", "tag colors"},
+    {"itemRelations", "related"},
+    {"relationPredicates", "related"},
+    {"fulltextItems", "text from Zotero's full-text cache"},
+}
+
+// Doctor checks that the database is one the tool can read: its schema
+// version, the tables every command needs and those of optional features.
+// Problems come with what to do about them; any blocking one fails.
+func (c *CLI) Doctor() error {
+    fmt.Printf("database\t%s\n", c.cfg.DBPath)
+    info, err := c.repo.schema()
+    if err != nil {
+        return fmt.Errorf("%w: cannot read %s, check dbPath points at zotero.sqlite: %w", errConfig, c.cfg.DBPath, err)
+    }
+
+    failed := 0
+    report := func(status, check, detail string) {
+        if status == "fail" {
+            failed++
+        }
+        fmt.Printf("%s\t%s\t%s\n", status, check, detail)
+    }
+
+    switch {
+    case !info.has("version") || info.Userdata == 0:
+        report("fail", "schema", "no Zotero schema version recorded; is dbPath a Zotero database?")
+    case info.Userdata > testedUserdataVersion:
+        report("warn", "schema", fmt.Sprintf("userdata %d is newer than the tested %d; report anything that breaks", info.Userdata, testedUserdataVersion))
+    default:
+        report("ok", "schema", fmt.Sprintf("userdata %d", info.Userdata))
+    }
+
+    var missing []string
+    for _, table := range requiredTables {
+        if !info.has(table) {
+            missing = append(missing, table)
+        }
+    }
+    if len(missing) > 0 {
+        report("fail", "tables", "missing "+strings.Join(missing, ", ")+"; the database predates Zotero 5 or is damaged, open it in a current Zotero to upgrade it")
+    } else {
+        report("ok", "tables", fmt.Sprintf("all %d required tables present", len(requiredTables)))
+    }
+
+    for _, optional := range optionalTables {
+        if info.has(optional.table) {
+            report("ok", optional.table, "for "+optional.feature)
+        } else {
+            report("warn", optional.table, "missing, needed for "+optional.feature+"; upgrading Zotero adds it")
+        }
+    }
+
+    if failed > 0 {
+        return fmt.Errorf("%w: %d checks failed", errConfig, failed)
+    }
+    return nil
+}
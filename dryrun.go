@@ -0,0 +1,40 @@
+package main
+
+import (
+    "fmt"
+    "strings"
+)
+
+// pretend prints an action that would change files, state or the library
+// and reports whether -dry-run holds it back, in which case the caller
+// skips it
+func (c *CLI) pretend(format string, args ...interface{}) bool {
+    if !c.dryRun {
+        return false
+    }
+    fmt.Printf("would "+format+"\n", args...)
+    return true
+}
+
+// pretendTags is pretend for adding and removing tags of an item
+func (c *CLI) pretendTags(stableID string, add, remove []string) bool {
+    if len(remove) > 0 {
+        return c.pretend("remove %s from %s", strings.Join(remove, ", "), stableID)
+    }
+    return c.pretend("tag %s with %s", stableID, strings.Join(add, ", "))
+}
+
+// pretendFiling is pretend for filing an item in a collection, or moving
+// it there with move
+func (c *CLI) pretendFiling(stableID, collection string, move bool) bool {
+    if move {
+        return c.pretend("move %s to collection %s", stableID, collection)
+    }
+    return c.pretend("file %s in collection %s", stableID, collection)
+}
+
+// readOnlyDSN opens the SQLite file at path without creating or changing
+// it, for reading the sidecar indexes under -dry-run
+func readOnlyDSN(path string) string {
+    return "file:" + path + "?mode=ro"
+}
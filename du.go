@@ -0,0 +1,206 @@
+package main
+
+import (
+    "cmp"
+    "errors"
+    "fmt"
+    "io/fs"
+    "path/filepath"
+    "slices"
+    "strconv"
+    "strings"
+)
+
+// itemSize is the disk usage of an item's attachments
+type itemSize struct {
+    Item  *Item
+    Bytes int64
+}
+
+// attachmentSize returns the disk usage of an attachment. Stored files
+// count their whole storage folder, which also holds snapshot resources
+// and extracted text.
+func (c *CLI) attachmentSize(att *Attachment) (int64, error) {
+    if att.Path == "" {
+        return 0, nil
+    }
+    root := att.Path
+    if att.LinkMode == linkModeImportedFile || att.LinkMode == linkModeImportedURL {
+        root = filepath.Join(c.cfg.StoragePath, att.StableID)
+    }
+
+    var total int64
+    err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+        if err != nil {
+            return err
+        }
+        if d.IsDir() {
+            return nil
+        }
+        info, err := d.Info()
+        if err != nil {
+            return err
+        }
+        total += info.Size()
+        return nil
+    })
+    if errors.Is(err, fs.ErrNotExist) {
+        return 0, nil
+    }
+    if err != nil {
+        return 0, fmt.Errorf("measuring %s: %w", root, err)
+    }
+    return total, nil
+}
+
+// formatSize renders a byte count the way du -h does
+func formatSize(bytes int64, human bool) string {
+    if !human {
+        return fmt.Sprint(bytes)
+    }
+    const units = "KMGTPE"
+    if bytes < 1024 {
+        return fmt.Sprintf("%dB", bytes)
+    }
+    value := float64(bytes)
+    unit := -1
+    for value >= 1024 && unit < len(units)-1 {
+        value /= 1024
+        unit++
+    }
+    if value < 10 {
+        return fmt.Sprintf("%.1f%c", value, units[unit])
+    }
+    return fmt.Sprintf("%.0f%c", value, units[unit])
+}
+
+// DiskUsageOptions controls the du command
+type DiskUsageOptions struct {
+    Sort         string
+    MinBytes     int64
+    Bytes        bool
+    ByCollection bool
+}
+
+// collectionPaths maps collection keys to their slash separated paths
+func collectionPaths(collections []*Collection) map[string]string {
+    byKey := make(map[string]*Collection)
+    for _, collection := range collections {
+        byKey[collection.Key] = collection
+    }
+    paths := make(map[string]string)
+    for _, collection := range collections {
+        parts := []string{collection.Name}
+        for parent := byKey[collection.ParentKey]; parent != nil; parent = byKey[parent.ParentKey] {
+            parts = append([]string{parent.Name}, parts...)
+        }
+        paths[collection.Key] = strings.Join(parts, "/")
+    }
+    return paths
+}
+
+// DiskUsage prints how much disk the attachments of matching items take,
+// per item or summed per collection
+func (c *CLI) DiskUsage(filter Filter, opts DiskUsageOptions) error {
+    items, err := c.repo.ListItems(filter)
+    if err != nil {
+        return fmt.Errorf("listing items: %w", err)
+    }
+
+    var sizes []itemSize
+    for _, item := range items {
+        var total int64
+        for _, att := range item.Attachments {
+            size, err := c.attachmentSize(att)
+            if err != nil {
+                return err
+            }
+            total += size
+        }
+        if total >= opts.MinBytes {
+            sizes = append(sizes, itemSize{Item: item, Bytes: total})
+        }
+    }
+
+    if opts.ByCollection {
+        return c.diskUsageByCollection(sizes, opts)
+    }
+
+    switch opts.Sort {
+    case "key":
+        slices.SortFunc(sizes, func(a, b itemSize) int {
+            return strings.Compare(a.Item.StableID, b.Item.StableID)
+        })
+    case "title":
+        slices.SortFunc(sizes, func(a, b itemSize) int {
+            return strings.Compare(a.Item.Title, b.Item.Title)
+        })
+    default:
+        slices.SortStableFunc(sizes, func(a, b itemSize) int {
+            return cmp.Compare(b.Bytes, a.Bytes)
+        })
+    }
+
+    var total int64
+    for _, size := range sizes {
+        fmt.Printf("%s\t%s\t%s\n", formatSize(size.Bytes, !opts.Bytes), size.Item.StableID, size.Item.Title)
+        total += size.Bytes
+    }
+    fmt.Printf("%s\ttotal\n", formatSize(total, !opts.Bytes))
+    return nil
+}
+
+// diskUsageByCollection sums item sizes per collection. Items in several
+// collections count towards each of them.
+func (c *CLI) diskUsageByCollection(sizes []itemSize, opts DiskUsageOptions) error {
+    collections, err := c.repo.ListCollections()
+    if err != nil {
+        return fmt.Errorf("listing collections: %w", err)
+    }
+    paths := collectionPaths(collections)
+
+    totals := make(map[string]int64)
+    for _, size := range sizes {
+        keys, err := c.repo.ItemCollections(size.Item.ID)
+        if err != nil {
+            return fmt.Errorf("fetching collections: %w", err)
+        }
+        if len(keys) == 0 {
+            totals["(unfiled)"] += size.Bytes
+        }
+        for _, key := range keys {
+            totals[paths[key]] += size.Bytes
+        }
+    }
+
+    names := sortedKeys(totals)
+    if opts.Sort != "title" && opts.Sort != "key" {
+        slices.SortStableFunc(names, func(a, b string) int {
+            return cmp.Compare(totals[b], totals[a])
+        })
+    }
+    for _, name := range names {
+        fmt.Printf("%s\t%s\n", formatSize(totals[name], !opts.Bytes), name)
+    }
+    return nil
+}
+
+// parseSize reads sizes such as 500K, 20M or 1G
+func parseSize(s string) (int64, error) {
+    if s == "" {
+        return 0, nil
+    }
+    number, multiplier := s, int64(1)
+    switch suffix := strings.ToUpper(s[len(s)-1:]); suffix {
+    case "K", "M", "G", "T":
+        multiplier = int64(1) << (10 * (strings.Index("KMGT", suffix) + 1))
+        number = s[:len(s)-1]
+    case "B":
+        number = s[:len(s)-1]
+    }
+    n, err := strconv.ParseFloat(number, 64)
+    if err != nil || n < 0 {
+        return 0, fmt.Errorf("invalid size: %q", s)
+    }
+    return int64(n * float64(multiplier)), nil
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+    "fmt"
+    "maps"
+    "os"
+    "path/filepath"
+    "slices"
+    "strings"
+)
+
+// dumpVersion is bumped whenever the dump layout changes incompatibly
+const dumpVersion = 1
+
+// Dump is a full export of a library, laid out so that two dumps of the
+// same library compare equal line by line
+type Dump struct {
+    Version     int              `json:"version"`
+    Collections []DumpCollection `json:"collections"`
+    Items       []DumpItem       `json:"items"`
+}
+
+// DumpCollection is a collection in a dump
+type DumpCollection struct {
+    Key    string `json:"key"`
+    Name   string `json:"name"`
+    Parent string `json:"parent,omitempty"`
+}
+
+// DumpItem is a top-level item in a dump
+type DumpItem struct {
+    LibraryID    int64             `json:"libraryID"`
+    Key          string            `json:"key"`
+    ItemType     string            `json:"itemType"`
+    DateAdded    string            `json:"dateAdded"`
+    DateModified string            `json:"dateModified"`
+    Fields       map[string]string `json:"fields"`
+    Creators     []Creator         `json:"creators"`
+    Tags         []string          `json:"tags"`
+    Collections  []string          `json:"collections"`
+    Attachments  []DumpAttachment  `json:"attachments"`
+    Notes        []DumpNote        `json:"notes"`
+}
+
+// DumpAttachment is the metadata of an attachment in a dump. Paths inside
+// the storage folder are relative so dumps from different machines match.
+type DumpAttachment struct {
+    Key         string `json:"key"`
+    Title       string `json:"title"`
+    LinkMode    string `json:"linkMode"`
+    ContentType string `json:"contentType,omitempty"`
+    Path        string `json:"path,omitempty"`
+    MD5         string `json:"md5,omitempty"`
+}
+
+// DumpNote is a child note in a dump
+type DumpNote struct {
+    Key  string `json:"key"`
+    HTML string `json:"html"`
+}
+
+const itemTagsQuery = `
+    SELECT itag.itemID, t.name
+    FROM itemTags itag
+    JOIN tags t ON itag.tagID = t.tagID
+    WHERE itag.itemID IN (SELECT value FROM json_each(?))
+    ORDER BY itag.itemID, t.name`
+
+// Tags retrieves the tags of the items with the given IDs in a single
+// query, keyed by item ID and in name order
+func (r *Repository) Tags(itemIDs []int64) (map[int64][]string, error) {
+    rows, err := r.query(itemTagsQuery, idList(itemIDs))
+    if err != nil {
+        return nil, fmt.Errorf("executing query: %w", err)
+    }
+    defer rows.Close()
+
+    tags := make(map[int64][]string)
+    for rows.Next() {
+        var itemID int64
+        var tag string
+        if err := rows.Scan(&itemID, &tag); err != nil {
+            return nil, fmt.Errorf("scanning row: %w", err)
+        }
+        tags[itemID] = append(tags[itemID], tag)
+    }
+
+    if err = rows.Err(); err != nil {
+        return nil, fmt.Errorf("iterating rows: %w", err)
+    }
+
+    return tags, nil
+}
+
+// BuildDump exports every top-level item and collection of the selected
+// library, sorted by library and key
+func (r *Repository) BuildDump() (*Dump, error) {
+    dump := &Dump{
+        Version:     dumpVersion,
+        Collections: []DumpCollection{},
+        Items:       []DumpItem{},
+    }
+
+    collections, err := r.ListCollections()
+    if err != nil {
+        return nil, fmt.Errorf("listing collections: %w", err)
+    }
+    for _, collection := range collections {
+        dump.Collections = append(dump.Collections, DumpCollection{
+            Key:    collection.Key,
+            Name:   collection.Name,
+            Parent: collection.ParentKey,
+        })
+    }
+
+    items, err := r.ListItems(Filter{})
+    if err != nil {
+        return nil, fmt.Errorf("listing items: %w", err)
+    }
+    for _, item := range items {
+        dump.Items = append(dump.Items, r.dumpItem(item))
+    }
+    slices.SortFunc(dump.Items, func(a, b DumpItem) int {
+        if a.LibraryID != b.LibraryID {
+            return int(a.LibraryID - b.LibraryID)
+        }
+        return strings.Compare(a.Key, b.Key)
+    })
+
+    return dump, nil
+}
+
+// dumpItem lays out everything stored about a single item, as loaded by
+// loadChildren
+func (r *Repository) dumpItem(item *Item) DumpItem {
+    dumped := DumpItem{
+        LibraryID:    item.LibraryID,
+        Key:          item.StableID,
+        ItemType:     item.ItemType,
+        DateAdded:    item.DateAdded,
+        DateModified: item.DateModified,
+        Fields:       map[string]string{},
+        Creators:     []Creator{},
+        Tags:         []string{},
+        Collections:  []string{},
+        Attachments:  []DumpAttachment{},
+        Notes:        []DumpNote{},
+    }
+    maps.Copy(dumped.Fields, item.Fields)
+    dumped.Creators = append(dumped.Creators, item.Creators...)
+    dumped.Tags = append(dumped.Tags, item.Tags...)
+    dumped.Collections = append(dumped.Collections, item.CollectionKeys...)
+
+    for _, att := range item.Attachments {
+        path := att.Path
+        if rel, err := filepath.Rel(r.cfg.StoragePath, path); err == nil && !strings.HasPrefix(rel, "..") {
+            path = rel
+        }
+        dumped.Attachments = append(dumped.Attachments, DumpAttachment{
+            Key:         att.StableID,
+            Title:       att.Title,
+            LinkMode:    att.LinkModeName(),
+            ContentType: att.ContentType,
+            Path:        path,
+            MD5:         att.StorageHash,
+        })
+    }
+    for _, note := range item.Notes {
+        dumped.Notes = append(dumped.Notes, DumpNote{Key: note.StableID, HTML: note.HTML})
+    }
+
+    return dumped
+}
+
+// Dump writes a full export of the library to path, or to stdout when
+// path is empty or "-"
+func (c *CLI) Dump(path string) error {
+    dump, err := c.repo.BuildDump()
+    if err != nil {
+        return err
+    }
+    if path == "" || path == "-" {
+        return writeJSON(dump)
+    }
+    if c.pretend("write dump of %d items to %s", len(dump.Items), path) {
+        return nil
+    }
+
+    // write next to the target so an interrupted dump never replaces a
+    // good one
+    tmp, err := os.CreateTemp(filepath.Dir(path), ".dump-*.json")
+    if err != nil {
+        return fmt.Errorf("creating temporary file: %w", err)
+    }
+    defer os.Remove(tmp.Name())
+    if err := tmp.Chmod(0644); err != nil {
+        tmp.Close()
+        return fmt.Errorf("writing dump: %w", err)
+    }
+    if err := encodeJSON(tmp, dump); err != nil {
+        tmp.Close()
+        return err
+    }
+    if err := tmp.Close(); err != nil {
+        return fmt.Errorf("writing dump: %w", err)
+    }
+    if err := os.Rename(tmp.Name(), path); err != nil {
+        return fmt.Errorf("writing dump: %w", err)
+    }
+    return nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "io"
+    "log/slog"
+    "net/http"
+    "strings"
+    "time"
+)
+
+// EmbeddingsConfig selects the service turning text into vectors for
+// semsearch. Any server speaking the OpenAI embeddings API works, such as
+// a local Ollama or llama.cpp server or a hosted API.
+type EmbeddingsConfig struct {
+    URL    string `json:"url"`
+    Model  string `json:"model"`
+    APIKey string `json:"apiKey"`
+}
+
+// embeddingBatch is the number of texts sent per embeddings request
+const embeddingBatch = 64
+
+// embed returns a vector for each of texts, in order
+func (c *CLI) embed(texts []string) ([][]float32, error) {
+    cfg := c.cfg.Embeddings
+    if cfg.URL == "" || cfg.Model == "" {
+        return nil, fmt.Errorf("%w: embeddings.url and embeddings.model must be set", errConfig)
+    }
+
+    body, err := json.Marshal(map[string]interface{}{
+        "model": cfg.Model,
+        "input": texts,
+    })
+    if err != nil {
+        return nil, fmt.Errorf("encoding request: %w", err)
+    }
+    req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(cfg.URL, "/")+"/embeddings", bytes.NewReader(body))
+    if err != nil {
+        return nil, fmt.Errorf("creating request: %w", err)
+    }
+    req.Header.Set("Content-Type", "application/json")
+    req.Header.Set("User-Agent", "zotero-fetch/"+c.cfg.Version)
+    if cfg.APIKey != "" {
+        req.Header.Set("Authorization", "Bearer "+cfg.APIKey)
+    }
+
+    start := time.Now()
+    resp, err := httpClient.Do(req)
+    if err != nil {
+        return nil, fmt.Errorf("requesting %s: %w", req.URL, err)
+    }
+    defer resp.Body.Close()
+    slog.Debug("http request",
+        "method", req.Method,
+        "url", req.URL.String(),
+        "status", resp.StatusCode,
+        "duration", time.Since(start))
+
+    if resp.StatusCode != http.StatusOK {
+        message, _ := io.ReadAll(resp.Body)
+        return nil, fmt.Errorf("requesting %s: %s: %s", req.URL, resp.Status, bytes.TrimSpace(message))
+    }
+    var result struct {
+        Data []struct {
+            Index     int       `json:"index"`
+            Embedding []float32 `json:"embedding"`
+        } `json:"data"`
+    }
+    if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+        return nil, fmt.Errorf("decoding response of %s: %w", req.URL, err)
+    }
+    if len(result.Data) != len(texts) {
+        return nil, fmt.Errorf("expected %d embeddings from %s, got %d", len(texts), req.URL, len(result.Data))
+    }
+
+    vectors := make([][]float32, len(texts))
+    for _, d := range result.Data {
+        if d.Index < 0 || d.Index >= len(texts) {
+            return nil, fmt.Errorf("embedding index out of range: %d", d.Index)
+        }
+        vectors[d.Index] = d.Embedding
+    }
+    return vectors, nil
+}
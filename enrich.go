@@ -0,0 +1,204 @@
+package main
+
+import (
+    "fmt"
+    "maps"
+    "net/url"
+    "strconv"
+    "strings"
+)
+
+// crossrefWork is the part of a Crossref work record compared against
+// the local item
+type crossrefWork struct {
+    Type           string   `json:"type"`
+    DOI            string   `json:"DOI"`
+    Title          []string `json:"title"`
+    ContainerTitle []string `json:"container-title"`
+    Publisher      string   `json:"publisher"`
+    Volume         string   `json:"volume"`
+    Issue          string   `json:"issue"`
+    Page           string   `json:"page"`
+    ISSN           []string `json:"ISSN"`
+    Author         []struct {
+        Given  string `json:"given"`
+        Family string `json:"family"`
+        Name   string `json:"name"`
+    } `json:"author"`
+    Issued struct {
+        DateParts [][]int `json:"date-parts"`
+    } `json:"issued"`
+}
+
+// crossrefLookup fetches the Crossref record of a DOI, or the best title
+// match when doi is empty
+func (c *CLI) crossrefLookup(doi, title string) (*crossrefWork, error) {
+    base := strings.TrimSuffix(c.cfg.CrossrefURL, "/")
+    if doi != "" {
+        var resp struct {
+            Message crossrefWork `json:"message"`
+        }
+        if err := c.getJSON(base+"/works/"+url.PathEscape(normalizeDOI(doi)), &resp); err != nil {
+            return nil, fmt.Errorf("looking up DOI: %w", err)
+        }
+        return &resp.Message, nil
+    }
+
+    query := url.Values{"query.bibliographic": {title}, "rows": {"1"}}
+    var resp struct {
+        Message struct {
+            Items []crossrefWork `json:"items"`
+        } `json:"message"`
+    }
+    if err := c.getJSON(base+"/works?"+query.Encode(), &resp); err != nil {
+        return nil, fmt.Errorf("searching title: %w", err)
+    }
+    if len(resp.Message.Items) == 0 {
+        return nil, fmt.Errorf("crossref record %w: %s", errNotFound, title)
+    }
+    return &resp.Message.Items[0], nil
+}
+
+// Enrichment statuses
+const (
+    enrichSame    = "same"
+    enrichMissing = "missing"
+    enrichDiffers = "differs"
+)
+
+// EnrichedField compares one field of the local record with Crossref
+type EnrichedField struct {
+    Field    string `json:"field"`
+    Local    string `json:"local"`
+    Crossref string `json:"crossref"`
+    Status   string `json:"status"`
+}
+
+// crossrefFields flattens a Crossref work into Zotero field names
+func crossrefFields(work *crossrefWork) map[string]string {
+    var authors []string
+    for _, a := range work.Author {
+        switch {
+        case a.Family != "" && a.Given != "":
+            authors = append(authors, a.Family+", "+a.Given)
+        case a.Family != "":
+            authors = append(authors, a.Family)
+        default:
+            authors = append(authors, a.Name)
+        }
+    }
+    year := ""
+    if len(work.Issued.DateParts) > 0 && len(work.Issued.DateParts[0]) > 0 {
+        year = strconv.Itoa(work.Issued.DateParts[0][0])
+    }
+    return map[string]string{
+        "title":            strings.Join(work.Title, " "),
+        "creators":         strings.Join(authors, "; "),
+        "publicationTitle": strings.Join(work.ContainerTitle, " "),
+        "publisher":        work.Publisher,
+        "volume":           work.Volume,
+        "issue":            work.Issue,
+        "pages":            work.Page,
+        "ISSN":             strings.Join(work.ISSN, ", "),
+        "date":             year,
+        "DOI":              work.DOI,
+    }
+}
+
+// enrichedFieldOrder is the order fields are reported in
+var enrichedFieldOrder = []string{
+    "title", "creators", "publicationTitle", "publisher",
+    "volume", "issue", "pages", "ISSN", "date", "DOI",
+}
+
+// sameValue compares field values ignoring case, diacritics, spacing and
+// dash styles. Dates only need to agree on the year Crossref reports.
+func sameValue(field, local, remote string) bool {
+    if field == "date" {
+        return strings.Contains(local, remote)
+    }
+    if field == "DOI" {
+        return normalizeDOI(local) == normalizeDOI(remote)
+    }
+    normalize := func(s string) string {
+        s = strings.NewReplacer("–", "-", "—", "-").Replace(fold(s))
+        return strings.Join(strings.Fields(s), " ")
+    }
+    return normalize(local) == normalize(remote)
+}
+
+// Enrich compares an item with its Crossref record, found by DOI or else
+// by title, and returns every field Crossref knows about
+func (c *CLI) Enrich(stableID string) ([]EnrichedField, error) {
+    item, err := c.repo.GetByStableID(stableID)
+    if err != nil {
+        return nil, fmt.Errorf("getting item: %w", err)
+    }
+    local := maps.Clone(item.Fields)
+    if local == nil {
+        local = make(map[string]string)
+    }
+    var authors []string
+    for _, creator := range item.Creators {
+        if creator.CreatorType == "author" {
+            authors = append(authors, (&Author{FirstName: creator.FirstName, LastName: creator.LastName}).Name())
+        }
+    }
+    local["creators"] = strings.Join(authors, "; ")
+
+    work, err := c.crossrefLookup(local["DOI"], item.Title)
+    if err != nil {
+        return nil, err
+    }
+    remote := crossrefFields(work)
+
+    var fields []EnrichedField
+    for _, name := range enrichedFieldOrder {
+        if remote[name] == "" {
+            continue
+        }
+        field := EnrichedField{Field: name, Local: local[name], Crossref: remote[name], Status: enrichSame}
+        switch {
+        case field.Local == "":
+            field.Status = enrichMissing
+        case !sameValue(name, field.Local, field.Crossref):
+            field.Status = enrichDiffers
+        }
+        fields = append(fields, field)
+    }
+    return fields, nil
+}
+
+// PrintEnrichment prints the fields that are missing locally or disagree
+// with Crossref, or every field when all is set
+func (c *CLI) PrintEnrichment(stableID string, all bool, format string) error {
+    fields, err := c.Enrich(stableID)
+    if err != nil {
+        return err
+    }
+    if !all {
+        var changed []EnrichedField
+        for _, field := range fields {
+            if field.Status != enrichSame {
+                changed = append(changed, field)
+            }
+        }
+        fields = changed
+    }
+
+    if format == "json" {
+        if fields == nil {
+            fields = []EnrichedField{}
+        }
+        return writeJSON(fields)
+    }
+    for _, field := range fields {
+        switch field.Status {
+        case enrichMissing:
+            fmt.Printf("%-8s%-17s%s\n", field.Status, field.Field, field.Crossref)
+        default:
+            fmt.Printf("%-8s%-17s%s\n%25s-> %s\n", field.Status, field.Field, field.Local, "", field.Crossref)
+        }
+    }
+    return nil
+}
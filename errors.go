@@ -0,0 +1,75 @@
+package main
+
+import (
+    "database/sql"
+    "encoding/json"
+    "errors"
+    "os"
+)
+
+// Sentinel errors wrapped by the repository and CLI so that failures can be
+// told apart by the exit status
+var (
+    errNotFound     = errors.New("not found")
+    errNoAttachment = errors.New("no attachment found")
+    errConfig       = errors.New("invalid configuration")
+
+    // errZoteroNotRunning is returned when the Zotero client's connector
+    // server does not answer
+    errZoteroNotRunning = errors.New("zotero is not running")
+
+    // errConflict is returned when a remote write would overwrite changes
+    // made elsewhere
+    errConflict = errors.New("conflicting change")
+)
+
+// Exit statuses for the failure classes wrapping scripts branch on
+const (
+    exitError        = 1
+    exitNotFound     = 2
+    exitNoAttachment = 3
+    exitLocked       = 4
+    exitConfig       = 5
+    exitConflict     = 6
+)
+
+// jsonErrors makes fatal print errors as JSON objects
+var jsonErrors bool
+
+// errorJSON is the structured form of a fatal error
+type errorJSON struct {
+    Code     string `json:"code"`
+    ExitCode int    `json:"exitCode"`
+    Context  string `json:"context"`
+    Message  string `json:"message"`
+}
+
+// classify maps err to a stable error code and exit status
+func classify(err error) (string, int) {
+    switch {
+    case errors.Is(err, errNotFound), errors.Is(err, sql.ErrNoRows):
+        return "not_found", exitNotFound
+    case errors.Is(err, errNoAttachment):
+        return "no_attachment", exitNoAttachment
+    case isLocked(err):
+        return "db_locked", exitLocked
+    case errors.Is(err, errConfig):
+        return "config", exitConfig
+    case errors.Is(err, errConflict):
+        return "conflict", exitConflict
+    default:
+        return "error", exitError
+    }
+}
+
+// writeErrorJSON prints err to stderr as a single JSON object
+func writeErrorJSON(msg string, err error, code string, status int) {
+    json.NewEncoder(os.Stderr).Encode(struct {
+        Error errorJSON `json:"error"`
+    }{errorJSON{
+        Code:     code,
+        ExitCode: status,
+        Context:  msg,
+        Message:  err.Error(),
+    }})
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+    "fmt"
+    "io"
+    "os"
+)
+
+// ExportOptions holds the settings of the individual export formats
+type ExportOptions struct {
+    // Deck is the Anki deck cards are filed under
+    Deck string
+}
+
+// exportFormats lists the formats understood by the export command
+var exportFormats = []string{"anki", "bibtex", "hayagriva", "ndjson"}
+
+// Export writes items matching filter in one of the export formats, or
+// through a configured formatter, to path, or to stdout when path is empty
+func (c *CLI) Export(format, path string, filter Filter, opts ExportOptions) error {
+    var export func(io.Writer) error
+    switch format {
+    case "anki":
+        export = func(w io.Writer) error { return c.ExportAnki(w, filter, opts.Deck) }
+    case "bibtex":
+        export = func(w io.Writer) error { return c.ExportBibTeX(w, filter) }
+    case "hayagriva":
+        export = func(w io.Writer) error { return c.ExportHayagriva(w, filter) }
+    case "ndjson":
+        export = func(w io.Writer) error { return c.ExportNDJSON(w, filter) }
+    default:
+        command := c.formatter(format)
+        if command == nil {
+            return fmt.Errorf("unknown export format: %s", format)
+        }
+        export = func(w io.Writer) error {
+            return runFormatter(command, w, func(in io.Writer) error { return c.ExportNDJSON(in, filter) })
+        }
+    }
+
+    if path == "" {
+        return export(os.Stdout)
+    }
+    if c.dryRun {
+        // the export still runs so that bad filters fail the dry run too
+        if err := export(io.Discard); err != nil {
+            return err
+        }
+        c.pretend("write %s export to %s", format, path)
+        return nil
+    }
+    f, err := os.Create(path)
+    if err != nil {
+        return fmt.Errorf("creating %s: %w", path, err)
+    }
+    if err := export(f); err != nil {
+        f.Close()
+        return err
+    }
+    if err := f.Close(); err != nil {
+        return fmt.Errorf("writing %s: %w", path, err)
+    }
+    return nil
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "log/slog"
+    "os"
+    "path/filepath"
+    "strings"
+)
+
+// exportTree is the export format writing a folder tree instead of a file
+const exportTree = "tree"
+
+// unfiledFolder holds the items of an exported tree that are in no
+// collection
+const unfiledFolder = "Unfiled"
+
+// treeNameReplacer replaces the characters file systems reject in names
+var treeNameReplacer = strings.NewReplacer(
+    "/", "_", "\\", "_", ":", "_", "*", "_", "?", "_",
+    "\"", "_", "<", "_", ">", "_", "|", "_")
+
+// treeName makes s usable as a file or folder name on every platform
+func treeName(s string) string {
+    s = strings.Map(func(r rune) rune {
+        if r < ' ' {
+            return -1
+        }
+        return r
+    }, treeNameReplacer.Replace(s))
+    s = strings.Trim(s, " .")
+    if s == "" {
+        return "_"
+    }
+    return s
+}
+
+// treeFileName names an item's attachment in an exported tree after the
+// item's title, keeping the key to tell same-titled items apart
+func treeFileName(item *Item, att *Attachment) string {
+    title := item.Title
+    if title == "" {
+        title = "Untitled"
+    }
+    return treeName(truncateString(title, 80)) + " (" + item.StableID + ")" + filepath.Ext(att.Path)
+}
+
+// treeFolders maps collection keys to their folders in an exported tree,
+// following the chain of parents so that names containing a slash stay
+// a single folder
+func treeFolders(collections []*Collection) map[string]string {
+    byKey := make(map[string]*Collection)
+    for _, collection := range collections {
+        byKey[collection.Key] = collection
+    }
+    folders := make(map[string]string)
+    for _, collection := range collections {
+        parts := []string{treeName(collection.Name)}
+        for parent := byKey[collection.ParentKey]; parent != nil; parent = byKey[parent.ParentKey] {
+            parts = append([]string{treeName(parent.Name)}, parts...)
+        }
+        folders[collection.Key] = filepath.Join(parts...)
+    }
+    return folders
+}
+
+// ExportTree mirrors the collections of the items matching filter as
+// folders in dir, with the best attachment of each item in the folder of
+// every collection it is filed in and items in none under Unfiled. The
+// files are copied, or symlinked with link, so the tree can be handed to
+// someone without Zotero. jobs items are exported at once.
+func (c *CLI) ExportTree(dir string, filter Filter, link bool, jobs int) error {
+    items, err := c.repo.ListItems(filter)
+    if err != nil {
+        return fmt.Errorf("listing items: %w", err)
+    }
+    collections, err := c.repo.ListCollections()
+    if err != nil {
+        return fmt.Errorf("listing collections: %w", err)
+    }
+    folders := treeFolders(collections)
+    if c.dryRun {
+        // keeps what would be done in item order
+        jobs = 1
+    }
+
+    type export struct {
+        written int
+        err     error
+    }
+    results := parallel(len(items), jobs, func(i int) export {
+        written, err := c.exportTreeItem(dir, items[i], folders, link)
+        return export{written, err}
+    })
+    written := 0
+    for i := range items {
+        result := <-results[i]
+        if result.err != nil {
+            return result.err
+        }
+        written += result.written
+    }
+    if !c.dryRun {
+        fmt.Printf("%d files written to %s\n", written, dir)
+    }
+    return nil
+}
+
+// exportTreeItem puts the best attachment of item into the folder of each
+// of its collections below dir, as mapped by folders, returning the number
+// of files written
+func (c *CLI) exportTreeItem(dir string, item *Item, folders map[string]string, link bool) (int, error) {
+    att := storageAttachment(item)
+    if att == nil {
+        return 0, nil
+    }
+    if err := c.ensureLocal(att); err != nil {
+        slog.Warn("could not download attachment", "key", item.StableID, "error", err)
+    }
+    if !att.Exists() {
+        slog.Warn("attachment missing from disk, skipped", "key", item.StableID, "path", att.Path)
+        return 0, nil
+    }
+
+    var targets []string
+    for _, key := range item.CollectionKeys {
+        if folder, ok := folders[key]; ok {
+            targets = append(targets, folder)
+        }
+    }
+    if len(targets) == 0 {
+        targets = []string{unfiledFolder}
+    }
+    for _, folder := range targets {
+        target := filepath.Join(dir, folder, treeFileName(item, att))
+        if err := c.exportTreeFile(att.Path, target, link); err != nil {
+            return 0, err
+        }
+    }
+    return len(targets), nil
+}
+
+// exportTreeFile copies or links the file at src to target, replacing
+// what an earlier export left there
+func (c *CLI) exportTreeFile(src, target string, link bool) error {
+    if link {
+        if c.pretend("link %s to %s", target, src) {
+            return nil
+        }
+    } else if c.pretend("copy %s to %s", src, target) {
+        return nil
+    }
+    if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+        return fmt.Errorf("creating folder: %w", err)
+    }
+    // a link left by an earlier export must not be written through into
+    // the storage folder
+    if err := os.Remove(target); err != nil && !errors.Is(err, os.ErrNotExist) {
+        return fmt.Errorf("replacing %s: %w", target, err)
+    }
+    if !link {
+        if err := copyFile(src, target); err != nil {
+            return fmt.Errorf("copying %s: %w", src, err)
+        }
+        return nil
+    }
+    if err := os.Symlink(src, target); err != nil {
+        return fmt.Errorf("linking %s: %w", src, err)
+    }
+    return nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+    "regexp"
+    "strings"
+)
+
+// citationKeyField is the extra line Better BibTeX and Zotero read
+// citation keys from
+const citationKeyField = "Citation Key"
+
+// setExtraField replaces or appends a "Name: value" line of an extra
+// field, the convention Zotero uses for fields it has no column for
+func setExtraField(extra, name, value string) string {
+    line := name + ": " + value
+    lines := strings.Split(extra, "\n")
+    for i, existing := range lines {
+        if before, _, ok := strings.Cut(existing, ":"); ok && strings.EqualFold(strings.TrimSpace(before), name) {
+            lines[i] = line
+            return strings.Join(lines, "\n")
+        }
+    }
+    if strings.TrimSpace(extra) == "" {
+        return line
+    }
+    return strings.TrimRight(extra, "\n") + "\n" + line
+}
+
+// extraValue returns the value of a "Name: value" line of an extra field,
+// matching the name case-insensitively
+func extraValue(extra, name string) string {
+    for _, line := range strings.Split(extra, "\n") {
+        if before, after, ok := strings.Cut(line, ":"); ok && strings.EqualFold(strings.TrimSpace(before), name) {
+            return strings.TrimSpace(after)
+        }
+    }
+    return ""
+}
+
+// extraValueSQL is the SQL entry point of extraValue, as extra_value()
+func extraValueSQL(extra interface{}, name string) string {
+    return extraValue(sqlText(extra), name)
+}
+
+// extraKeyPattern matches the names of "Name: value" lines, leaving out
+// free text and URLs that merely contain a colon
+var extraKeyPattern = regexp.MustCompile(`^\pL[\pL\pN ._-]*$`)
+
+// parseExtra returns the "Name: value" lines of an extra field, such as
+// Citation Key, PMID, arXiv or Better BibTeX's tex.* fields. Like
+// extraValue the first line of a name wins.
+func parseExtra(extra string) map[string]string {
+    fields := make(map[string]string)
+    for _, line := range strings.Split(extra, "\n") {
+        before, after, ok := strings.Cut(line, ":")
+        name := strings.TrimSpace(before)
+        if !ok || !extraKeyPattern.MatchString(name) || strings.HasPrefix(after, "//") {
+            continue
+        }
+        if _, seen := fields[name]; !seen {
+            fields[name] = strings.TrimSpace(after)
+        }
+    }
+    return fields
+}
+
+// extraCondition matches items whose extra field has a line of the
+// given name
+const extraCondition = `EXISTS (
+            SELECT 1 FROM itemData ed
+            JOIN itemDataValues ev ON ed.valueID = ev.valueID
+            JOIN fields ef ON ed.fieldID = ef.fieldID
+            WHERE ed.itemID = i.itemID AND ef.fieldName = 'extra'
+            AND extra_value(ev.value, ?) != ''`
+
+// extraFilter translates "name" or "name=value" into a condition on the
+// extra field, comparing values ignoring case and diacritics
+func extraFilter(spec string) (string, []interface{}) {
+    name, value, ok := strings.Cut(spec, "=")
+    name = strings.TrimSpace(name)
+    if !ok {
+        return extraCondition + ")", []interface{}{name}
+    }
+    return extraCondition + `
+            AND fold(extra_value(ev.value, ?)) = fold(?))`, []interface{}{name, name, strings.TrimSpace(value)}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+    "database/sql"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "log/slog"
+    "net/http"
+    "os"
+    "path/filepath"
+    "time"
+)
+
+// httpClient is shared by every command talking to remote services
+var httpClient = &http.Client{Timeout: 5 * time.Minute}
+
+const itemLibraryIDQuery = `SELECT libraryID FROM items WHERE key = ?`
+
+// apiLibraryPrefix returns the Web API path of the library holding the
+// item, e.g. "users/123" or "groups/456"
+func (r *Repository) apiLibraryPrefix(stableID string) (string, error) {
+    var libraryID int64
+    if err := r.queryRow(itemLibraryIDQuery, stableID).Scan(&libraryID); err != nil {
+        return "", fmt.Errorf("fetching library: %w", err)
+    }
+    return r.apiLibraryPrefixOf(libraryID)
+}
+
+const libraryGroupQuery = `SELECT groupID FROM groups WHERE libraryID = ?`
+
+// apiLibraryPrefixOf returns the Web API path of a library; any library
+// that is not a group, including 0 for none selected, is the user library
+func (r *Repository) apiLibraryPrefixOf(libraryID int64) (string, error) {
+    var groupID int64
+    err := r.queryRow(libraryGroupQuery, libraryID).Scan(&groupID)
+    if errors.Is(err, sql.ErrNoRows) {
+        if r.cfg.UserID == "" {
+            return "", fmt.Errorf("%w: userID is not set", errConfig)
+        }
+        return "users/" + r.cfg.UserID, nil
+    }
+    if err != nil {
+        return "", fmt.Errorf("fetching library: %w", err)
+    }
+    return fmt.Sprintf("groups/%d", groupID), nil
+}
+
+// getJSON decodes the response of a GET request to url into v. A 404
+// response is reported as errNotFound.
+func (c *CLI) getJSON(url string, v interface{}) error {
+    req, err := http.NewRequest(http.MethodGet, url, nil)
+    if err != nil {
+        return fmt.Errorf("creating request: %w", err)
+    }
+    agent := "zotero-fetch/" + c.cfg.Version
+    if c.cfg.Email != "" {
+        agent += " (mailto:" + c.cfg.Email + ")"
+    }
+    req.Header.Set("User-Agent", agent)
+    req.Header.Set("Accept", "application/json")
+
+    start := time.Now()
+    resp, err := httpClient.Do(req)
+    if err != nil {
+        return fmt.Errorf("requesting %s: %w", req.URL, err)
+    }
+    defer resp.Body.Close()
+    slog.Debug("http request",
+        "method", req.Method,
+        "url", req.URL.String(),
+        "status", resp.StatusCode,
+        "duration", time.Since(start))
+
+    if resp.StatusCode == http.StatusNotFound {
+        return fmt.Errorf("%s %w", req.URL, errNotFound)
+    }
+    if resp.StatusCode != http.StatusOK {
+        return fmt.Errorf("requesting %s: %s", req.URL, resp.Status)
+    }
+    if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+        return fmt.Errorf("decoding response of %s: %w", req.URL, err)
+    }
+    return nil
+}
+
+// downloadFile streams the response of req into path, replacing the file
+// only once the download completed
+func downloadFile(req *http.Request, path string) error {
+    start := time.Now()
+    resp, err := httpClient.Do(req)
+    if err != nil {
+        return fmt.Errorf("requesting %s: %w", req.URL, err)
+    }
+    defer resp.Body.Close()
+    slog.Debug("http request",
+        "method", req.Method,
+        "url", req.URL.String(),
+        "status", resp.StatusCode,
+        "duration", time.Since(start))
+
+    if resp.StatusCode != http.StatusOK {
+        return fmt.Errorf("requesting %s: %s", req.URL, resp.Status)
+    }
+
+    if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+        return fmt.Errorf("creating storage folder: %w", err)
+    }
+    tmp, err := os.CreateTemp(filepath.Dir(path), ".download-*")
+    if err != nil {
+        return fmt.Errorf("creating temporary file: %w", err)
+    }
+    defer os.Remove(tmp.Name())
+
+    if _, err := io.Copy(tmp, resp.Body); err != nil {
+        tmp.Close()
+        return fmt.Errorf("downloading %s: %w", req.URL, err)
+    }
+    if err := tmp.Close(); err != nil {
+        return fmt.Errorf("writing %s: %w", tmp.Name(), err)
+    }
+    if err := os.Rename(tmp.Name(), path); err != nil {
+        return fmt.Errorf("moving download into place: %w", err)
+    }
+    return nil
+}
+
+// fetchFromAPI downloads an attachment file from the Zotero Web API into
+// its storage folder
+func (c *CLI) fetchFromAPI(att *Attachment) error {
+    if c.cfg.APIKey == "" {
+        return fmt.Errorf("%w: apiKey is not set", errConfig)
+    }
+    prefix, err := c.repo.apiLibraryPrefix(att.StableID)
+    if err != nil {
+        return err
+    }
+
+    url := fmt.Sprintf("%s/%s/items/%s/file", c.cfg.APIURL, prefix, att.StableID)
+    req, err := http.NewRequest(http.MethodGet, url, nil)
+    if err != nil {
+        return fmt.Errorf("building request: %w", err)
+    }
+    req.Header.Set("Zotero-API-Key", c.cfg.APIKey)
+    req.Header.Set("Zotero-API-Version", "3")
+    req.Header.Set("User-Agent", "zotero-fetch/"+c.cfg.Version)
+
+    return downloadFile(req, att.Path)
+}
+
+// canFetch reports whether a remote source for attachments is configured
+func (c *CLI) canFetch() bool {
+    return c.cfg.WebDAV.URL != "" || c.cfg.APIKey != ""
+}
+
+// fetchAttachment downloads a missing attachment and checks it against the
+// hash Zotero recorded for it
+func (c *CLI) fetchAttachment(att *Attachment) error {
+    fetch := c.fetchFromAPI
+    if c.cfg.WebDAV.URL != "" {
+        fetch = c.fetchFromWebDAV
+    }
+    if err := fetch(att); err != nil {
+        return err
+    }
+
+    status, err := verifyAttachment(att)
+    if err != nil {
+        return err
+    }
+    if status == verifyModified {
+        os.Remove(att.Path)
+        return fmt.Errorf("downloaded file does not match the recorded hash: %s", att.Path)
+    }
+    return nil
+}
+
+// ensureLocal downloads a stored attachment missing from disk when a
+// remote source is configured, so that commands reading files also work
+// on synced libraries whose files were not all downloaded
+func (c *CLI) ensureLocal(att *Attachment) error {
+    if att.Path == "" || att.LinkMode == linkModeLinkedFile || att.Exists() || !c.canFetch() {
+        return nil
+    }
+    if c.dryRun {
+        // stdout carries the output of the command needing the file,
+        // such as the paths of path -0
+        fmt.Fprintf(os.Stderr, "would download %s to %s\n", att.StableID, att.Path)
+        return nil
+    }
+    if err := c.fetchAttachment(att); err != nil {
+        return fmt.Errorf("fetching %s: %w", att.StableID, err)
+    }
+    return nil
+}
+
+// Fetch downloads the item's stored attachments that are missing locally
+func (c *CLI) Fetch(stableID string) error {
+    item, err := c.repo.GetByStableID(stableID)
+    if err != nil {
+        return fmt.Errorf("getting item: %w", err)
+    }
+
+    fetched := 0
+    for _, att := range item.Attachments {
+        if att.Path == "" || att.LinkMode == linkModeLinkedFile || att.Exists() {
+            continue
+        }
+        if c.pretend("download %s to %s", att.StableID, att.Path) {
+            fetched++
+            continue
+        }
+        if err := c.fetchAttachment(att); err != nil {
+            return fmt.Errorf("fetching %s: %w", att.StableID, err)
+        }
+        fmt.Println(att.Path)
+        fetched++
+    }
+
+    if fetched == 0 {
+        return fmt.Errorf("no missing attachment found for item: %s", stableID)
+    }
+    return nil
+}
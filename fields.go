@@ -0,0 +1,95 @@
+package main
+
+import (
+    "database/sql"
+    "errors"
+    "fmt"
+)
+
+const fieldQuery = `
+    SELECT idv.value
+    FROM itemData id
+    JOIN itemDataValues idv ON id.valueID = idv.valueID
+    JOIN fields f ON id.fieldID = f.fieldID
+    WHERE id.itemID = ? AND f.fieldName = ?`
+
+// Field retrieves a single metadata field of an item, returning an empty
+// string when the item does not have it
+func (r *Repository) Field(itemID int64, fieldName string) (string, error) {
+    var value string
+    err := r.queryRow(fieldQuery, itemID, fieldName).Scan(&value)
+    if errors.Is(err, sql.ErrNoRows) {
+        return "", nil
+    }
+    if err != nil {
+        return "", fmt.Errorf("fetching %s: %w", fieldName, err)
+    }
+    return value, nil
+}
+
+const fieldsQuery = `
+    SELECT f.fieldName, idv.value
+    FROM itemData id
+    JOIN itemDataValues idv ON id.valueID = idv.valueID
+    JOIN fields f ON id.fieldID = f.fieldID
+    WHERE id.itemID = ?`
+
+// Fields retrieves every metadata field of an item keyed by field name
+func (r *Repository) Fields(itemID int64) (map[string]string, error) {
+    rows, err := r.query(fieldsQuery, itemID)
+    if err != nil {
+        return nil, fmt.Errorf("executing query: %w", err)
+    }
+    defer rows.Close()
+
+    fields := make(map[string]string)
+    for rows.Next() {
+        var name, value string
+        if err := rows.Scan(&name, &value); err != nil {
+            return nil, fmt.Errorf("scanning row: %w", err)
+        }
+        fields[name] = value
+    }
+
+    if err = rows.Err(); err != nil {
+        return nil, fmt.Errorf("iterating rows: %w", err)
+    }
+
+    return fields, nil
+}
+
+const itemFieldsQuery = `
+    SELECT id.itemID, f.fieldName, idv.value
+    FROM itemData id
+    JOIN itemDataValues idv ON id.valueID = idv.valueID
+    JOIN fields f ON id.fieldID = f.fieldID
+    WHERE id.itemID IN (SELECT value FROM json_each(?))`
+
+// ItemFields retrieves every metadata field of the items with the given
+// IDs in a single query, keyed by item ID and field name
+func (r *Repository) ItemFields(itemIDs []int64) (map[int64]map[string]string, error) {
+    rows, err := r.query(itemFieldsQuery, idList(itemIDs))
+    if err != nil {
+        return nil, fmt.Errorf("executing query: %w", err)
+    }
+    defer rows.Close()
+
+    fields := make(map[int64]map[string]string)
+    for rows.Next() {
+        var itemID int64
+        var name, value string
+        if err := rows.Scan(&itemID, &name, &value); err != nil {
+            return nil, fmt.Errorf("scanning row: %w", err)
+        }
+        if fields[itemID] == nil {
+            fields[itemID] = make(map[string]string)
+        }
+        fields[itemID][name] = value
+    }
+
+    if err = rows.Err(); err != nil {
+        return nil, fmt.Errorf("iterating rows: %w", err)
+    }
+
+    return fields, nil
+}
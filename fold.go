@@ -0,0 +1,64 @@
+package main
+
+import (
+    "database/sql"
+    "fmt"
+    "strings"
+    "unicode"
+
+    "github.com/mattn/go-sqlite3"
+    "golang.org/x/text/cases"
+    "golang.org/x/text/unicode/norm"
+)
+
+// driverName is the sqlite3 driver extended with the fold() SQL function
+const driverName = "sqlite3_zotero"
+
+func init() {
+    sql.Register(driverName, &sqlite3.SQLiteDriver{
+        ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+            if err := conn.RegisterFunc("fold", foldValue, true); err != nil {
+                return err
+            }
+            return conn.RegisterFunc("extra_value", extraValueSQL, true)
+        },
+    })
+}
+
+// sqlText converts an SQL function argument to text, treating NULL as
+// empty text since the functions mostly see the outer joins of the item
+// queries
+func sqlText(v interface{}) string {
+    switch v := v.(type) {
+    case string:
+        return v
+    case []byte:
+        return string(v)
+    case nil:
+        return ""
+    default:
+        return fmt.Sprint(v)
+    }
+}
+
+// foldValue is the SQL entry point of fold
+func foldValue(v interface{}) string {
+    return fold(sqlText(v))
+}
+
+// caseFolder folds case following the Unicode full case folding rules
+var caseFolder = cases.Fold()
+
+// fold normalizes s for comparisons that ignore case and diacritics, so
+// that "muller" matches "Müller": the text is decomposed (NFKD), combining
+// marks are dropped and the remainder is case folded
+func fold(s string) string {
+    var b strings.Builder
+    for _, r := range norm.NFKD.String(s) {
+        if unicode.Is(unicode.Mn, r) {
+            continue
+        }
+        b.WriteRune(r)
+    }
+    return caseFolder.String(b.String())
+}
@@ -0,0 +1,23 @@
+package main
+
+import "testing"
+
+func TestFold(t *testing.T) {
+    tests := []struct {
+        s, folded string
+    }{
+        {"", ""},
+        {"Müller", "muller"},
+        {"MULLER", "muller"},
+        {"Ångström", "angstrom"},
+        {"Straße", "strasse"},
+        {"ﬁeld", "field"},
+        {"Čapek, Karel", "capek, karel"},
+        {"naïve café", "naive cafe"},
+    }
+    for _, test := range tests {
+        if folded := fold(test.s); folded != test.folded {
+            t.Errorf("fold(%q) = %q, expected %q", test.s, folded, test.folded)
+        }
+    }
+}
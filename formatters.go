@@ -0,0 +1,98 @@
+package main
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "os"
+    "os/exec"
+    "slices"
+    "strings"
+)
+
+// formatter returns the command of the configured formatter name, nil
+// when there is none
+func (c *CLI) formatter(name string) []string {
+    fields := strings.Fields(c.cfg.Formatters[name])
+    if len(fields) == 0 {
+        return nil
+    }
+    return fields
+}
+
+// formatterNames lists the configured formatters in lexical order
+func (c *CLI) formatterNames() []string {
+    var names []string
+    for _, name := range sortedKeys(c.cfg.Formatters) {
+        if c.formatter(name) != nil {
+            names = append(names, name)
+        }
+    }
+    return names
+}
+
+// runFormatter runs the formatter command with what input writes, items as
+// JSON lines, streamed to its stdin and its output going to w
+func runFormatter(command []string, w io.Writer, input func(io.Writer) error) error {
+    pr, pw := io.Pipe()
+    written := make(chan error, 1)
+    go func() {
+        err := input(pw)
+        pw.Close()
+        written <- err
+    }()
+
+    cmd := exec.Command(command[0], command[1:]...)
+    cmd.Stdin = pr
+    cmd.Stdout = w
+    cmd.Stderr = os.Stderr
+    err := cmd.Run()
+    // unblocks the input when the formatter quit without reading it all,
+    // which is its own business, like head's
+    pr.Close()
+    if inputErr := <-written; inputErr != nil && !errors.Is(inputErr, io.ErrClosedPipe) && err == nil {
+        return inputErr
+    }
+    if err != nil {
+        return fmt.Errorf("running formatter %s: %w", command[0], err)
+    }
+    return nil
+}
+
+// formatItem prints one item through a formatter
+func (c *CLI) formatItem(command []string, stableID string) error {
+    item, err := c.repo.GetByStableID(stableID)
+    if err != nil {
+        return fmt.Errorf("getting item: %w", err)
+    }
+    encoded, err := c.itemJSON(item, ListOptions{Abstract: true})
+    if err != nil {
+        return err
+    }
+    return runFormatter(command, os.Stdout, func(w io.Writer) error {
+        enc := json.NewEncoder(w)
+        enc.SetEscapeHTML(false)
+        if err := enc.Encode(encoded); err != nil {
+            return fmt.Errorf("encoding json: %w", err)
+        }
+        return nil
+    })
+}
+
+// withFormatters adds the configured formatters to the formats of the
+// commands that accept them
+func (c *CLI) withFormatters(formats map[string][]string) map[string][]string {
+    names := c.formatterNames()
+    if len(names) == 0 {
+        return formats
+    }
+    extended := make(map[string][]string, len(formats))
+    for command, list := range formats {
+        extended[command] = list
+    }
+    for _, command := range []string{"export", "reference"} {
+        extended[command] = append(slices.Clone(formats[command]), names...)
+    }
+    return extended
+}
@@ -0,0 +1,7 @@
+//go:build sqlite_fts5
+
+package main
+
+// fts5Available reports whether the SQLite driver was built with FTS5,
+// which the search index needs
+const fts5Available = true
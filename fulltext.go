@@ -0,0 +1,183 @@
+package main
+
+import (
+    "bytes"
+    "errors"
+    "fmt"
+    "html"
+    "io/fs"
+    "log/slog"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "regexp"
+    "strings"
+    "unicode/utf8"
+)
+
+// ftCacheName is the file Zotero keeps an attachment's extracted text in,
+// inside the attachment's storage folder
+const ftCacheName = ".zotero-ft-cache"
+
+// markupPattern matches the tags and scripts dropped from HTML attachments
+var markupPattern = regexp.MustCompile(`(?is)<(script|style)\b.*?</(script|style)>|<[^>]*>`)
+
+// attachmentText returns the text of an attachment. Zotero's full-text
+// cache is used when the attachment was indexed; otherwise PDFs go through
+// pdftotext, which also marks page breaks, and text and HTML files are
+// read directly.
+func (c *CLI) attachmentText(att *Attachment) (string, error) {
+    cache := filepath.Join(c.cfg.StoragePath, att.StableID, ftCacheName)
+    data, err := os.ReadFile(cache)
+    if err == nil {
+        return string(data), nil
+    }
+    if !errors.Is(err, fs.ErrNotExist) {
+        return "", fmt.Errorf("reading full-text cache: %w", err)
+    }
+    if att.Path == "" || !att.Exists() {
+        return "", fmt.Errorf("%w: %s is not on disk", errNoAttachment, att.StableID)
+    }
+
+    switch {
+    case att.Kind() == "pdf":
+        var stderr bytes.Buffer
+        cmd := exec.Command("pdftotext", "-enc", "UTF-8", att.Path, "-")
+        cmd.Stderr = &stderr
+        out, err := cmd.Output()
+        if msg := bytes.TrimSpace(stderr.Bytes()); err != nil && len(msg) > 0 {
+            return "", fmt.Errorf("running pdftotext: %w: %s", err, msg)
+        }
+        if err != nil {
+            return "", fmt.Errorf("running pdftotext: %w", err)
+        }
+        return string(out), nil
+    case att.Kind() == "html":
+        data, err := os.ReadFile(att.Path)
+        if err != nil {
+            return "", fmt.Errorf("reading attachment: %w", err)
+        }
+        return html.UnescapeString(markupPattern.ReplaceAllString(string(data), " ")), nil
+    case strings.HasPrefix(att.ContentType, "text/"):
+        data, err := os.ReadFile(att.Path)
+        if err != nil {
+            return "", fmt.Errorf("reading attachment: %w", err)
+        }
+        return string(data), nil
+    default:
+        return "", fmt.Errorf("no extracted text for %s (%s), index it in Zotero first", att.StableID, att.ContentType)
+    }
+}
+
+// Text prints the extracted text of the item's main attachment, or of
+// every attachment backed by a file with all
+func (c *CLI) Text(stableID string, all bool) error {
+    item, err := c.repo.GetByStableID(stableID)
+    if err != nil {
+        return fmt.Errorf("getting item: %w", err)
+    }
+
+    var attachments []*Attachment
+    if all {
+        for _, att := range item.Attachments {
+            if att.Path != "" {
+                attachments = append(attachments, att)
+            }
+        }
+    } else if att := storageAttachment(item); att != nil {
+        attachments = append(attachments, att)
+    }
+    if len(attachments) == 0 {
+        return fmt.Errorf("%w for item: %s", errNoAttachment, stableID)
+    }
+
+    for _, att := range attachments {
+        if err := c.ensureLocal(att); err != nil {
+            return err
+        }
+        text, err := c.attachmentText(att)
+        if err != nil {
+            return err
+        }
+        fmt.Print(text)
+        if !strings.HasSuffix(text, "\n") {
+            fmt.Println()
+        }
+    }
+    return nil
+}
+
+// pageBreak separates the pages of text extracted by pdftotext
+const pageBreak = "\f"
+
+// snippetRadius is how much text grep shows on each side of a match
+const snippetRadius = 60
+
+// snippet returns the text around the match at loc in line, with
+// whitespace collapsed and cuts marked
+func snippet(line string, loc []int) string {
+    start, end := max(loc[0]-snippetRadius, 0), min(loc[1]+snippetRadius, len(line))
+    for start > 0 && !utf8.RuneStart(line[start]) {
+        start--
+    }
+    for end < len(line) && !utf8.RuneStart(line[end]) {
+        end++
+    }
+    text := strings.Join(strings.Fields(line[start:end]), " ")
+    if start > 0 {
+        text = "…" + text
+    }
+    if end < len(line) {
+        text += "…"
+    }
+    return text
+}
+
+// Grep prints the lines of the attachment texts of items matching filter
+// that match pattern, with the item's stable ID and, when the text has
+// page breaks, the page
+func (c *CLI) Grep(pattern string, ignoreCase bool, filter Filter) error {
+    if ignoreCase {
+        pattern = "(?i)" + pattern
+    }
+    re, err := regexp.Compile(pattern)
+    if err != nil {
+        return fmt.Errorf("parsing pattern: %w", err)
+    }
+    items, err := c.repo.ListItems(filter)
+    if err != nil {
+        return fmt.Errorf("listing items: %w", err)
+    }
+
+    matched := false
+    for _, item := range items {
+        for _, att := range item.Attachments {
+            if err := c.ensureLocal(att); err != nil {
+                slog.Warn("skipping attachment", "key", att.StableID, "error", err)
+                continue
+            }
+            text, err := c.attachmentText(att)
+            if err != nil {
+                slog.Debug("skipping attachment", "key", att.StableID, "error", err)
+                continue
+            }
+            pages := strings.Split(text, pageBreak)
+            for i, page := range pages {
+                hint := ""
+                if len(pages) > 1 {
+                    hint = fmt.Sprintf("p. %d", i+1)
+                }
+                for _, line := range strings.Split(page, "\n") {
+                    if loc := re.FindStringIndex(line); loc != nil {
+                        fmt.Printf("%s\t%s\t%s\n", item.StableID, hint, snippet(line, loc))
+                        matched = true
+                    }
+                }
+            }
+        }
+    }
+    if !matched {
+        return fmt.Errorf("pattern %w in any attachment: %s", errNotFound, pattern)
+    }
+    return nil
+}
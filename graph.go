@@ -0,0 +1,214 @@
+package main
+
+import (
+    "encoding/xml"
+    "fmt"
+    "io"
+    "os"
+    "regexp"
+    "strings"
+)
+
+// doiPattern finds DOIs in free text such as the extra field
+var doiPattern = regexp.MustCompile(`10\.\d{4,9}/[^\s"<>,;]+`)
+
+// Edge kinds of the relation graph
+const (
+    edgeRelated = "related"
+    edgeCites   = "cites"
+)
+
+// GraphEdge links two items of the relation graph
+type GraphEdge struct {
+    From, To string
+    Kind     string
+}
+
+// Graph is the relation graph of a set of items
+type Graph struct {
+    Items []*Item
+    Edges []GraphEdge
+}
+
+// normalizeDOI lowercases a DOI and strips resolver prefixes and
+// trailing punctuation so that DOIs from different fields compare equal
+func normalizeDOI(doi string) string {
+    doi = strings.ToLower(strings.TrimSpace(doi))
+    for _, prefix := range []string{"https://doi.org/", "http://doi.org/", "https://dx.doi.org/", "doi:"} {
+        doi = strings.TrimPrefix(doi, prefix)
+    }
+    // text around a DOI often closes a parenthesis the DOI never opened,
+    // while DOIs such as 10.1016/S0140-6736(20)30183-5 keep their own
+    depth := 0
+    for i, r := range doi {
+        if r == '(' {
+            depth++
+        } else if r == ')' && depth == 0 {
+            doi = doi[:i]
+            break
+        } else if r == ')' {
+            depth--
+        }
+    }
+    return strings.TrimRight(doi, ".]")
+}
+
+// BuildGraph links items matching filter through Zotero's related items
+// and through DOIs of other items mentioned in their extra field
+func (r *Repository) BuildGraph(filter Filter) (*Graph, error) {
+    items, err := r.ListItems(filter)
+    if err != nil {
+        return nil, fmt.Errorf("listing items: %w", err)
+    }
+    graph := &Graph{Items: items}
+
+    inGraph := make(map[string]bool)
+    byDOI := make(map[string]string)
+    for _, item := range items {
+        inGraph[item.StableID] = true
+        doi, err := r.Field(item.ID, "DOI")
+        if err != nil {
+            return nil, err
+        }
+        if doi != "" {
+            byDOI[normalizeDOI(doi)] = item.StableID
+        }
+    }
+
+    for _, item := range items {
+        related, err := r.RelatedKeys(item.StableID)
+        if err != nil {
+            return nil, fmt.Errorf("fetching related items: %w", err)
+        }
+        for _, key := range related {
+            // relations are symmetric, emit each pair once
+            if inGraph[key] && item.StableID < key {
+                graph.Edges = append(graph.Edges, GraphEdge{From: item.StableID, To: key, Kind: edgeRelated})
+            }
+        }
+
+        extra, err := r.Field(item.ID, "extra")
+        if err != nil {
+            return nil, err
+        }
+        cited := make(map[string]bool)
+        for _, doi := range doiPattern.FindAllString(extra, -1) {
+            key, ok := byDOI[normalizeDOI(doi)]
+            if ok && key != item.StableID && !cited[key] {
+                cited[key] = true
+                graph.Edges = append(graph.Edges, GraphEdge{From: item.StableID, To: key, Kind: edgeCites})
+            }
+        }
+    }
+
+    return graph, nil
+}
+
+// writeDOT renders the graph in Graphviz's DOT language
+func writeDOT(w io.Writer, graph *Graph) error {
+    var b strings.Builder
+    b.WriteString("digraph zotero {\n")
+    b.WriteString("    node [shape=box];\n")
+    for _, item := range graph.Items {
+        fmt.Fprintf(&b, "    %q [label=%q];\n", item.StableID, truncateString(item.Title, 60))
+    }
+    for _, edge := range graph.Edges {
+        attrs := ""
+        if edge.Kind == edgeRelated {
+            attrs = " [dir=none, style=dashed]"
+        }
+        fmt.Fprintf(&b, "    %q -> %q%s;\n", edge.From, edge.To, attrs)
+    }
+    b.WriteString("}\n")
+    _, err := io.WriteString(w, b.String())
+    return err
+}
+
+// graphML mirrors the subset of GraphML needed for the relation graph
+type graphML struct {
+    XMLName xml.Name     `xml:"graphml"`
+    XMLNS   string       `xml:"xmlns,attr"`
+    Keys    []graphMLKey `xml:"key"`
+    Graph   graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+    ID   string `xml:"id,attr"`
+    For  string `xml:"for,attr"`
+    Name string `xml:"attr.name,attr"`
+    Type string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+    EdgeDefault string        `xml:"edgedefault,attr"`
+    Nodes       []graphMLNode `xml:"node"`
+    Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLData struct {
+    Key   string `xml:"key,attr"`
+    Value string `xml:",chardata"`
+}
+
+type graphMLNode struct {
+    ID   string        `xml:"id,attr"`
+    Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+    Source string        `xml:"source,attr"`
+    Target string        `xml:"target,attr"`
+    Data   []graphMLData `xml:"data"`
+}
+
+// writeGraphML renders the graph as GraphML, readable by Gephi and yEd
+func writeGraphML(w io.Writer, graph *Graph) error {
+    doc := graphML{
+        XMLNS: "http://graphml.graphdrawing.org/xmlns",
+        Keys: []graphMLKey{
+            {ID: "title", For: "node", Name: "title", Type: "string"},
+            {ID: "kind", For: "edge", Name: "kind", Type: "string"},
+        },
+        Graph: graphMLGraph{EdgeDefault: "directed"},
+    }
+    for _, item := range graph.Items {
+        doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLNode{
+            ID:   item.StableID,
+            Data: []graphMLData{{Key: "title", Value: item.Title}},
+        })
+    }
+    for _, edge := range graph.Edges {
+        doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{
+            Source: edge.From,
+            Target: edge.To,
+            Data:   []graphMLData{{Key: "kind", Value: edge.Kind}},
+        })
+    }
+
+    if _, err := io.WriteString(w, xml.Header); err != nil {
+        return err
+    }
+    enc := xml.NewEncoder(w)
+    enc.Indent("", "  ")
+    if err := enc.Encode(doc); err != nil {
+        return fmt.Errorf("encoding graphml: %w", err)
+    }
+    _, err := io.WriteString(w, "\n")
+    return err
+}
+
+// Graph prints the relation graph of items matching filter
+func (c *CLI) Graph(filter Filter, format string) error {
+    graph, err := c.repo.BuildGraph(filter)
+    if err != nil {
+        return err
+    }
+    switch format {
+    case "dot":
+        return writeDOT(os.Stdout, graph)
+    case "graphml":
+        return writeGraphML(os.Stdout, graph)
+    default:
+        return fmt.Errorf("unknown graph format: %s", format)
+    }
+}
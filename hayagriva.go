@@ -0,0 +1,187 @@
+package main
+
+import (
+    "cmp"
+    "fmt"
+    "io"
+    "regexp"
+    "slices"
+    "strconv"
+    "strings"
+)
+
+// hayagrivaType describes how a Zotero item type is expressed in
+// Hayagriva: the entry type, and for items published inside another work
+// the type of that parent and the Zotero field holding its title
+type hayagrivaType struct {
+    Type        string
+    Parent      string
+    ParentTitle string
+}
+
+// hayagrivaTypes maps Zotero item types to Hayagriva entry types
+var hayagrivaTypes = map[string]hayagrivaType{
+    "journalArticle":      {"article", "periodical", "publicationTitle"},
+    "magazineArticle":     {"article", "periodical", "publicationTitle"},
+    "newspaperArticle":    {"article", "newspaper", "publicationTitle"},
+    "blogPost":            {"article", "blog", "blogTitle"},
+    "bookSection":         {"chapter", "anthology", "bookTitle"},
+    "conferencePaper":     {"article", "proceedings", "proceedingsTitle"},
+    "encyclopediaArticle": {"entry", "reference", "encyclopediaTitle"},
+    "book":                {Type: "book"},
+    "thesis":              {Type: "thesis"},
+    "report":              {Type: "report"},
+    "webpage":             {"web", "web", "websiteTitle"},
+    "manuscript":          {Type: "manuscript"},
+    "patent":              {Type: "patent"},
+    "case":                {Type: "case"},
+    "statute":             {Type: "legislation"},
+    "film":                {Type: "video"},
+    "videoRecording":      {Type: "video"},
+    "audioRecording":      {Type: "audio"},
+    "podcast":             {Type: "audio"},
+    "artwork":             {Type: "artwork"},
+    "computerProgram":     {Type: "repository"},
+    "preprint":            {Type: "article"},
+}
+
+// hayagrivaCreatorTypes maps Zotero creator roles to Hayagriva name fields
+var hayagrivaCreatorTypes = [][2]string{
+    {"author", "author"},
+    {"editor", "editor"},
+}
+
+// hayagrivaSerials maps Zotero fields to Hayagriva serial numbers
+var hayagrivaSerials = [][2]string{
+    {"DOI", "doi"},
+    {"ISBN", "isbn"},
+    {"ISSN", "issn"},
+}
+
+// isoDatePattern matches the ISO part Zotero keeps at the start of its
+// date field, e.g. "2020-05-01" or "2017-00-00"
+var isoDatePattern = regexp.MustCompile(`^\d{4}(?:-\d{2}(?:-\d{2})?)?`)
+
+// hayagrivaDate reduces a Zotero date to the precision Hayagriva accepts,
+// dropping the zeroed month and day of partial dates
+func hayagrivaDate(date string) string {
+    iso := isoDatePattern.FindString(date)
+    if iso == "" {
+        return yearPattern.FindString(date)
+    }
+    iso = strings.TrimSuffix(iso, "-00")
+    return strings.TrimSuffix(iso, "-00")
+}
+
+// yamlString quotes s as a YAML double-quoted scalar
+func yamlString(s string) string {
+    return strconv.Quote(s)
+}
+
+// writeHayagriva writes a Hayagriva entry for item under the given key
+func writeHayagriva(w io.Writer, key string, item DumpItem) error {
+    kind, ok := hayagrivaTypes[item.ItemType]
+    if !ok {
+        kind = hayagrivaType{Type: "misc"}
+    }
+
+    var b strings.Builder
+    fmt.Fprintf(&b, "%s:\n", yamlString(key))
+    field := func(indent, name, value string) {
+        if value != "" {
+            fmt.Fprintf(&b, "%s%s: %s\n", indent, name, yamlString(value))
+        }
+    }
+
+    fmt.Fprintf(&b, "  type: %s\n", kind.Type)
+    field("  ", "title", item.Fields["title"])
+    for _, role := range hayagrivaCreatorTypes {
+        var names []string
+        for _, creator := range item.Creators {
+            if creator.CreatorType != role[0] {
+                continue
+            }
+            name := creator.LastName
+            if creator.FirstName != "" {
+                name += ", " + creator.FirstName
+            }
+            names = append(names, name)
+        }
+        if len(names) == 0 {
+            continue
+        }
+        fmt.Fprintf(&b, "  %s:\n", role[1])
+        for _, name := range names {
+            fmt.Fprintf(&b, "    - %s\n", yamlString(name))
+        }
+    }
+    field("  ", "date", hayagrivaDate(item.Fields["date"]))
+    field("  ", "edition", item.Fields["edition"])
+    field("  ", "publisher", cmp.Or(item.Fields["publisher"], item.Fields["university"], item.Fields["institution"]))
+    field("  ", "location", item.Fields["place"])
+    field("  ", "language", item.Fields["language"])
+    field("  ", "url", item.Fields["url"])
+
+    var serials []string
+    for _, mapping := range hayagrivaSerials {
+        if value := item.Fields[mapping[0]]; value != "" {
+            serials = append(serials, fmt.Sprintf("    %s: %s\n", mapping[1], yamlString(value)))
+        }
+    }
+    if arxiv := extraValue(item.Fields["extra"], "arXiv"); arxiv != "" {
+        serials = append(serials, fmt.Sprintf("    arxiv: %s\n", yamlString(arxiv)))
+    }
+    if len(serials) > 0 {
+        b.WriteString("  serial-number:\n")
+        b.WriteString(strings.Join(serials, ""))
+    }
+
+    // volume, issue and pages locate the item within its parent
+    indent := "  "
+    parentTitle := item.Fields[kind.ParentTitle]
+    if kind.Parent != "" && (parentTitle != "" || item.Fields["volume"] != "" || item.Fields["issue"] != "") {
+        b.WriteString("  parent:\n")
+        fmt.Fprintf(&b, "    type: %s\n", kind.Parent)
+        field("    ", "title", parentTitle)
+        indent = "    "
+    }
+    field(indent, "volume", item.Fields["volume"])
+    field(indent, "issue", item.Fields["issue"])
+    field("  ", "page-range", item.Fields["pages"])
+    b.WriteString("\n")
+
+    _, err := io.WriteString(w, b.String())
+    return err
+}
+
+// ExportHayagriva writes items matching filter as a Hayagriva bibliography
+// for Typst, keyed by the same citation keys bib uses
+func (c *CLI) ExportHayagriva(w io.Writer, filter Filter) error {
+    items, err := c.repo.ListItems(filter)
+    if err != nil {
+        return fmt.Errorf("listing items: %w", err)
+    }
+    index, err := c.repo.citationKeyIndex()
+    if err != nil {
+        return err
+    }
+    keys := make(map[string]string, len(index))
+    for key, item := range index {
+        keys[item.Key] = key
+    }
+
+    var entries []string
+    for _, item := range items {
+        if key, ok := keys[item.StableID]; ok {
+            entries = append(entries, key)
+        }
+    }
+    slices.Sort(entries)
+
+    for _, key := range entries {
+        if err := writeHayagriva(w, key, index[key]); err != nil {
+            return fmt.Errorf("writing bibliography: %w", err)
+        }
+    }
+    return nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+    "bufio"
+    "errors"
+    "fmt"
+    "log/slog"
+    "os"
+    "path/filepath"
+    "strings"
+    "time"
+)
+
+// lastKeyword stands for the most recently accessed item
+const lastKeyword = "last"
+
+// HistoryEntry records a single access to an item
+type HistoryEntry struct {
+    Time     time.Time
+    Action   string
+    StableID string
+}
+
+// stateProfile is the profile in use, whose history and pins are kept
+// apart from those of the other profiles
+var stateProfile string
+
+// stateDir returns the folder holding local state such as the history
+func stateDir() (string, error) {
+    dir, err := os.UserConfigDir()
+    if err != nil {
+        return "", fmt.Errorf("locating config folder: %w", err)
+    }
+    if stateProfile != "" {
+        return filepath.Join(dir, "zotero-fetch", "profiles", stateProfile), nil
+    }
+    return filepath.Join(dir, "zotero-fetch"), nil
+}
+
+// historyPath returns the location of the history file
+func historyPath() (string, error) {
+    dir, err := stateDir()
+    if err != nil {
+        return "", err
+    }
+    return filepath.Join(dir, "history.tsv"), nil
+}
+
+// recordHistory appends an access to the history file, except under
+// -dry-run. Failing to record is not worth failing the command for, so
+// errors are only logged.
+func (c *CLI) recordHistory(action, stableID string) {
+    if c.dryRun {
+        return
+    }
+    path, err := historyPath()
+    if err == nil {
+        err = os.MkdirAll(filepath.Dir(path), 0755)
+    }
+    var f *os.File
+    if err == nil {
+        f, err = os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+    }
+    if err == nil {
+        _, err = fmt.Fprintf(f, "%s\t%s\t%s\n", time.Now().Format(time.RFC3339), action, stableID)
+        if closeErr := f.Close(); err == nil {
+            err = closeErr
+        }
+    }
+    if err != nil {
+        slog.Warn("recording history", "error", err)
+    }
+}
+
+// readHistory returns the recorded accesses, most recent first
+func readHistory() ([]HistoryEntry, error) {
+    path, err := historyPath()
+    if err != nil {
+        return nil, err
+    }
+    f, err := os.Open(path)
+    if errors.Is(err, os.ErrNotExist) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("opening history: %w", err)
+    }
+    defer f.Close()
+
+    var entries []HistoryEntry
+    scanner := bufio.NewScanner(f)
+    for scanner.Scan() {
+        fields := strings.Split(scanner.Text(), "\t")
+        if len(fields) != 3 {
+            continue
+        }
+        t, err := time.Parse(time.RFC3339, fields[0])
+        if err != nil {
+            continue
+        }
+        entries = append(entries, HistoryEntry{Time: t, Action: fields[1], StableID: fields[2]})
+    }
+    if err := scanner.Err(); err != nil {
+        return nil, fmt.Errorf("reading history: %w", err)
+    }
+
+    for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+        entries[i], entries[j] = entries[j], entries[i]
+    }
+    return entries, nil
+}
+
+// lastAccessed returns the stable ID of the most recently accessed item
+func lastAccessed() (string, error) {
+    entries, err := readHistory()
+    if err != nil {
+        return "", err
+    }
+    if len(entries) == 0 {
+        return "", fmt.Errorf("history %w: no item was opened or referenced yet", errNotFound)
+    }
+    return entries[0].StableID, nil
+}
+
+// History prints the n most recent accesses with the items' titles
+func (c *CLI) History(n int) error {
+    entries, err := readHistory()
+    if err != nil {
+        return err
+    }
+    if n > 0 && len(entries) > n {
+        entries = entries[:n]
+    }
+
+    for _, entry := range entries {
+        title := ""
+        if item, err := c.repo.GetByStableID(entry.StableID); err == nil {
+            title = item.Title
+        }
+        fmt.Printf("%s\t%-9s\t%-8s\t%s\n",
+            entry.Time.Local().Format("2006-01-02 15:04"),
+            entry.Action,
+            entry.StableID,
+            title)
+    }
+    return nil
+}
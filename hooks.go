@@ -0,0 +1,129 @@
+package main
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "io"
+    "log/slog"
+    "net/http"
+    "os"
+    "os/exec"
+    "slices"
+    "strings"
+    "time"
+)
+
+// hookPollInterval is how often serve looks for new items when hooks are
+// configured, besides on every request
+const hookPollInterval = 30 * time.Second
+
+// hookClient POSTs to hook URLs, which should answer quickly
+var hookClient = &http.Client{Timeout: 30 * time.Second}
+
+// HookConfig is run by serve for every new item matching Filter: Command
+// gets the item as JSON on stdin and its key in ZOTERO_KEY, URL is POSTed
+// a hookEvent. Either or both may be set.
+type HookConfig struct {
+    Filter  Filter `json:"filter"`
+    Command string `json:"command"`
+    URL     string `json:"url"`
+}
+
+// hookEvent is the JSON body POSTed to hook URLs
+type hookEvent struct {
+    Event string   `json:"event"`
+    Item  JSONItem `json:"item"`
+}
+
+// checkHooks rejects hooks with nothing to run
+func (cfg Config) checkHooks() error {
+    for i, hook := range cfg.Hooks {
+        if strings.TrimSpace(hook.Command) == "" && hook.URL == "" {
+            return fmt.Errorf("%w: hook %d needs a command or url", errConfig, i)
+        }
+    }
+    return nil
+}
+
+// runHookCommand runs a hook's command for an item
+func runHookCommand(command string, item JSONItem, body []byte) error {
+    fields := strings.Fields(command)
+    cmd := exec.Command(fields[0], fields[1:]...)
+    cmd.Stdin = bytes.NewReader(body)
+    cmd.Env = append(os.Environ(), "ZOTERO_KEY="+item.Key)
+    if out, err := cmd.CombinedOutput(); err != nil {
+        return fmt.Errorf("running %s: %w: %s", fields[0], err, bytes.TrimSpace(out))
+    }
+    return nil
+}
+
+// postHook POSTs an item's event to a hook's URL
+func (c *CLI) postHook(url string, item JSONItem) error {
+    body, err := json.Marshal(hookEvent{Event: "item.added", Item: item})
+    if err != nil {
+        return fmt.Errorf("encoding event: %w", err)
+    }
+    req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+    if err != nil {
+        return fmt.Errorf("creating request: %w", err)
+    }
+    req.Header.Set("Content-Type", "application/json")
+    req.Header.Set("User-Agent", "zotero-fetch/"+c.cfg.Version)
+
+    resp, err := hookClient.Do(req)
+    if err != nil {
+        return fmt.Errorf("requesting %s: %w", req.URL, err)
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode/100 != 2 {
+        message, _ := io.ReadAll(resp.Body)
+        return fmt.Errorf("requesting %s: %s: %s", req.URL, resp.Status, bytes.TrimSpace(message))
+    }
+    return nil
+}
+
+// runHooks runs the configured hooks for the items new to the index.
+// Failures are logged; the other hooks and items go ahead.
+func (c *CLI) runHooks(items []JSONItem) {
+    for i, hook := range c.cfg.Hooks {
+        keys, err := c.repo.ListKeys(hook.Filter)
+        if err != nil {
+            slog.Warn("matching hook filter", "hook", i, "error", err)
+            continue
+        }
+        for _, item := range items {
+            if !slices.Contains(keys, item.Key) {
+                continue
+            }
+            body, err := json.Marshal(item)
+            if err != nil {
+                slog.Warn("encoding item", "key", item.Key, "error", err)
+                continue
+            }
+            if strings.TrimSpace(hook.Command) != "" {
+                if err := runHookCommand(hook.Command, item, body); err != nil {
+                    slog.Warn("running hook", "hook", i, "key", item.Key, "error", err)
+                }
+            }
+            if hook.URL != "" {
+                if err := c.postHook(hook.URL, item); err != nil {
+                    slog.Warn("running hook", "hook", i, "key", item.Key, "error", err)
+                }
+            }
+            slog.Info("ran hook", "hook", i, "key", item.Key)
+        }
+    }
+}
+
+// watchHooks brings the index up to date every hookPollInterval, which
+// runs the hooks for new items even when no requests come in
+func (index *itemIndex) watchHooks() {
+    for range time.Tick(hookPollInterval) {
+        index.mu.Lock()
+        if err := index.refresh(); err != nil {
+            slog.Warn("refreshing index", "error", err)
+        }
+        index.mu.Unlock()
+    }
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+    "encoding/json"
+    "regexp"
+    "strconv"
+    "strings"
+)
+
+// isbnCondition matches items whose ISBN field holds one of a JSON list of
+// ISBNs, compared without hyphens and spaces. The field may list several.
+const isbnCondition = `EXISTS (
+            SELECT 1 FROM itemData bd
+            JOIN itemDataValues bv ON bd.valueID = bv.valueID
+            JOIN fields bf ON bd.fieldID = bf.fieldID
+            WHERE bd.itemID = i.itemID AND bf.fieldName = 'ISBN'
+            AND EXISTS (
+                SELECT 1 FROM json_each(?) isbn
+                WHERE upper(replace(replace(bv.value, '-', ''), ' ', '')) LIKE '%' || isbn.value || '%'))`
+
+// arxivCondition matches items mentioning an arXiv ID where Zotero and
+// its translators put it: the extra field ("arXiv: 2001.01234"), the
+// archive ID of preprints, the arxiv.org URL or the DataCite DOI
+const arxivCondition = `EXISTS (
+            SELECT 1 FROM itemData xd
+            JOIN itemDataValues xv ON xd.valueID = xv.valueID
+            JOIN fields xf ON xd.fieldID = xf.fieldID
+            WHERE xd.itemID = i.itemID
+            AND xf.fieldName IN ('extra', 'archiveID', 'url', 'DOI')
+            AND fold(xv.value) LIKE fold(?))`
+
+// arxivPrefix matches the ways an arXiv ID is commonly written down
+// around the ID itself
+var arxivPrefix = regexp.MustCompile(`(?i)^(https?://(www\.)?arxiv\.org/(abs|pdf)/|arxiv:\s*|10\.48550/arxiv\.)`)
+
+// arxivVersion matches the version suffix of an arXiv ID
+var arxivVersion = regexp.MustCompile(`v\d+$`)
+
+// normalizeArXiv reduces an arXiv ID, URL or DOI to the bare ID without
+// version, such as 2001.01234 or hep-th/9901001
+func normalizeArXiv(id string) string {
+    id = arxivPrefix.ReplaceAllString(strings.TrimSpace(id), "")
+    id = strings.TrimSuffix(id, ".pdf")
+    return arxivVersion.ReplaceAllString(id, "")
+}
+
+// isbnVariants returns the ISBN without separators, along with its
+// ISBN-10 or ISBN-13 counterpart when it has one, as a JSON list
+func isbnVariants(isbn string) string {
+    isbn = strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(isbn))
+    variants := []string{isbn}
+    switch {
+    case len(isbn) == 10:
+        body := "978" + isbn[:9]
+        variants = append(variants, body+isbn13CheckDigit(body))
+    case len(isbn) == 13 && strings.HasPrefix(isbn, "978"):
+        body := isbn[3:12]
+        variants = append(variants, body+isbn10CheckDigit(body))
+    }
+    encoded, _ := json.Marshal(variants)
+    return string(encoded)
+}
+
+// isbn13CheckDigit computes the check digit of the first 12 digits of an
+// ISBN-13
+func isbn13CheckDigit(body string) string {
+    sum := 0
+    for i, r := range body {
+        weight := 1
+        if i%2 == 1 {
+            weight = 3
+        }
+        sum += int(r-'0') * weight
+    }
+    return strconv.Itoa((10 - sum%10) % 10)
+}
+
+// isbn10CheckDigit computes the check digit of the first 9 digits of an
+// ISBN-10
+func isbn10CheckDigit(body string) string {
+    sum := 0
+    for i, r := range body {
+        sum += int(r-'0') * (10 - i)
+    }
+    check := (11 - sum%11) % 11
+    if check == 10 {
+        return "X"
+    }
+    return strconv.Itoa(check)
+}
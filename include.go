@@ -0,0 +1,61 @@
+package main
+
+import (
+    "fmt"
+    "strings"
+)
+
+// inclusion lists the kinds of top-level items listed besides regular
+// items, as a comma-separated value of -include
+type inclusion string
+
+const (
+    includeStandaloneAttachments = "standalone-attachments"
+    includeNotes                 = "notes"
+)
+
+// includeTypes maps every kind accepted by -include to its item type
+var includeTypes = map[string]string{
+    includeStandaloneAttachments: "attachment",
+    includeNotes:                 "note",
+}
+
+// regularItemCondition matches items that are neither attachments nor
+// notes
+const regularItemCondition = "(it.display = 1 AND idv.value IS NOT NULL)"
+
+// String implements flag.Value
+func (in *inclusion) String() string {
+    return string(*in)
+}
+
+// Set implements flag.Value
+func (in *inclusion) Set(value string) error {
+    var kinds []string
+    for _, kind := range strings.Split(value, ",") {
+        kind = strings.TrimSpace(kind)
+        if kind == "" {
+            continue
+        }
+        if _, ok := includeTypes[kind]; !ok {
+            return fmt.Errorf("expected %s or %s", includeStandaloneAttachments, includeNotes)
+        }
+        kinds = append(kinds, kind)
+    }
+    *in = inclusion(strings.Join(kinds, ","))
+    return nil
+}
+
+// condition restricts items to regular ones plus the included kinds
+func (in inclusion) condition() string {
+    var types []string
+    for _, kind := range strings.Split(string(in), ",") {
+        if typeName, ok := includeTypes[kind]; ok {
+            types = append(types, "'"+typeName+"'")
+        }
+    }
+    if len(types) == 0 {
+        return regularItemCondition
+    }
+    return fmt.Sprintf("(%s OR it.typeName IN (%s))", regularItemCondition, strings.Join(types, ", "))
+}
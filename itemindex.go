@@ -0,0 +1,457 @@
+package main
+
+import (
+    "cmp"
+    "fmt"
+    "log/slog"
+    "slices"
+    "strings"
+    "sync"
+    "time"
+)
+
+// libraryStateQuery finds the latest change to any item, children
+// included, counts the items and finds the highest item ID, which
+// together tell the daemon whether its index is behind. Deleting items
+// shows in the count only.
+const libraryStateQuery = `
+    SELECT COALESCE(MAX(dateModified), ''), COUNT(*), COALESCE(MAX(itemID), 0)
+    FROM items`
+
+// libraryState is the result of libraryStateQuery
+type libraryState struct {
+    modified string
+    items    int
+    lastID   int64
+}
+
+// indexFilter selects the items the index holds
+var indexFilter = Filter{
+    Include: inclusion(includeStandaloneAttachments + "," + includeNotes),
+}
+
+// changedItemsQuery lists the top-level items changed since a time, either
+// themselves or through one of their attachments or notes, and those
+// added after an item ID, which synced items carrying an older
+// modification time only show through. Modification times have a
+// resolution of a second, so items changed in the same second as the last
+// refresh are included again.
+const changedItemsQuery = `
+    SELECT DISTINCT p.key
+    FROM items i
+    LEFT JOIN itemAttachments ia ON i.itemID = ia.itemID
+    LEFT JOIN itemNotes n ON i.itemID = n.itemID
+    JOIN items p ON p.itemID = COALESCE(ia.parentItemID, n.parentItemID, i.itemID)
+    WHERE i.dateModified >= ? OR i.itemID > ?`
+
+// newItemsQuery counts the items added after an item ID
+const newItemsQuery = `SELECT COUNT(*) FROM items WHERE itemID > ?`
+
+// missingItemsQuery picks the item IDs of a list that are no longer in
+// the database
+const missingItemsQuery = `
+    SELECT value FROM json_each(?)
+    WHERE value NOT IN (SELECT itemID FROM items)`
+
+// ListKeys retrieves the stable IDs of the items matching filter, in
+// listing order, without loading their children
+func (r *Repository) ListKeys(filter Filter) ([]string, error) {
+    query, args, ok, err := r.itemsQuery(filter)
+    if err != nil || !ok {
+        return nil, err
+    }
+
+    rows, err := r.query(query, args...)
+    if err != nil {
+        return nil, fmt.Errorf("executing query: %w", err)
+    }
+    defer rows.Close()
+
+    var keys []string
+    for rows.Next() {
+        item, err := scanItem(rows)
+        if err != nil {
+            return nil, fmt.Errorf("scanning row: %w", err)
+        }
+        keys = append(keys, item.StableID)
+    }
+
+    if err = rows.Err(); err != nil {
+        return nil, fmt.Errorf("iterating rows: %w", err)
+    }
+
+    return keys, nil
+}
+
+// itemIndex keeps the JSON representation of every item in memory for
+// the daemon, so that requests are answered without going through the
+// database. It catches up with items changed since it was last brought up
+// to date before each request.
+type itemIndex struct {
+    cli *CLI
+
+    // added is told about items new to the index when catching up
+    added func([]JSONItem)
+
+    mu    sync.Mutex
+    byKey map[string]indexedItem
+    state libraryState
+    // checked is when state was read, in the format of dateModified
+    checked string
+}
+
+// indexedItem is an item of the index; the item ID keeps listings in
+// the order items were added in, as in the database
+type indexedItem struct {
+    id      int64
+    encoded JSONItem
+}
+
+// newItemIndex loads every item of the selected library into an index
+func newItemIndex(c *CLI) (*itemIndex, error) {
+    start := time.Now()
+    index := &itemIndex{cli: c, byKey: make(map[string]indexedItem)}
+    index.checked = dateModifiedNow()
+    state, err := index.libraryState()
+    if err != nil {
+        return nil, err
+    }
+    index.state = state
+    err = c.repo.EachItem(indexFilter, func(item *Item) error {
+        return index.load(item)
+    })
+    if err != nil {
+        return nil, fmt.Errorf("loading items: %w", err)
+    }
+    slog.Info("loaded index", "items", len(index.byKey), "duration", time.Since(start))
+    return index, nil
+}
+
+// dateModifiedNow returns the current time as Zotero stores modification
+// times
+func dateModifiedNow() string {
+    return time.Now().UTC().Format(time.DateTime)
+}
+
+// libraryState reads the current state of the library
+func (index *itemIndex) libraryState() (libraryState, error) {
+    var state libraryState
+    if err := index.cli.repo.queryRow(libraryStateQuery).Scan(&state.modified, &state.items, &state.lastID); err != nil {
+        return state, fmt.Errorf("fetching library state: %w", err)
+    }
+    return state, nil
+}
+
+// load puts item into the index, replacing an earlier version of it
+func (index *itemIndex) load(item *Item) error {
+    encoded, err := index.cli.itemJSON(item, rpcOptions)
+    if err != nil {
+        return err
+    }
+    index.byKey[item.StableID] = indexedItem{id: item.ID, encoded: encoded}
+    return nil
+}
+
+// refresh reloads the items changed since the index was last brought up
+// to date and drops deleted ones. The caller holds mu.
+func (index *itemIndex) refresh() error {
+    checked := dateModifiedNow()
+    state, err := index.libraryState()
+    if err != nil {
+        return err
+    }
+    // changes made later in the second the state was last read leave it
+    // as it was, so until that second is over the changed items are
+    // looked up regardless
+    if state == index.state && index.checked > state.modified {
+        return nil
+    }
+
+    // items counted beyond those added since were there before, so fewer
+    // means some were deleted, even when additions hide it in the total
+    var added int
+    if err := index.cli.repo.queryRow(newItemsQuery, index.state.lastID).Scan(&added); err != nil {
+        return fmt.Errorf("counting new items: %w", err)
+    }
+    if state.items < index.state.items+added {
+        if err := index.dropDeleted(); err != nil {
+            return err
+        }
+    }
+    loaded, err := index.reloadChanged()
+    if err != nil {
+        return err
+    }
+    index.state, index.checked = state, checked
+    if len(loaded) > 0 && index.added != nil {
+        go index.added(loaded)
+    }
+    return nil
+}
+
+// dropDeleted removes the items no longer in the database from the index
+func (index *itemIndex) dropDeleted() error {
+    ids := make([]int64, 0, len(index.byKey))
+    keys := make(map[int64]string, len(index.byKey))
+    for key, entry := range index.byKey {
+        ids = append(ids, entry.id)
+        keys[entry.id] = key
+    }
+    rows, err := index.cli.repo.query(missingItemsQuery, idList(ids))
+    if err != nil {
+        return fmt.Errorf("executing query: %w", err)
+    }
+    defer rows.Close()
+    removed := 0
+    for rows.Next() {
+        var id int64
+        if err := rows.Scan(&id); err != nil {
+            return fmt.Errorf("scanning row: %w", err)
+        }
+        delete(index.byKey, keys[id])
+        removed++
+    }
+    if err = rows.Err(); err != nil {
+        return fmt.Errorf("iterating rows: %w", err)
+    }
+    slog.Debug("dropped deleted items", "removed", removed)
+    return nil
+}
+
+// reloadChanged reloads the items changed or added since the index was
+// last brought up to date, returning those new to it
+func (index *itemIndex) reloadChanged() ([]JSONItem, error) {
+    rows, err := index.cli.repo.query(changedItemsQuery, index.state.modified, index.state.lastID)
+    if err != nil {
+        return nil, fmt.Errorf("executing query: %w", err)
+    }
+    defer rows.Close()
+    var keys []string
+    for rows.Next() {
+        var key string
+        if err := rows.Scan(&key); err != nil {
+            return nil, fmt.Errorf("scanning row: %w", err)
+        }
+        keys = append(keys, key)
+    }
+    if err = rows.Err(); err != nil {
+        return nil, fmt.Errorf("iterating rows: %w", err)
+    }
+
+    added, err := index.loadKeys(keys)
+    if err != nil {
+        return nil, err
+    }
+    slog.Debug("refreshed index", "changed", len(keys), "added", len(added))
+    return added, nil
+}
+
+// loadKeys loads the items with the given stable IDs into the index in
+// one batch, dropping those no longer found, and returns the ones new to
+// it
+func (index *itemIndex) loadKeys(keys []string) ([]JSONItem, error) {
+    items, err := index.cli.repo.GetByStableIDs(keys)
+    if err != nil {
+        return nil, fmt.Errorf("getting items: %w", err)
+    }
+    found := make(map[string]bool, len(items))
+    var added []JSONItem
+    for _, item := range items {
+        found[item.StableID] = true
+        _, known := index.byKey[item.StableID]
+        if err := index.load(item); err != nil {
+            return nil, err
+        }
+        if !known {
+            added = append(added, index.byKey[item.StableID].encoded)
+        }
+    }
+    for _, key := range keys {
+        if !found[key] {
+            delete(index.byKey, key)
+        }
+    }
+    return added, nil
+}
+
+// get returns the indexed item with the stable ID
+func (index *itemIndex) get(stableID string) (JSONItem, error) {
+    index.mu.Lock()
+    defer index.mu.Unlock()
+    if err := index.refresh(); err != nil {
+        return JSONItem{}, err
+    }
+    entry, ok := index.byKey[stableID]
+    if !ok {
+        return JSONItem{}, fmt.Errorf("item %w: %s", errNotFound, stableID)
+    }
+    return entry.encoded, nil
+}
+
+// list returns the indexed items matching filter, evaluated against the
+// index. Filters it cannot answer, such as -query or -where, which need
+// fields the index lacks, go through the database instead.
+func (index *itemIndex) list(filter Filter) ([]JSONItem, error) {
+    index.mu.Lock()
+    defer index.mu.Unlock()
+    if err := index.refresh(); err != nil {
+        return nil, err
+    }
+
+    match, ok, err := index.matcher(filter)
+    if err != nil {
+        return nil, err
+    }
+    if !ok {
+        return index.listFromDatabase(filter)
+    }
+    var matched []indexedItem
+    for _, entry := range index.byKey {
+        if match(entry.encoded) {
+            matched = append(matched, entry)
+        }
+    }
+    slices.SortFunc(matched, func(a, b indexedItem) int {
+        return cmp.Compare(a.id, b.id)
+    })
+    encoded := make([]JSONItem, len(matched))
+    for i, entry := range matched {
+        encoded[i] = entry.encoded
+    }
+    return encoded, nil
+}
+
+// matcher returns a function telling whether an indexed item passes
+// filter, or false when filter needs the database
+func (index *itemIndex) matcher(filter Filter) (func(JSONItem) bool, bool, error) {
+    // the index holds what these filters look at; others go to the
+    // database
+    rest := filter
+    rest.Title, rest.TitleWords, rest.AbstractContains, rest.Author = "", "", "", ""
+    rest.Tag, rest.NotTag, rest.Status = "", "", ""
+    rest.Untagged, rest.ColoredTagsOnly, rest.Pinned = false, false, false
+    rest.Collection, rest.Include = "", ""
+    if rest != (Filter{}) {
+        return nil, false, nil
+    }
+
+    var tests []func(JSONItem) bool
+    contains := func(s, part string) bool {
+        return strings.Contains(fold(s), fold(part))
+    }
+
+    types := map[string]bool{}
+    for _, kind := range strings.Split(string(filter.Include), ",") {
+        types[includeTypes[kind]] = true
+    }
+    tests = append(tests, func(e JSONItem) bool {
+        return (e.ItemType != "attachment" && e.ItemType != "note") || types[e.ItemType]
+    })
+
+    titleWords := strings.Fields(filter.TitleWords)
+    if filter.Title != "" {
+        titleWords = append(titleWords, filter.Title)
+    }
+    for _, word := range titleWords {
+        tests = append(tests, func(e JSONItem) bool { return contains(e.Title, word) })
+    }
+    if filter.Tag != "" {
+        tests = append(tests, func(e JSONItem) bool {
+            return slices.ContainsFunc(e.Tags, func(tag string) bool { return contains(tag, filter.Tag) })
+        })
+    }
+    if filter.NotTag != "" {
+        tests = append(tests, func(e JSONItem) bool { return !hasTag(e, filter.NotTag) })
+    }
+    if filter.Status != "" {
+        tag, err := index.cli.cfg.statusTag(string(filter.Status))
+        if err != nil {
+            return nil, false, err
+        }
+        tests = append(tests, func(e JSONItem) bool { return hasTag(e, tag) })
+    }
+    if filter.Untagged {
+        tests = append(tests, func(e JSONItem) bool { return len(e.Tags) == 0 })
+    }
+    if filter.ColoredTagsOnly {
+        tests = append(tests, func(e JSONItem) bool { return len(e.TagColors) > 0 })
+    }
+    if filter.Author != "" {
+        tests = append(tests, func(e JSONItem) bool {
+            return slices.ContainsFunc(e.Creators, func(creator Creator) bool {
+                return contains(creator.FirstName+" "+creator.LastName, filter.Author) ||
+                    contains(creator.LastName+", "+creator.FirstName, filter.Author)
+            })
+        })
+    }
+    if filter.AbstractContains != "" {
+        tests = append(tests, func(e JSONItem) bool { return contains(e.Abstract, filter.AbstractContains) })
+    }
+    if filter.Pinned {
+        keys, err := readPins()
+        if err != nil {
+            return nil, false, err
+        }
+        tests = append(tests, func(e JSONItem) bool { return slices.Contains(keys, e.Key) })
+    }
+    if filter.Collection != "" {
+        root, err := index.cli.repo.findCollection(filter.Collection)
+        if err != nil {
+            return nil, false, err
+        }
+        collections, err := index.cli.repo.ListCollections()
+        if err != nil {
+            return nil, false, fmt.Errorf("listing collections: %w", err)
+        }
+        path := collectionPaths(collections)[root.Key]
+        tests = append(tests, func(e JSONItem) bool {
+            return slices.ContainsFunc(e.Collections, func(p string) bool {
+                return p == path || strings.HasPrefix(p, path+"/")
+            })
+        })
+    }
+
+    return func(e JSONItem) bool {
+        for _, test := range tests {
+            if !test(e) {
+                return false
+            }
+        }
+        return true
+    }, true, nil
+}
+
+// hasTag reports whether the item carries tag, ignoring case and
+// diacritics
+func hasTag(e JSONItem, tag string) bool {
+    return slices.ContainsFunc(e.Tags, func(t string) bool { return fold(t) == fold(tag) })
+}
+
+// listFromDatabase runs filter as a query and returns the matching items
+// from the index. Items the query finds that are missing from the index,
+// such as ones filed without changing their modification time, are
+// loaded on the way.
+func (index *itemIndex) listFromDatabase(filter Filter) ([]JSONItem, error) {
+    keys, err := index.cli.repo.ListKeys(filter)
+    if err != nil {
+        return nil, fmt.Errorf("listing items: %w", err)
+    }
+
+    var missing []string
+    for _, key := range keys {
+        if _, ok := index.byKey[key]; !ok {
+            missing = append(missing, key)
+        }
+    }
+    if _, err := index.loadKeys(missing); err != nil {
+        return nil, err
+    }
+
+    encoded := make([]JSONItem, 0, len(keys))
+    for _, key := range keys {
+        if entry, ok := index.byKey[key]; ok {
+            encoded = append(encoded, entry.encoded)
+        }
+    }
+    return encoded, nil
+}
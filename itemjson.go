@@ -0,0 +1,146 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "io"
+    "os"
+    "time"
+)
+
+// JSONItem is the JSON representation of a top-level item
+type JSONItem struct {
+    Key         string            `json:"key"`
+    ItemType    string            `json:"itemType"`
+    Title       string            `json:"title"`
+    Creators    []Creator         `json:"creators"`
+    Year        string            `json:"year,omitempty"`
+    Publication string            `json:"publication,omitempty"`
+    DOI         string            `json:"doi,omitempty"`
+    DateAdded   string            `json:"dateAdded"`
+    CitationKey string            `json:"citekey,omitempty"`
+    Extra       map[string]string `json:"extra,omitempty"`
+    Abstract    string            `json:"abstract,omitempty"`
+    Note        string            `json:"note,omitempty"`
+    Tags        []string          `json:"tags"`
+    TagColors   map[string]string `json:"tagColors,omitempty"`
+    Collections []string          `json:"collections"`
+    Children    []JSONChild       `json:"children"`
+    Retracted   *Retraction       `json:"retracted,omitempty"`
+    // Cited is the local citation count, set with -cited
+    Cited       *int              `json:"localCitations,omitempty"`
+}
+
+// JSONChild is the JSON representation of an attachment or note
+type JSONChild struct {
+    Key         string `json:"key"`
+    ItemType    string `json:"itemType"`
+    Title       string `json:"title,omitempty"`
+    LinkMode    string `json:"linkMode,omitempty"`
+    ContentType string `json:"contentType,omitempty"`
+    Path        string `json:"path,omitempty"`
+    Exists      *bool  `json:"exists,omitempty"`
+    Size        *int64 `json:"size,omitempty"`
+    Modified    string `json:"modified,omitempty"`
+    Note        string `json:"note,omitempty"`
+}
+
+// itemJSON builds the JSON representation of item including its children
+func (c *CLI) itemJSON(item *Item, opts ListOptions) (JSONItem, error) {
+    encoded := JSONItem{
+        Key:         item.StableID,
+        ItemType:    item.ItemType,
+        Title:       item.Title,
+        Creators:    []Creator{},
+        Year:        item.Year,
+        Publication: item.Publication,
+        DOI:         item.DOI,
+        DateAdded:   item.DateAdded,
+        CitationKey: item.CitationKey,
+        Note:        item.Note,
+        Extra:       item.Extra,
+        Tags:        []string{},
+        Collections: []string{},
+        Children:    []JSONChild{},
+        Retracted:   item.Retraction,
+    }
+    encoded.Creators = append(encoded.Creators, item.Creators...)
+    encoded.Tags = append(encoded.Tags, item.displayTags()...)
+    encoded.TagColors = item.TagColors
+    encoded.Collections = append(encoded.Collections, item.Collections...)
+    if opts.Abstract {
+        encoded.Abstract = item.Abstract.String
+    }
+
+    for _, att := range item.Attachments {
+        exists := att.Exists()
+        child := JSONChild{
+            Key:         att.StableID,
+            ItemType:    "attachment",
+            Title:       att.Title,
+            LinkMode:    att.LinkModeName(),
+            ContentType: att.ContentType,
+            Path:        att.Path,
+            Exists:      &exists,
+        }
+        if opts.Stat && exists {
+            if info, err := os.Stat(att.Path); err == nil {
+                size := info.Size()
+                child.Size = &size
+                child.Modified = info.ModTime().UTC().Format(time.RFC3339)
+            }
+        }
+        encoded.Children = append(encoded.Children, child)
+    }
+
+    for _, note := range item.Notes {
+        encoded.Children = append(encoded.Children, JSONChild{
+            Key:      note.StableID,
+            ItemType: "note",
+            Title:    note.Title,
+            Note:     note.HTML,
+        })
+    }
+
+    return encoded, nil
+}
+
+// printItemsJSON prints items as a JSON array
+func (c *CLI) printItemsJSON(items []*Item, opts ListOptions) error {
+    var cited map[string]int
+    if opts.Cited {
+        var err error
+        if cited, err = c.repo.LocalCitations(); err != nil {
+            return fmt.Errorf("counting local citations: %w", err)
+        }
+    }
+    encoded := make([]JSONItem, 0, len(items))
+    for _, item := range items {
+        e, err := c.itemJSON(item, opts)
+        if err != nil {
+            return err
+        }
+        if opts.Cited {
+            count := cited[item.StableID]
+            e.Cited = &count
+        }
+        encoded = append(encoded, e)
+    }
+    return writeJSON(encoded)
+}
+
+// writeJSON prints v to stdout as indented JSON
+func writeJSON(v interface{}) error {
+    return encodeJSON(os.Stdout, v)
+}
+
+// encodeJSON writes v to w as indented JSON
+func encodeJSON(w io.Writer, v interface{}) error {
+    enc := json.NewEncoder(w)
+    enc.SetIndent("", "  ")
+    enc.SetEscapeHTML(false)
+    if err := enc.Encode(v); err != nil {
+        return fmt.Errorf("encoding json: %w", err)
+    }
+    return nil
+}
@@ -0,0 +1,31 @@
+package main
+
+// defaultJobs is how many files verify, export tree and bundle handle at
+// once unless -jobs says otherwise; the work is bound by IO rather than CPU
+const defaultJobs = 4
+
+// parallel calls fn for the indexes 0 to n-1, jobs calls at once, and
+// returns a channel per index delivering its result, so that callers can
+// report results in order as soon as they are ready
+func parallel[T any](n, jobs int, fn func(i int) T) []chan T {
+    results := make([]chan T, n)
+    for i := range results {
+        results[i] = make(chan T, 1)
+    }
+
+    queue := make(chan int)
+    for range max(jobs, 1) {
+        go func() {
+            for i := range queue {
+                results[i] <- fn(i)
+            }
+        }()
+    }
+    go func() {
+        for i := range n {
+            queue <- i
+        }
+        close(queue)
+    }()
+    return results
+}
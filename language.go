@@ -0,0 +1,41 @@
+package main
+
+import (
+    "encoding/json"
+    "strings"
+
+    "golang.org/x/text/language"
+    "golang.org/x/text/language/display"
+)
+
+// languageCondition matches items whose language field names one of a
+// JSON list of spellings, or is a regional variant of the code
+const languageCondition = `EXISTS (
+            SELECT 1 FROM itemData ld
+            JOIN itemDataValues lv ON ld.valueID = lv.valueID
+            JOIN fields lf ON ld.fieldID = lf.fieldID
+            WHERE ld.itemID = i.itemID AND lf.fieldName = 'language'
+            AND (fold(trim(lv.value)) IN (SELECT fold(value) FROM json_each(?))
+                OR fold(replace(trim(lv.value), '_', '-')) LIKE fold(?)))`
+
+// languageSpellings returns the ways the language field may spell code:
+// the code itself and the language's English and native names. Zotero
+// keeps whatever the translator or the user typed.
+func languageSpellings(code string) string {
+    spellings := []string{code}
+    if tag, err := language.Parse(code); err == nil {
+        for _, name := range []string{display.English.Languages().Name(tag), display.Self.Name(tag)} {
+            if name != "" {
+                spellings = append(spellings, name)
+            }
+        }
+    }
+    encoded, _ := json.Marshal(spellings)
+    return string(encoded)
+}
+
+// languageArgs returns the arguments of languageCondition for code
+func languageArgs(code string) []interface{} {
+    code = strings.TrimSpace(code)
+    return []interface{}{languageSpellings(code), code + "-%"}
+}
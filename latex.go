@@ -0,0 +1,36 @@
+package main
+
+import (
+    "fmt"
+    "regexp"
+)
+
+// latexCitePattern limits -cite to plain LaTeX command names
+var latexCitePattern = regexp.MustCompile(`^[A-Za-z]+\*?$`)
+
+// latexReference formats the item as a LaTeX citation command, optionally
+// preceded by a comment naming the title and attachment for the writer
+func (c *CLI) latexReference(stableID string, opts ReferenceOptions) (string, error) {
+    if !latexCitePattern.MatchString(opts.Cite) {
+        return "", fmt.Errorf("invalid citation command: %s", opts.Cite)
+    }
+    key, err := c.repo.CitationKey(stableID)
+    if err != nil {
+        return "", err
+    }
+    ref := fmt.Sprintf(`\%s{%s}`, opts.Cite, key)
+
+    item, err := c.repo.GetByStableID(stableID)
+    if err != nil {
+        return "", fmt.Errorf("getting item: %w", err)
+    }
+    // retractions are commented on whether asked for or not
+    if !opts.Comment && item.Retraction == nil {
+        return ref, nil
+    }
+    comment := "% " + markedTitle(item)
+    if path := c.getStoragePath(item); path != "" {
+        comment += " (" + path + ")"
+    }
+    return comment + "\n" + ref, nil
+}
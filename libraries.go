@@ -0,0 +1,114 @@
+package main
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+)
+
+// Library represents one of the libraries stored in zotero.sqlite: the
+// personal library, a group library or a feed
+type Library struct {
+    ID        int64
+    Type      string
+    Name      string
+    ItemCount int
+}
+
+// librariesQuery lists the libraries with the names of those that are
+// groups or feeds, read from the sources tables; libraries without a name
+// are called by type and ID
+func librariesQuery(sources []string) string {
+    names := ""
+    joins := ""
+    for _, source := range sources {
+        names += source + ".name, "
+        joins += "\n    LEFT JOIN " + source + " ON l.libraryID = " + source + ".libraryID"
+    }
+    return `
+    SELECT
+        l.libraryID,
+        l.type,
+        COALESCE(` + names + `CASE l.type WHEN 'user' THEN 'My Library'
+            ELSE l.type || ' ' || l.libraryID END) as name,
+        (SELECT COUNT(*) FROM items i
+            JOIN itemTypes it ON i.itemTypeID = it.itemTypeID
+            WHERE i.libraryID = l.libraryID AND it.display = 1) as items
+    FROM libraries l` + joins + `
+    ORDER BY l.libraryID`
+}
+
+// ListLibraries retrieves all libraries in the database
+func (r *Repository) ListLibraries() ([]*Library, error) {
+    var sources []string
+    for _, table := range []string{"groups", "feeds"} {
+        if r.hasTable(table) {
+            sources = append(sources, table)
+        }
+    }
+    rows, err := r.query(librariesQuery(sources))
+    if err != nil {
+        return nil, fmt.Errorf("executing query: %w", err)
+    }
+    defer rows.Close()
+
+    var libraries []*Library
+    for rows.Next() {
+        var lib Library
+        if err := rows.Scan(
+            &lib.ID,
+            &lib.Type,
+            &lib.Name,
+            &lib.ItemCount,
+        ); err != nil {
+            return nil, fmt.Errorf("scanning row: %w", err)
+        }
+        libraries = append(libraries, &lib)
+    }
+
+    if err = rows.Err(); err != nil {
+        return nil, fmt.Errorf("iterating rows: %w", err)
+    }
+
+    return libraries, nil
+}
+
+// UseLibrary scopes every subsequent query to the library with the given
+// name or ID
+func (r *Repository) UseLibrary(nameOrID string) error {
+    libraries, err := r.ListLibraries()
+    if err != nil {
+        return fmt.Errorf("listing libraries: %w", err)
+    }
+
+    id, _ := strconv.ParseInt(nameOrID, 10, 64)
+    for _, lib := range libraries {
+        if lib.ID == id || strings.EqualFold(lib.Name, nameOrID) {
+            r.libraryID = lib.ID
+            return nil
+        }
+    }
+    return fmt.Errorf("library %w: %s", errNotFound, nameOrID)
+}
+
+// scopeConditions returns the conditions restricting items to the
+// selected library, if any
+func (r *Repository) scopeConditions() ([]string, []interface{}) {
+    if r.libraryID == 0 {
+        return nil, nil
+    }
+    return []string{"i.libraryID = ?"}, []interface{}{r.libraryID}
+}
+
+// Libraries prints the libraries available in the database
+func (c *CLI) Libraries() error {
+    libraries, err := c.repo.ListLibraries()
+    if err != nil {
+        return fmt.Errorf("listing libraries: %w", err)
+    }
+
+    for _, lib := range libraries {
+        fmt.Printf("%d\t%-6s\t%d\t%s\n", lib.ID, lib.Type, lib.ItemCount, lib.Name)
+    }
+    return nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+)
+
+// citingTextsQuery reads the texts items mention other items' DOIs in:
+// their extra field and their notes, standalone notes counting for
+// themselves
+const citingTextsQuery = `
+    SELECT i.key, v.value
+    FROM items i
+    JOIN itemData d ON i.itemID = d.itemID
+    JOIN itemDataValues v ON d.valueID = v.valueID
+    WHERE d.fieldID = (SELECT fieldID FROM fields WHERE fieldName = 'extra')
+    AND i.itemID NOT IN (SELECT itemID FROM deletedItems)
+    UNION ALL
+    SELECT i.key, n.note
+    FROM itemNotes n
+    JOIN items i ON i.itemID = COALESCE(n.parentItemID, n.itemID)
+    WHERE n.note LIKE '%10.%'
+    AND i.itemID NOT IN (SELECT itemID FROM deletedItems)`
+
+// relationPairsQuery lists the pairs of related items, in the direction
+// they were recorded
+const relationPairsQuery = `
+    SELECT i.key, SUBSTR(ir.object, -8)
+    FROM itemRelations ir
+    JOIN relationPredicates rp ON ir.predicateID = rp.predicateID
+    JOIN items i ON ir.itemID = i.itemID
+    WHERE rp.predicate = ?`
+
+// citedOrder is the -sort cited order, most cited first; its argument is
+// the JSON object of localCitations
+const citedOrder = " ORDER BY COALESCE((SELECT lc.value FROM json_each(?) lc WHERE lc.key = i.key), 0) DESC, i.itemID"
+
+// LocalCitations counts, by stable ID, the other items of the database
+// that point at each item: related to it in Zotero or mentioning its DOI
+// in their extra field or notes. Items nobody points at are left out.
+func (r *Repository) LocalCitations() (map[string]int, error) {
+    index, err := r.DOIIndex()
+    if err != nil {
+        return nil, err
+    }
+    citers := make(map[string]map[string]bool)
+    cite := func(from, to string) {
+        if from == to {
+            return
+        }
+        if citers[to] == nil {
+            citers[to] = make(map[string]bool)
+        }
+        citers[to][from] = true
+    }
+
+    rows, err := r.query(citingTextsQuery)
+    if err != nil {
+        return nil, fmt.Errorf("executing query: %w", err)
+    }
+    defer rows.Close()
+    for rows.Next() {
+        var key, text string
+        if err := rows.Scan(&key, &text); err != nil {
+            return nil, fmt.Errorf("scanning row: %w", err)
+        }
+        for _, doi := range doiPattern.FindAllString(text, -1) {
+            if cited, ok := index[normalizeDOI(doi)]; ok {
+                cite(key, cited)
+            }
+        }
+    }
+    if err = rows.Err(); err != nil {
+        return nil, fmt.Errorf("iterating rows: %w", err)
+    }
+
+    if r.hasTable("itemRelations") {
+        rows, err := r.query(relationPairsQuery, relationPredicate)
+        if err != nil {
+            return nil, fmt.Errorf("executing query: %w", err)
+        }
+        defer rows.Close()
+        for rows.Next() {
+            var from, to string
+            if err := rows.Scan(&from, &to); err != nil {
+                return nil, fmt.Errorf("scanning row: %w", err)
+            }
+            // relations are symmetric
+            cite(from, to)
+            cite(to, from)
+        }
+        if err = rows.Err(); err != nil {
+            return nil, fmt.Errorf("iterating rows: %w", err)
+        }
+    }
+
+    counts := make(map[string]int, len(citers))
+    for key, from := range citers {
+        counts[key] = len(from)
+    }
+    return counts, nil
+}
+
+// localCitationsArg encodes the local citation counts for citedOrder
+func (r *Repository) localCitationsArg() (string, error) {
+    counts, err := r.LocalCitations()
+    if err != nil {
+        return "", fmt.Errorf("counting local citations: %w", err)
+    }
+    encoded, err := json.Marshal(counts)
+    if err != nil {
+        return "", fmt.Errorf("encoding local citations: %w", err)
+    }
+    return string(encoded), nil
+}
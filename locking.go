@@ -0,0 +1,103 @@
+package main
+
+import (
+    "database/sql"
+    "errors"
+    "fmt"
+    "log/slog"
+    "strings"
+    "time"
+
+    "github.com/mattn/go-sqlite3"
+)
+
+// defaultBusyTimeout is how many milliseconds SQLite waits for Zotero to
+// release a lock, such as during sync, before giving up on a statement
+const defaultBusyTimeout = 5000
+
+// Statements failing on a lock that SQLite does not wait for are retried
+// lockRetries times, pausing lockBackoff and twice as long each time after
+const (
+    lockRetries = 5
+    lockBackoff = 100 * time.Millisecond
+)
+
+// isLocked reports whether err means another connection, usually Zotero,
+// holds a lock on the database
+func isLocked(err error) bool {
+    var sqliteErr sqlite3.Error
+    return errors.As(err, &sqliteErr) &&
+        (sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked)
+}
+
+// databaseDSN returns the data source name opening the database at path
+// with SQLite waiting up to timeout milliseconds for locks
+func databaseDSN(path string, timeout int) string {
+    sep := "?"
+    if strings.Contains(path, "?") {
+        sep = "&"
+    }
+    return fmt.Sprintf("%s%s_busy_timeout=%d", path, sep, timeout)
+}
+
+// retryLocked runs fn, running it again after a growing pause while it
+// fails straight away because the database is locked. SQLite already waits
+// out most locks through the busy timeout, so a failure that took that
+// long is final; this covers locks reported without waiting, such as
+// while Zotero recovers its WAL.
+func retryLocked(fn func() error) error {
+    delay := lockBackoff
+    for attempt := 1; ; attempt++ {
+        start := time.Now()
+        err := fn()
+        if err == nil || !isLocked(err) || attempt == lockRetries || time.Since(start) >= lockBackoff {
+            return err
+        }
+        slog.Debug("database locked, retrying", "attempt", attempt, "delay", delay)
+        time.Sleep(delay)
+        delay *= 2
+    }
+}
+
+// lockedRows are the rows of a query whose first row was already read
+// within retryLocked; the first call to Next hands that row out
+type lockedRows struct {
+    *sql.Rows
+    // readAhead is set until Next is called, first telling whether the
+    // query returned any row
+    readAhead, first bool
+}
+
+// Next prepares the next row for Scan, like sql.Rows.Next
+func (rows *lockedRows) Next() bool {
+    if rows.readAhead {
+        rows.readAhead = false
+        return rows.first
+    }
+    return rows.Rows.Next()
+}
+
+// lockedRow is the result of a single-row query, like sql.Row
+type lockedRow struct {
+    rows *lockedRows
+    err  error
+}
+
+// Scan copies the columns of the row into dest, returning sql.ErrNoRows
+// when there is none, like sql.Row.Scan
+func (row *lockedRow) Scan(dest ...interface{}) error {
+    if row.err != nil {
+        return row.err
+    }
+    defer row.rows.Close()
+    if !row.rows.Next() {
+        if err := row.rows.Err(); err != nil {
+            return err
+        }
+        return sql.ErrNoRows
+    }
+    if err := row.rows.Scan(dest...); err != nil {
+        return err
+    }
+    return row.rows.Close()
+}
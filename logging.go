@@ -0,0 +1,99 @@
+package main
+
+import (
+    "fmt"
+    "log/slog"
+    "os"
+    "strings"
+    "time"
+)
+
+// setupLogging installs the default logger writing to stderr at the given
+// level, as text or as JSON lines
+func setupLogging(level string, asJSON bool) error {
+    var lvl slog.Level
+    if err := lvl.UnmarshalText([]byte(level)); err != nil {
+        return fmt.Errorf("invalid log level %q", level)
+    }
+
+    opts := &slog.HandlerOptions{Level: lvl}
+    var handler slog.Handler = slog.NewTextHandler(os.Stderr, opts)
+    if asJSON {
+        handler = slog.NewJSONHandler(os.Stderr, opts)
+    }
+    slog.SetDefault(slog.New(handler))
+    return nil
+}
+
+// atExit holds the cleanups fatal and usage run before exiting, since
+// os.Exit skips deferred calls
+var atExit []func()
+
+// fatal reports err and exits with the status matching its class
+func fatal(msg string, err error) {
+    code, status := classify(err)
+    if jsonErrors {
+        writeErrorJSON(msg, err, code, status)
+    } else {
+        slog.Error(msg, "error", err, "code", code)
+    }
+    exit(status)
+}
+
+// usage prints a usage message and exits with a non-zero status
+func usage(msg string) {
+    fmt.Fprintln(os.Stderr, msg)
+    exit(1)
+}
+
+// exit runs the atExit cleanups and exits with status
+func exit(status int) {
+    for _, cleanup := range atExit {
+        cleanup()
+    }
+    os.Exit(status)
+}
+
+// compactSQL collapses the whitespace of a query for single-line logging
+func compactSQL(query string) string {
+    return strings.Join(strings.Fields(query), " ")
+}
+
+// query runs a query through a cached prepared statement, logging the
+// SQL and its duration at debug level. SQLite only runs the statement, and
+// meets locks, on reading the first row, so that is read here and retried
+// while the database is locked.
+func (r *Repository) query(query string, args ...interface{}) (*lockedRows, error) {
+    start := time.Now()
+    var rows *lockedRows
+    err := retryLocked(func() error {
+        stmt, release, err := r.prepare(query)
+        if err != nil {
+            return err
+        }
+        first, err := stmt.Query(args...)
+        release()
+        if err != nil {
+            return err
+        }
+        more := first.Next()
+        if err := first.Err(); !more && err != nil {
+            first.Close()
+            return err
+        }
+        rows = &lockedRows{Rows: first, readAhead: true, first: more}
+        return nil
+    })
+    slog.Debug("query",
+        "sql", compactSQL(query),
+        "args", args,
+        "duration", time.Since(start),
+        "error", err)
+    return rows, err
+}
+
+// queryRow runs a single-row query like query, its error deferred to Scan
+func (r *Repository) queryRow(query string, args ...interface{}) *lockedRow {
+    rows, err := r.query(query, args...)
+    return &lockedRow{rows: rows, err: err}
+}
@@ -2,36 +2,153 @@ package main
 
 import (
     "database/sql"
+    "encoding/json"
+    "errors"
     "flag"
     "fmt"
-    "log"
+    "io"
+    "os"
     "os/exec"
-    "path/filepath"
+    "slices"
+    "strconv"
     "strings"
     "unicode/utf8"
-
-    _ "github.com/mattn/go-sqlite3"
 )
 
 // Config holds application-wide configuration
 type Config struct {
-    DBPath      string
-    StoragePath string
-    Version     string
+    DBPath      string `json:"dbPath"`
+    StoragePath string `json:"storagePath"`
+    Version     string `json:"-"`
+
+    // BusyTimeout is how many milliseconds queries wait for Zotero to
+    // release a lock on the database
+    BusyTimeout int `json:"busyTimeout"`
+
+    // Snapshot queries a temporary copy of the database instead, for
+    // when Zotero keeps it locked for as long as it runs
+    Snapshot bool `json:"snapshot"`
+
+    // Zotero Web API access used to download attachments that were not
+    // synced to this machine
+    APIURL string `json:"apiURL"`
+    APIKey string `json:"apiKey"`
+    UserID string `json:"userID"`
+
+    // WebDAV server holding the attachment zips, used instead of the
+    // Web API when configured
+    WebDAV WebDAVConfig `json:"webdav"`
+
+    // Scholarly metadata services. Email is sent along so the services
+    // can reach out instead of blocking heavy use.
+    Email              string `json:"email"`
+    CrossrefURL        string `json:"crossrefURL"`
+    OpenAlexURL        string `json:"openAlexURL"`
+    SemanticScholarURL string `json:"semanticScholarURL"`
+    UnpaywallURL       string `json:"unpaywallURL"`
+
+    // Connector reaches the running Zotero client for edits
+    Connector ConnectorConfig `json:"connector"`
+
+    // Embeddings is the model semsearch compares meanings with
+    Embeddings EmbeddingsConfig `json:"embeddings"`
+
+    // Server secures serve -bind, which answers over the network
+    Server ServerConfig `json:"server"`
+
+    // Hooks are run by serve for new items, e.g. to write literature
+    // notes or notify a chat
+    Hooks []HookConfig `json:"hooks"`
+
+    // Formatters are commands producing export and reference formats of
+    // their own, keyed by format name. They read the items as JSON lines
+    // on stdin and write the output to stdout.
+    Formatters map[string]string `json:"formatters"`
+
+    // Readers are the commands attachments are opened with, keyed by
+    // content type or by kind (pdf, epub or html); the path is appended
+    Readers map[string]string `json:"readers"`
+
+    // AnnotationColors labels annotation colors, keyed by hex value or by
+    // the name of Zotero's palette color, e.g. yellow: quote
+    AnnotationColors map[string]string `json:"annotationColors"`
+
+    // StatusTags maps the reading statuses toread, reading and read to
+    // the tags marking them
+    StatusTags map[string]string `json:"statusTags"`
+
+    // Library scopes all queries to a library name or ID
+    Library string `json:"library"`
+
+    // Defaults sets flag defaults per command, keyed by command and flag
+    // name
+    Defaults map[string]map[string]interface{} `json:"defaults"`
+
+    // Presets are named sets of flags given on the command line as @name,
+    // keyed by name and flag name
+    Presets map[string]map[string]interface{} `json:"presets"`
+
+    // Profiles are named sets of config keys overlaid on the rest, such
+    // as the database of a second Zotero profile; Profile picks the one
+    // used when -profile is not given
+    Profiles map[string]json.RawMessage `json:"profiles"`
+    Profile  string                     `json:"profile"`
 }
 
 // Item represents a Zotero library item with its metadata
 type Item struct {
+    ID          int64
     StableID    string
     Title       string
-    Tags        sql.NullString
-    Attachments sql.NullString
+    // Creators lists the item's creators with their roles, in order
+    Creators    []Creator
+    Tags        []string
+    // TagColors maps the item's colored tags to their "#RRGGBB" color
+    TagColors   map[string]string
+    Abstract    sql.NullString
+    Year        string
+    Publication string
+    DOI         string
+    DateAdded   string
+    // DateModified is when the item last changed, LibraryID the library
+    // holding it
+    DateModified string
+    LibraryID    int64
+    // CitationKey is the key set on the item, empty when bib would
+    // generate one
+    CitationKey string
+    // Extra holds the "Name: value" lines of the extra field
+    Extra       map[string]string
+    // ItemType is Zotero's name for the item type. Note holds the HTML
+    // of standalone notes.
+    ItemType    string
+    Note        string
+    // Collections holds the slash separated paths of the collections the
+    // item is filed in, CollectionKeys their keys
+    Collections    []string
+    CollectionKeys []string
+    Attachments []*Attachment
+    // Notes are the item's child notes, oldest first
+    Notes       []*Note
+    // Fields holds every metadata field set on the item by field name
+    Fields      map[string]string
+    // Retraction is set when the item was retracted
+    Retraction  *Retraction
 }
 
 // Repository handles database operations
 type Repository struct {
     db  *sql.DB
     cfg Config
+
+    // libraryID restricts queries to a single library when non-zero
+    libraryID int64
+    // dryRun leaves the sidecar indexes as they are, searching them as
+    // last built
+    dryRun bool
+
+    stmts       statements
+    schemaCache schemaCache
 }
 
 // NewRepository creates a new Repository instance
@@ -41,66 +158,577 @@ func NewRepository(db *sql.DB, cfg Config) *Repository {
 
 const baseQuery = `
     SELECT 
+        i.itemID,
         i.key,
-        idv.value as title,
-        GROUP_CONCAT(DISTINCT t.name) as tags,
-        GROUP_CONCAT(DISTINCT child.key || ':' || COALESCE(ia.path, '')) as attachments
+        COALESCE(idv.value, inote.title, '') as title,
+        (SELECT av.value FROM itemData ad
+            JOIN itemDataValues av ON ad.valueID = av.valueID
+            WHERE ad.itemID = i.itemID
+            AND ad.fieldID = (SELECT fieldID FROM fields WHERE fieldName = 'abstractNote')) as abstract,
+        (SELECT v.value FROM itemData d
+            JOIN itemDataValues v ON d.valueID = v.valueID
+            JOIN fields f ON d.fieldID = f.fieldID
+            WHERE d.itemID = i.itemID AND f.fieldName = 'date') as date,
+        (SELECT v.value FROM itemData d
+            JOIN itemDataValues v ON d.valueID = v.valueID
+            JOIN fields f ON d.fieldID = f.fieldID
+            WHERE d.itemID = i.itemID AND f.fieldName IN (
+                'publicationTitle', 'proceedingsTitle', 'bookTitle',
+                'websiteTitle', 'blogTitle')
+            LIMIT 1) as publication,
+        (SELECT v.value FROM itemData d
+            JOIN itemDataValues v ON d.valueID = v.valueID
+            JOIN fields f ON d.fieldID = f.fieldID
+            WHERE d.itemID = i.itemID AND f.fieldName = 'DOI') as doi,
+        i.dateAdded,
+        (SELECT v.value FROM itemData d
+            JOIN itemDataValues v ON d.valueID = v.valueID
+            JOIN fields f ON d.fieldID = f.fieldID
+            WHERE d.itemID = i.itemID AND f.fieldName = 'citationKey') as citationKey,
+        (SELECT v.value FROM itemData d
+            JOIN itemDataValues v ON d.valueID = v.valueID
+            JOIN fields f ON d.fieldID = f.fieldID
+            WHERE d.itemID = i.itemID AND f.fieldName = 'extra') as extra,
+        it.typeName,
+        inote.note,
+        i.libraryID,
+        i.dateModified
     FROM items i
     LEFT JOIN itemData id ON i.itemID = id.itemID 
+        AND id.fieldID = (SELECT fieldID FROM fields WHERE fieldName = 'title')
     LEFT JOIN itemDataValues idv ON id.valueID = idv.valueID
     LEFT JOIN itemTypes it ON i.itemTypeID = it.itemTypeID
-    LEFT JOIN itemTags itag ON i.itemID = itag.itemID
-    LEFT JOIN tags t ON itag.tagID = t.tagID
-    LEFT JOIN itemAttachments ia ON (ia.parentItemID = i.itemID OR ia.itemID = i.itemID)
-    LEFT JOIN items child ON ia.itemID = child.itemID
-    WHERE it.display = 1
-        AND id.fieldID = (SELECT fieldID FROM fields WHERE fieldName = 'title')
+    LEFT JOIN itemNotes inote ON i.itemID = inote.itemID
+    WHERE ((it.display = 1 AND idv.value IS NOT NULL)
+            OR it.typeName IN ('attachment', 'note'))
         AND NOT EXISTS (
             SELECT 1 FROM itemAttachments 
             WHERE itemAttachments.itemID = i.itemID 
-            AND itemAttachments.parentItemID IS NOT NULL)`
+            AND itemAttachments.parentItemID IS NOT NULL)
+        AND NOT EXISTS (
+            SELECT 1 FROM itemNotes
+            WHERE itemNotes.itemID = i.itemID
+            AND itemNotes.parentItemID IS NOT NULL)`
 
-// GetByStableID retrieves a single item by its stable ID
-func (r *Repository) GetByStableID(stableID string) (*Item, error) {
-    query := fmt.Sprintf("%s AND i.key = ? GROUP BY i.itemID", baseQuery)
-    
+// itemTitle is the title column of baseQuery, which standalone notes
+// take from their first line
+const itemTitle = "COALESCE(idv.value, inote.title, '')"
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+    Scan(dest ...interface{}) error
+}
+
+// scanItem reads an item from a row of baseQuery
+func scanItem(row rowScanner) (*Item, error) {
     var item Item
-    err := r.db.QueryRow(query, stableID).Scan(
+    var date, publication, doi, citationKey, extra, note sql.NullString
+    if err := row.Scan(
+        &item.ID,
         &item.StableID,
         &item.Title,
-        &item.Tags,
-        &item.Attachments,
-    )
+        &item.Abstract,
+        &date,
+        &publication,
+        &doi,
+        &item.DateAdded,
+        &citationKey,
+        &extra,
+        &item.ItemType,
+        &note,
+        &item.LibraryID,
+        &item.DateModified,
+    ); err != nil {
+        return nil, err
+    }
+    item.Year = yearPattern.FindString(date.String)
+    item.Publication = publication.String
+    item.DOI = doi.String
+    item.Note = note.String
+    item.Extra = parseExtra(extra.String)
+    item.CitationKey = explicitCitationKey(map[string]string{
+        "citationKey": citationKey.String,
+        "extra":       extra.String,
+    })
+    return &item, nil
+}
+
+// idList encodes item IDs as a JSON array for json_each, which keeps
+// batched queries to a single placeholder however many items they cover
+func idList(ids []int64) string {
+    parts := make([]string, len(ids))
+    for i, id := range ids {
+        parts[i] = strconv.FormatInt(id, 10)
+    }
+    return "[" + strings.Join(parts, ",") + "]"
+}
+
+// loadChildren fills in the attachments, notes, fields, creators, tags and
+// collections of items with one batched query each. Keeping them out of
+// the item query lets filenames and tags containing separators survive
+// intact.
+func (r *Repository) loadChildren(items []*Item) error {
+    if len(items) == 0 {
+        return nil
+    }
+    ids := make([]int64, len(items))
+    keys := make([]string, len(items))
+    for i, item := range items {
+        ids[i] = item.ID
+        keys[i] = item.StableID
+    }
+
+    attachments, err := r.Attachments(ids)
+    if err != nil {
+        return fmt.Errorf("fetching attachments: %w", err)
+    }
+    tags, err := r.Tags(ids)
+    if err != nil {
+        return fmt.Errorf("fetching tags: %w", err)
+    }
+    creators, err := r.Creators(ids)
+    if err != nil {
+        return fmt.Errorf("fetching creators: %w", err)
+    }
+    colors, err := r.TagColors(ids)
+    if err != nil {
+        return fmt.Errorf("fetching tag colors: %w", err)
+    }
+    collections, err := r.CollectionPaths(ids)
+    if err != nil {
+        return fmt.Errorf("fetching collections: %w", err)
+    }
+    collectionKeys, err := r.CollectionKeys(ids)
+    if err != nil {
+        return fmt.Errorf("fetching collections: %w", err)
+    }
+    notes, err := r.ChildNotes(ids)
+    if err != nil {
+        return fmt.Errorf("fetching notes: %w", err)
+    }
+    fields, err := r.ItemFields(ids)
+    if err != nil {
+        return fmt.Errorf("fetching fields: %w", err)
+    }
+    retractions, err := r.Retractions(keys)
+    if err != nil {
+        return fmt.Errorf("fetching retractions: %w", err)
+    }
+    for _, item := range items {
+        item.Attachments = attachments[item.ID]
+        item.Tags = tags[item.ID]
+        item.Creators = creators[item.ID]
+        item.TagColors = colors[item.ID]
+        item.Collections = collections[item.ID]
+        item.CollectionKeys = collectionKeys[item.ID]
+        item.Notes = notes[item.ID]
+        item.Fields = fields[item.ID]
+        item.Retraction = retractions[item.StableID]
+    }
+    return nil
+}
+
+// GetByStableID retrieves a single item by its stable ID
+func (r *Repository) GetByStableID(stableID string) (*Item, error) {
+    conditions, args := r.scopeConditions()
+    conditions = append(conditions, "i.key = ?")
+    args = append(args, stableID)
+    query := fmt.Sprintf("%s AND %s", baseQuery, strings.Join(conditions, " AND "))
+
+    item, err := scanItem(r.queryRow(query, args...))
+    if errors.Is(err, sql.ErrNoRows) {
+        return nil, fmt.Errorf("item %w: %s", errNotFound, stableID)
+    }
     if err != nil {
         return nil, fmt.Errorf("fetching item: %w", err)
     }
-    return &item, nil
+
+    if err := r.loadChildren([]*Item{item}); err != nil {
+        return nil, err
+    }
+    return item, nil
+}
+
+// GetByStableIDs retrieves the items with the given stable IDs in a single
+// query, in the order they were added. Keys matching no item are skipped.
+func (r *Repository) GetByStableIDs(stableIDs []string) ([]*Item, error) {
+    if len(stableIDs) == 0 {
+        return nil, nil
+    }
+    encoded, err := json.Marshal(stableIDs)
+    if err != nil {
+        return nil, fmt.Errorf("encoding keys: %w", err)
+    }
+    conditions, args := r.scopeConditions()
+    conditions = append(conditions, "i.key IN (SELECT value FROM json_each(?))")
+    args = append(args, string(encoded))
+    query := fmt.Sprintf("%s AND %s ORDER BY i.itemID", baseQuery, strings.Join(conditions, " AND "))
+
+    rows, err := r.query(query, args...)
+    if err != nil {
+        return nil, fmt.Errorf("executing query: %w", err)
+    }
+    defer rows.Close()
+
+    var items []*Item
+    for rows.Next() {
+        item, err := scanItem(rows)
+        if err != nil {
+            return nil, fmt.Errorf("scanning row: %w", err)
+        }
+        items = append(items, item)
+    }
+
+    if err = rows.Err(); err != nil {
+        return nil, fmt.Errorf("iterating rows: %w", err)
+    }
+
+    if err := r.loadChildren(items); err != nil {
+        return nil, err
+    }
+    return items, nil
 }
 
-// ListItems retrieves items matching the given filters
-func (r *Repository) ListItems(titleFilter, tagFilter string) ([]*Item, error) {
+// Filter narrows down the items returned by ListItems
+type Filter struct {
+    Title     string `json:"title,omitempty"`
+    Tag       string `json:"tag,omitempty"`
+    NotTag    string `json:"notTag,omitempty"`
+    Author    string `json:"author,omitempty"`
+    RelatedTo string `json:"relatedTo,omitempty"`
+    CreatorID int64  `json:"-"`
+    Pinned    bool   `json:"pinned,omitempty"`
+
+    // ColoredTagsOnly keeps items carrying at least one colored tag,
+    // Untagged those without any tag
+    ColoredTagsOnly bool `json:"coloredTagsOnly,omitempty"`
+    Untagged        bool `json:"untagged,omitempty"`
+
+    // Status keeps items in a reading status, see statusTags
+    Status readingStatus `json:"status,omitempty"`
+
+    // Language matches the language field by code, such as en or de
+    Language string `json:"language,omitempty"`
+
+    // Publication matches the journal, conference or proceedings name
+    Publication string `json:"publication,omitempty"`
+
+    // ISBN and ArXiv look items up by identifier, in any common spelling
+    ISBN  string `json:"isbn,omitempty"`
+    ArXiv string `json:"arxiv,omitempty"`
+
+    // Extra matches a "Name: value" line of the extra field, given as
+    // name or name=value
+    Extra string `json:"extra,omitempty"`
+
+    // Collection keeps items filed in a collection or its subcollections,
+    // given by key, name or slash separated path
+    Collection string `json:"collection,omitempty"`
+
+    // Sort orders the items by one of sortOrders instead of the order
+    // they were added in
+    Sort string `json:"sort,omitempty"`
+
+    // Where keeps items an expression over their fields holds for, see
+    // whereFields
+    Where string `json:"where,omitempty"`
+
+    AbstractContains string `json:"abstractContains,omitempty"`
+    Query            string `json:"query,omitempty"`
+    TitleWords       string `json:"titleWords,omitempty"`
+
+    // Feeds and Publications control items of the feed and My
+    // Publications pseudo-libraries
+    Feeds        membership `json:"feeds,omitempty"`
+    Publications membership `json:"publications,omitempty"`
+
+    // View restricts items to one of Zotero's built-in views
+    View view `json:"view,omitempty"`
+
+    // Include adds standalone attachments and notes to regular items
+    Include inclusion `json:"include,omitempty"`
+}
+
+// membership selects whether items of a pseudo-library are included,
+// excluded or exclusively listed
+type membership string
+
+const (
+    membershipInclude membership = "include"
+    membershipExclude membership = "exclude"
+    membershipOnly    membership = "only"
+)
+
+// String implements flag.Value
+func (m *membership) String() string {
+    return string(*m)
+}
+
+// Set implements flag.Value
+func (m *membership) Set(value string) error {
+    switch membership(value) {
+    case membershipInclude, membershipExclude, membershipOnly:
+        *m = membership(value)
+        return nil
+    default:
+        return fmt.Errorf("expected include, exclude or only")
+    }
+}
+
+// condition restricts expr to or away from the pseudo-library subquery
+func (m membership) condition(expr, subquery string) string {
+    switch m {
+    case membershipExclude:
+        return expr + " NOT IN (" + subquery + ")"
+    case membershipOnly:
+        return expr + " IN (" + subquery + ")"
+    default:
+        return ""
+    }
+}
+
+// queryCondition matches a word against every metadata field, creator,
+// tag and child note of an item
+const queryCondition = `(
+            EXISTS (
+                SELECT 1 FROM itemData qd
+                JOIN itemDataValues qv ON qd.valueID = qv.valueID
+                WHERE qd.itemID = i.itemID AND qv.value LIKE ?)
+            OR EXISTS (
+                SELECT 1 FROM itemCreators qic
+                JOIN creators qc ON qic.creatorID = qc.creatorID
+                WHERE qic.itemID = i.itemID
+                AND qc.firstName || ' ' || qc.lastName LIKE ?)
+            OR EXISTS (
+                SELECT 1 FROM itemTags qit
+                JOIN tags qt ON qit.tagID = qt.tagID
+                WHERE qit.itemID = i.itemID AND qt.name LIKE ?)
+            OR EXISTS (
+                SELECT 1 FROM itemNotes qn
+                WHERE qn.parentItemID = i.itemID AND qn.note LIKE ?))`
+
+// queryConditionArgs is the number of placeholders in queryCondition
+const queryConditionArgs = 4
+
+// conditions translates the filter into SQL conditions and their arguments
+func (f Filter) conditions() ([]string, []interface{}) {
+    var conditions []string
+    var args []interface{}
+    if f.Title != "" {
+        conditions = append(conditions, "fold("+itemTitle+") LIKE fold(?)")
+        args = append(args, "%"+f.Title+"%")
+    }
+    if f.Tag != "" {
+        conditions = append(conditions, `EXISTS (
+            SELECT 1 FROM itemTags itag
+            JOIN tags t ON itag.tagID = t.tagID
+            WHERE itag.itemID = i.itemID AND fold(t.name) LIKE fold(?))`)
+        args = append(args, "%"+f.Tag+"%")
+    }
+    if f.NotTag != "" {
+        conditions = append(conditions, `NOT EXISTS (
+            SELECT 1 FROM itemTags xtag
+            JOIN tags xt ON xtag.tagID = xt.tagID
+            WHERE xtag.itemID = i.itemID AND fold(xt.name) = fold(?))`)
+        args = append(args, f.NotTag)
+    }
+    if f.ColoredTagsOnly {
+        conditions = append(conditions, coloredTagsCondition)
+    }
+    if f.Language != "" {
+        conditions = append(conditions, languageCondition)
+        args = append(args, languageArgs(f.Language)...)
+    }
+    if f.Publication != "" {
+        conditions = append(conditions, publicationCondition)
+        args = append(args, "%"+f.Publication+"%")
+    }
+    if f.ISBN != "" {
+        conditions = append(conditions, isbnCondition)
+        args = append(args, isbnVariants(f.ISBN))
+    }
+    if f.ArXiv != "" {
+        conditions = append(conditions, arxivCondition)
+        args = append(args, "%"+normalizeArXiv(f.ArXiv)+"%")
+    }
+    if f.Extra != "" {
+        condition, extraArgs := extraFilter(f.Extra)
+        conditions = append(conditions, condition)
+        args = append(args, extraArgs...)
+    }
+    if f.Untagged {
+        conditions = append(conditions, "NOT EXISTS (SELECT 1 FROM itemTags utag WHERE utag.itemID = i.itemID)")
+    }
+    if f.Author != "" {
+        conditions = append(conditions, `EXISTS (
+            SELECT 1 FROM itemCreators ic
+            JOIN creators c ON ic.creatorID = c.creatorID
+            WHERE ic.itemID = i.itemID
+            AND (fold(c.firstName || ' ' || c.lastName) LIKE fold(?)
+                OR fold(c.lastName || ', ' || c.firstName) LIKE fold(?)))`)
+        args = append(args, "%"+f.Author+"%", "%"+f.Author+"%")
+    }
+    if f.AbstractContains != "" {
+        conditions = append(conditions, `EXISTS (
+            SELECT 1 FROM itemData ad
+            JOIN itemDataValues av ON ad.valueID = av.valueID
+            WHERE ad.itemID = i.itemID
+            AND ad.fieldID = (SELECT fieldID FROM fields WHERE fieldName = 'abstractNote')
+            AND av.value LIKE ?)`)
+        args = append(args, "%"+f.AbstractContains+"%")
+    }
+    for _, word := range strings.Fields(f.TitleWords) {
+        conditions = append(conditions, "fold("+itemTitle+") LIKE fold(?)")
+        args = append(args, "%"+word+"%")
+    }
+    // like Zotero's quick search every word has to match somewhere
+    for _, word := range strings.Fields(f.Query) {
+        conditions = append(conditions, queryCondition)
+        for i := 0; i < queryConditionArgs; i++ {
+            args = append(args, "%"+word+"%")
+        }
+    }
+    if f.RelatedTo != "" {
+        conditions = append(conditions, relatedToCondition)
+        args = append(args, f.RelatedTo, f.RelatedTo)
+    }
+    if c := f.Feeds.condition("i.libraryID",
+        "SELECT libraryID FROM libraries WHERE type = 'feed'"); c != "" {
+        conditions = append(conditions, c)
+    }
+    if c := f.Publications.condition("i.itemID",
+        "SELECT itemID FROM publicationsItems"); c != "" {
+        conditions = append(conditions, c)
+    }
+    if f.CreatorID != 0 {
+        conditions = append(conditions, `EXISTS (
+            SELECT 1 FROM itemCreators ic
+            WHERE ic.itemID = i.itemID AND ic.creatorID = ?)`)
+        args = append(args, f.CreatorID)
+    }
+    conditions = append(conditions, f.View.conditions()...)
+    conditions = append(conditions, f.Include.condition())
+    return conditions, args
+}
+
+// itemsQuery builds the query selecting items matching filter. It
+// reports false when the filter cannot match anything, such as -pinned
+// with no pins.
+func (r *Repository) itemsQuery(filter Filter) (string, []interface{}, bool, error) {
     queryBuilder := strings.Builder{}
     queryBuilder.WriteString(baseQuery)
 
-    var args []interface{}
-    if titleFilter != "" || tagFilter != "" {
-        conditions := make([]string, 0, 2)
-        if titleFilter != "" {
-            conditions = append(conditions, "idv.value LIKE ?")
-            args = append(args, "%"+titleFilter+"%")
+    conditions, args := r.scopeConditions()
+    // databases of older Zotero versions lack the tables of some filters,
+    // which then match nothing or, excluding, everything
+    if !r.hasTable("publicationsItems") {
+        if filter.Publications == membershipOnly {
+            return "", nil, false, nil
+        }
+        filter.Publications = ""
+    }
+    if (filter.ColoredTagsOnly && !r.hasTable("syncedSettings")) ||
+        (filter.RelatedTo != "" && !r.hasTable("itemRelations")) {
+        return "", nil, false, nil
+    }
+    if filter.Query != "" {
+        // the full-text index, when built, answers -query without
+        // scanning every field of the library
+        ids, ok, err := r.searchIndexMatches(filter.Query)
+        if err != nil {
+            return "", nil, false, err
+        }
+        if ok {
+            filter.Query = ""
+            conditions = append(conditions, "i.itemID IN (SELECT value FROM json_each(?))")
+            args = append(args, idList(ids))
+        }
+    }
+    if filter.Status != "" {
+        tag, err := r.cfg.statusTag(string(filter.Status))
+        if err != nil {
+            return "", nil, false, err
         }
-        if tagFilter != "" {
-            conditions = append(conditions, "t.name LIKE ?")
-            args = append(args, "%"+tagFilter+"%")
+        conditions = append(conditions, `EXISTS (
+            SELECT 1 FROM itemTags stag
+            JOIN tags st ON stag.tagID = st.tagID
+            WHERE stag.itemID = i.itemID AND fold(st.name) = fold(?))`)
+        args = append(args, tag)
+    }
+    if filter.Where != "" {
+        condition, whereArgs, err := whereCondition(filter.Where)
+        if err != nil {
+            return "", nil, false, fmt.Errorf("parsing -where: %w", err)
+        }
+        conditions = append(conditions, condition)
+        args = append(args, whereArgs...)
+    }
+    if filter.Collection != "" {
+        ids, err := r.collectionTree(filter.Collection)
+        if err != nil {
+            return "", nil, false, err
         }
-        if len(conditions) > 0 {
-            queryBuilder.WriteString(" AND " + strings.Join(conditions, " AND "))
+        conditions = append(conditions, `EXISTS (
+            SELECT 1 FROM collectionItems ci
+            WHERE ci.itemID = i.itemID
+            AND ci.collectionID IN (SELECT value FROM json_each(?)))`)
+        args = append(args, idList(ids))
+    }
+    filterConditions, filterArgs := filter.conditions()
+    conditions = append(conditions, filterConditions...)
+    args = append(args, filterArgs...)
+    if filter.Pinned {
+        condition, pinnedArgs, ok, err := pinnedCondition()
+        if err != nil || !ok {
+            return "", nil, false, err
         }
+        conditions = append(conditions, condition)
+        args = append(args, pinnedArgs...)
+    }
+    if len(conditions) > 0 {
+        queryBuilder.WriteString(" AND " + strings.Join(conditions, " AND "))
     }
 
-    queryBuilder.WriteString(" GROUP BY i.itemID")
+    if filter.Sort != "" {
+        order, ok := sortOrders[filter.Sort]
+        if !ok {
+            return "", nil, false, fmt.Errorf("unknown sort %q, expected one of %s", filter.Sort, strings.Join(sortedKeys(sortOrders), ", "))
+        }
+        queryBuilder.WriteString(order)
+        if filter.Sort == "cited" {
+            counts, err := r.localCitationsArg()
+            if err != nil {
+                return "", nil, false, err
+            }
+            args = append(args, counts)
+        }
+    } else {
+        queryBuilder.WriteString(filter.View.order())
+    }
+    return queryBuilder.String(), args, true, nil
+}
+
+// ListItems retrieves items matching the given filter
+func (r *Repository) ListItems(filter Filter) ([]*Item, error) {
+    items, err := r.scanItems(filter)
+    if err != nil {
+        return nil, err
+    }
+    if err := r.loadChildren(items); err != nil {
+        return nil, err
+    }
+    return items, nil
+}
+
+// scanItems returns the items matching filter with only the columns of
+// their own row, for callers that load what else they need in batches
+func (r *Repository) scanItems(filter Filter) ([]*Item, error) {
+    query, args, ok, err := r.itemsQuery(filter)
+    if err != nil || !ok {
+        return nil, err
+    }
 
-    rows, err := r.db.Query(queryBuilder.String(), args...)
+    rows, err := r.query(query, args...)
     if err != nil {
         return nil, fmt.Errorf("executing query: %w", err)
     }
@@ -108,16 +736,11 @@ func (r *Repository) ListItems(titleFilter, tagFilter string) ([]*Item, error) {
 
     var items []*Item
     for rows.Next() {
-        var item Item
-        if err := rows.Scan(
-            &item.StableID,
-            &item.Title,
-            &item.Tags,
-            &item.Attachments,
-        ); err != nil {
+        item, err := scanItem(rows)
+        if err != nil {
             return nil, fmt.Errorf("scanning row: %w", err)
         }
-        items = append(items, &item)
+        items = append(items, item)
     }
 
     if err = rows.Err(); err != nil {
@@ -127,10 +750,63 @@ func (r *Repository) ListItems(titleFilter, tagFilter string) ([]*Item, error) {
     return items, nil
 }
 
+// eachItemBatch is the number of items EachItem loads children for at once
+const eachItemBatch = 500
+
+// EachItem calls fn for every item matching filter while the rows are
+// scanned, so that large libraries are never held in memory at once
+func (r *Repository) EachItem(filter Filter, fn func(*Item) error) error {
+    query, args, ok, err := r.itemsQuery(filter)
+    if err != nil || !ok {
+        return err
+    }
+
+    rows, err := r.query(query, args...)
+    if err != nil {
+        return fmt.Errorf("executing query: %w", err)
+    }
+    defer rows.Close()
+
+    var batch []*Item
+    flush := func() error {
+        if err := r.loadChildren(batch); err != nil {
+            return err
+        }
+        for _, item := range batch {
+            if err := fn(item); err != nil {
+                return err
+            }
+        }
+        batch = batch[:0]
+        return nil
+    }
+    for rows.Next() {
+        item, err := scanItem(rows)
+        if err != nil {
+            return fmt.Errorf("scanning row: %w", err)
+        }
+        batch = append(batch, item)
+        if len(batch) == eachItemBatch {
+            if err := flush(); err != nil {
+                return err
+            }
+        }
+    }
+
+    if err = rows.Err(); err != nil {
+        return fmt.Errorf("iterating rows: %w", err)
+    }
+    return flush()
+}
+
 // CLI handles command-line operations
 type CLI struct {
     repo *Repository
     cfg  Config
+
+    // dryRun prints the files commands would write or download, and the
+    // changes they would make to the library, instead of making them
+    dryRun bool
 }
 
 // NewCLI creates a new CLI instance
@@ -138,27 +814,29 @@ func NewCLI(repo *Repository, cfg Config) *CLI {
     return &CLI{repo: repo, cfg: cfg}
 }
 
-// getStoragePath returns the full storage path for an item's attachment
-func (c *CLI) getStoragePath(item *Item) string {
-    if !item.Attachments.Valid || item.Attachments.String == "" {
-        return ""
+// storageAttachment returns the item's attachment backed by a file that
+// is most likely the work itself: a PDF, else an EPUB, else the first one,
+// such as a web snapshot
+func storageAttachment(item *Item) *Attachment {
+    for _, kind := range []string{"pdf", "epub"} {
+        if att := attachmentOfKind(item, kind); att != nil {
+            return att
+        }
     }
-
-    attachments := strings.Split(item.Attachments.String, ",")
-    if len(attachments) == 0 {
-        return ""
+    for _, att := range item.Attachments {
+        if att.Path != "" {
+            return att
+        }
     }
+    return nil
+}
 
-    parts := strings.SplitN(attachments[0], ":", 2)
-    if len(parts) != 2 {
-        return ""
+// getStoragePath returns the full storage path for an item's attachment
+func (c *CLI) getStoragePath(item *Item) string {
+    if att := storageAttachment(item); att != nil {
+        return att.Path
     }
-
-    return filepath.Join(
-        c.cfg.StoragePath,
-        parts[0],
-        strings.TrimPrefix(parts[1], "storage:"),
-    )
+    return ""
 }
 
 func truncateString(s string, n int) string {
@@ -169,154 +847,1263 @@ func truncateString(s string, n int) string {
     return string(runes[:n-3]) + "..."
 }
 
-// printItem formats and prints item information
-func (c *CLI) printItem(item *Item, verbose bool) {
-    if !verbose {
-        fmt.Println(item.StableID)
+// missingMarker flags attachments whose file is absent when verifying
+const missingMarker = "[MISSING]"
+
+// hasMissingAttachment reports whether any of the item's stored files is
+// absent from disk
+func hasMissingAttachment(item *Item) bool {
+    for _, att := range item.Attachments {
+        if att.Path != "" && !att.Exists() {
+            return true
+        }
+    }
+    return false
+}
+
+// printItem formats and prints item information, using table for the
+// verbose layout
+func (c *CLI) printItem(item *Item, opts ListOptions, table *itemTable) {
+    end := opts.recordEnd()
+    if !opts.Verbose {
+        if opts.Verify && hasMissingAttachment(item) {
+            fmt.Printf("%s\t%s%s", item.StableID, missingMarker, end)
+            return
+        }
+        fmt.Print(item.StableID + end)
         return
     }
 
-    title := truncateString(item.Title, 25)
-    tags := ""
-    if item.Tags.Valid {
-        tags = truncateString(item.Tags.String, 15)
-    }
-
-    if item.Attachments.Valid && item.Attachments.String != "" {
-        attachments := strings.Split(item.Attachments.String, ",")
-        for _, att := range attachments {
-            parts := strings.SplitN(att, ":", 2)
-            if len(parts) == 2 {
-                storagePath := filepath.Join(
-                    c.cfg.StoragePath,
-                    parts[0],
-                    strings.TrimPrefix(parts[1], "storage:"),
-                )
-                fmt.Printf("%-8s\t%-25s\t%-15s\t%s\n",
-                    item.StableID,
-                    title,
-                    tags,
-                    storagePath)
+    // items get a row per stored attachment when paths are shown
+    attachments := []*Attachment{nil}
+    if slices.Contains(table.columns, "path") && c.getStoragePath(item) != "" {
+        attachments = nil
+        for _, att := range item.Attachments {
+            if att.Path != "" {
+                attachments = append(attachments, att)
             }
         }
-    } else {
-        fmt.Printf("%-8s\t%-25s\t%-15s\t\n",
-            item.StableID,
-            title,
-            tags)
     }
+    for _, att := range attachments {
+        fmt.Print(table.row(item, att) + end)
+    }
+
+    if opts.Abstract && item.Abstract.Valid && item.Abstract.String != "" {
+        for _, line := range wrapText(item.Abstract.String, 76) {
+            fmt.Printf("    %s%s", line, end)
+        }
+    }
+}
+
+// wrapText breaks s into lines of at most width runes at word boundaries
+func wrapText(s string, width int) []string {
+    var lines []string
+    line := ""
+    for _, word := range strings.Fields(s) {
+        if line != "" && utf8.RuneCountInString(line)+1+utf8.RuneCountInString(word) > width {
+            lines = append(lines, line)
+            line = ""
+        }
+        if line != "" {
+            line += " "
+        }
+        line += word
+    }
+    if line != "" {
+        lines = append(lines, line)
+    }
+    return lines
 }
 
-// List displays items matching the given filters
-func (c *CLI) List(titleFilter, tagFilter string, verbose bool) error {
-    items, err := c.repo.ListItems(titleFilter, tagFilter)
+// ListOptions controls how listed items are rendered
+type ListOptions struct {
+    Verbose  bool
+    Verify   bool
+    Abstract bool
+    Format   string
+
+    // Columns selects and orders the columns of verbose output, e.g.
+    // "key,year,citekey"
+    Columns string
+
+    // Print0 ends records with a NUL byte instead of a newline, for
+    // xargs -0
+    Print0 bool
+
+    // Stat adds the size and modification time of attachment files
+    Stat bool
+
+    // Cited adds how many other items of the library point at each item,
+    // see LocalCitations
+    Cited bool
+
+    // Keep and Print are Starlark expressions filtering and printing
+    // items, Script a Starlark file doing the same, see itemScript
+    Keep   string
+    Print  string
+    Script string
+}
+
+// recordEnd returns the terminator of text output records
+func (opts ListOptions) recordEnd() string {
+    if opts.Print0 {
+        return "\x00"
+    }
+    return "\n"
+}
+
+// printItems renders items in the requested output format
+func (c *CLI) printItems(items []*Item, opts ListOptions) error {
+    script, err := opts.itemScript()
     if err != nil {
-        return fmt.Errorf("listing items: %w", err)
+        return err
+    }
+    if script != nil {
+        if items, err = script.filter(items); err != nil {
+            return err
+        }
     }
+    return c.printKept(items, opts, script)
+}
 
-    for _, item := range items {
-        c.printItem(item, verbose)
+// printKept renders items the scripts, if any, already kept, through
+// -print or format(item) when they are given
+func (c *CLI) printKept(items []*Item, opts ListOptions, script *itemScript) error {
+    if script != nil && script.formats() {
+        return script.print(items, opts.recordEnd())
+    }
+
+    switch opts.Format {
+    case "", "text":
+        if opts.Columns != "" || opts.Stat || opts.Cited {
+            opts.Verbose = true
+        }
+        table, err := c.newItemTable(opts)
+        if err != nil {
+            return err
+        }
+        for _, item := range items {
+            c.printItem(item, opts, table)
+        }
+        return nil
+    case "json":
+        return c.printItemsJSON(items, opts)
+    case "alfred":
+        return c.printItemsAlfred(items)
+    default:
+        return fmt.Errorf("unknown format: %s", opts.Format)
     }
-    return nil
+}
+
+// List displays items matching the given filter
+func (c *CLI) List(filter Filter, opts ListOptions) error {
+    items, err := c.repo.ListItems(filter)
+    if err != nil {
+        return fmt.Errorf("listing items: %w", err)
+    }
+
+    return c.printItems(items, opts)
 }
 
 // Open launches the default application for the item's attachment
 func (c *CLI) Open(stableID string) error {
+    return c.OpenKind(stableID, "")
+}
+
+// OpenKind opens the item's attachment of the given kind (pdf, epub or
+// html), or the one storageAttachment picks when kind is empty
+func (c *CLI) OpenKind(stableID, kind string) error {
     item, err := c.repo.GetByStableID(stableID)
     if err != nil {
         return fmt.Errorf("getting item: %w", err)
     }
 
-    path := c.getStoragePath(item)
-    if path == "" {
-        return fmt.Errorf("no attachment found for item: %s", stableID)
+    var att *Attachment
+    if kind == "" {
+        att = storageAttachment(item)
+    } else {
+        att = attachmentOfKind(item, kind)
+    }
+    if att == nil && kind != "" {
+        return fmt.Errorf("%w for item: %s (no %s)", errNoAttachment, stableID, kind)
+    }
+    if att == nil {
+        return fmt.Errorf("%w for item: %s", errNoAttachment, stableID)
+    }
+    if err := c.ensureLocal(att); err != nil {
+        return err
+    }
+    // under -dry-run a file still to be downloaded is not there to open
+    if c.dryRun && !att.Exists() {
+        return nil
+    }
+    path := att.Path
+
+    // a folder means the snapshot's filename was not recorded
+    if info, err := os.Stat(path); err == nil && info.IsDir() {
+        if path, err = snapshotFile(path); err != nil {
+            return fmt.Errorf("resolving snapshot: %w", err)
+        }
     }
 
     cmd := exec.Command("open", path)
+    if reader := c.reader(att); reader != nil {
+        cmd = exec.Command(reader[0], append(reader[1:], path)...)
+    }
     if err := cmd.Run(); err != nil {
         return fmt.Errorf("opening file: %w", err)
     }
     return nil
 }
 
-// Reference generates a reference link for the item
-func (c *CLI) Reference(stableID string) error {
+// reader returns the command configured for the attachment's content
+// type or kind, nil to leave it to the system's file associations
+func (c *CLI) reader(att *Attachment) []string {
+    for _, key := range []string{att.ContentType, att.Kind()} {
+        if fields := strings.Fields(c.cfg.Readers[key]); key != "" && len(fields) > 0 {
+            return fields
+        }
+    }
+    return nil
+}
+
+// OpenURL launches the browser on the item's URL field
+func (c *CLI) OpenURL(stableID string) error {
     item, err := c.repo.GetByStableID(stableID)
     if err != nil {
         return fmt.Errorf("getting item: %w", err)
     }
 
-    path := c.getStoragePath(item)
-    if path == "" {
-        return fmt.Errorf("no attachment found for item: %s", stableID)
+    url, err := c.repo.Field(item.ID, "url")
+    if err != nil {
+        return fmt.Errorf("getting url: %w", err)
+    }
+    if url == "" {
+        return fmt.Errorf("no url found for item: %s", stableID)
+    }
+
+    cmd := exec.Command("open", url)
+    if err := cmd.Run(); err != nil {
+        return fmt.Errorf("opening url: %w", err)
     }
+    return nil
+}
 
-    tags := ""
-    if item.Tags.Valid {
-        tags = item.Tags.String
+// ReferenceOptions controls how reference formats the item
+type ReferenceOptions struct {
+    Format string
+    // Cite is the LaTeX citation command, e.g. autocite or citep
+    Cite string
+    // Comment adds the title and attachment path as a LaTeX comment
+    Comment bool
+}
+
+// Reference generates a reference to the item in the requested format, or
+// through a configured formatter
+func (c *CLI) Reference(stableID string, opts ReferenceOptions) error {
+    var ref string
+    var err error
+    switch opts.Format {
+    case "", "markdown":
+        ref, err = c.reference(stableID)
+    case "latex":
+        ref, err = c.latexReference(stableID, opts)
+    case "wikipedia":
+        ref, err = c.wikipediaReference(stableID)
+    default:
+        command := c.formatter(opts.Format)
+        if command == nil {
+            return fmt.Errorf("unknown reference format: %s", opts.Format)
+        }
+        return c.formatItem(command, stableID)
     }
+    if err != nil {
+        return err
+    }
+    fmt.Println(ref)
+    return nil
+}
 
-    tags = "{" + tags + "}"
+// reference formats the reference link for the item
+func (c *CLI) reference(stableID string) (string, error) {
+    item, err := c.repo.GetByStableID(stableID)
+    if err != nil {
+        return "", fmt.Errorf("getting item: %w", err)
+    }
+
+    path := c.getStoragePath(item)
+    if path == "" {
+        return "", fmt.Errorf("%w for item: %s", errNoAttachment, stableID)
+    }
+
+    tags := "{" + strings.Join(item.displayTags(), ",") + "}"
     if tags == "{}" {
         tags = "{}"
     }
-    fmt.Printf("[zotero: %s, stableid: %s, tags: %s, version: %s](%s)\n",
-        item.Title,
+    return fmt.Sprintf("[zotero: %s, stableid: %s, tags: %s, version: %s](%s)",
+        markedTitle(item),
         item.StableID,
         tags,
         c.cfg.Version,
-        path)
-    return nil
+        path), nil
 }
 
-func main() {
-    cfg := Config{
-        DBPath:      "/Users/username/data/zotero/zotero.sqlite",
-        StoragePath: "/Users/username/data/zotero/storage/",
-        Version:     "1.0",
+// bindFilterFlags registers the item filter flags on fs, keeping any
+// values already present in filter as defaults
+func bindFilterFlags(fs *flag.FlagSet, filter *Filter) {
+    fs.StringVar(&filter.Title, "f", filter.Title, "Find items by title")
+    fs.StringVar(&filter.Tag, "t", filter.Tag, "Find items by tag")
+    fs.StringVar(&filter.NotTag, "T", filter.NotTag, "Leave out items carrying the tag")
+    fs.StringVar(&filter.NotTag, "not-tag", filter.NotTag, "Same as -T")
+    fs.BoolVar(&filter.ColoredTagsOnly, "colored-tags-only", filter.ColoredTagsOnly, "Find items carrying a colored tag")
+    fs.BoolVar(&filter.Untagged, "untagged", filter.Untagged, "Find items without any tag")
+    fs.Var(&filter.Status, "status", "Find items in a reading status: toread, reading or read")
+    fs.StringVar(&filter.Language, "language", filter.Language, "Find items in a language, by code such as en, de or ru")
+    fs.StringVar(&filter.Publication, "publication", filter.Publication, "Find items by journal, conference or proceedings name")
+    fs.StringVar(&filter.ISBN, "isbn", filter.ISBN, "Find items by ISBN-10 or ISBN-13, with or without hyphens")
+    fs.StringVar(&filter.ArXiv, "arxiv", filter.ArXiv, "Find items by arXiv ID, URL or DOI")
+    fs.StringVar(&filter.Extra, "extra", filter.Extra, "Find items by a line of the extra field: name or name=value, e.g. PMID=998877")
+    fs.StringVar(&filter.Collection, "collection", filter.Collection, "Find items in a collection or its subcollections, by key, name or path")
+    fs.StringVar(&filter.Author, "a", filter.Author, "Find items by author")
+    fs.StringVar(&filter.RelatedTo, "related-to", filter.RelatedTo, "Find items related to the given stable ID")
+    fs.StringVar(&filter.Query, "query", filter.Query, "Find items with every word in any field, creator, tag or note")
+    fs.StringVar(&filter.AbstractContains, "abstract-contains", filter.AbstractContains, "Find items whose abstract contains the text")
+    fs.StringVar(&filter.Sort, "sort", filter.Sort, "Order items by "+strings.Join(sortedKeys(sortOrders), ", "))
+    fs.StringVar(&filter.Where, "where", filter.Where, `Find items by an expression, e.g. "authors > 5 and year < 2015"`)
+    fs.BoolVar(&filter.Pinned, "pinned", filter.Pinned, "Find pinned items only")
+    fs.Var(&filter.Feeds, "feeds", "Feed items: include, exclude or only")
+    fs.Var(&filter.Publications, "publications", "My Publications items: include, exclude or only")
+    fs.Var(&filter.View, "view", "Zotero view: trash, unfiled, duplicates or recent")
+    fs.Var(&filter.Include, "include", "Also list standalone-attachments, notes or both, comma-separated")
+}
+
+// configPathOrDefault returns path, falling back to the default location
+func configPathOrDefault(path string) string {
+    if path != "" {
+        return path
     }
+    return defaultConfigPath()
+}
 
-    titleFlag := flag.String("f", "", "Find items by title")
-    tagFlag := flag.String("t", "", "Find items by tag")
-    verboseFlag := flag.Bool("v", false, "Verbose output")
+// parseArgs parses fs from args, accepting flags placed after positional
+// arguments, and returns the positional arguments. Configured defaults
+// are applied first and presets expanded.
+func parseArgs(fs *flag.FlagSet, args []string) []string {
+    applyFlagDefaults(fs, fs.Name())
+    args = expandPresets(args)
+    var positional []string
+    for {
+        fs.Parse(args)
+        args = fs.Args()
+        if len(args) == 0 {
+            return positional
+        }
+        positional = append(positional, args[0])
+        args = args[1:]
+    }
+}
+
+// bindListFlags registers the output flags shared by listing commands
+func bindListFlags(fs *flag.FlagSet, opts *ListOptions) {
+    fs.BoolVar(&opts.Verbose, "v", opts.Verbose, "Verbose output")
+    fs.BoolVar(&opts.Verify, "verify", opts.Verify, "Mark attachments missing from disk")
+    fs.BoolVar(&opts.Abstract, "abstract", opts.Abstract, "Include abstracts in verbose and JSON output")
+    fs.StringVar(&opts.Format, "format", opts.Format, "Output format: text, json or alfred")
+    fs.StringVar(&opts.Columns, "columns", opts.Columns, "Verbose columns in order: key, title, year, tags, path, citekey, size, modified, collections, cited")
+    fs.BoolVar(&opts.Stat, "stat", opts.Stat, "Show the size and modification time of attachment files")
+    fs.BoolVar(&opts.Cited, "cited", opts.Cited, "Show how many other items relate to or mention the DOI of each item")
+    fs.StringVar(&opts.Keep, "keep", opts.Keep, `Keep items a Starlark expression over their fields holds for, e.g. "len(authors) > 5 and year < 2015"`)
+    fs.StringVar(&opts.Print, "print", opts.Print, `Print each item as a Starlark expression over its fields, e.g. "'%s (%d)' % (title, year)"`)
+    fs.StringVar(&opts.Script, "script", opts.Script, "Starlark file defining keep(item) to filter items and format(item) to print them")
+    bindPrint0Flag(fs, &opts.Print0)
+}
+
+// bindPrint0Flag registers -0 and its long form -print0
+func bindPrint0Flag(fs *flag.FlagSet, print0 *bool) {
+    fs.BoolVar(print0, "0", *print0, "End records with NUL instead of newline, for xargs -0")
+    fs.BoolVar(print0, "print0", *print0, "Same as -0")
+}
+
+func main() {
+    var filter Filter
+    var opts ListOptions
+    configPath := flag.String("config", "", "Path to the JSON config file")
+    library := flag.String("library", "", "Restrict queries to a library name or ID")
+    profile := flag.String("profile", "", "Use a named profile of the config file")
+    logLevel := flag.String("log-level", "info", "Log level: debug, info, warn or error")
+    logJSON := flag.Bool("log-json", false, "Write logs as JSON lines")
+    snapshot := flag.Bool("snapshot", false, "Query a copy of the database, for when Zotero keeps it locked")
+    dryRun := flag.Bool("dry-run", false, "Print the files commands would write or download, and the library changes they would make, instead of making them")
+    flag.BoolVar(&jsonErrors, "json-errors", false, "Report fatal errors as JSON objects on stderr")
+    bindFilterFlags(flag.CommandLine, &filter)
+    bindListFlags(flag.CommandLine, &opts)
     flag.Parse()
 
-    db, err := sql.Open("sqlite3", cfg.DBPath)
+    if err := setupLogging(*logLevel, *logJSON); err != nil {
+        usage(err.Error())
+    }
+
+    cfg, err := loadConfig(configPathOrDefault(*configPath), *configPath != "")
+    if err != nil {
+        fatal("Error loading config", fmt.Errorf("%w: %w", errConfig, err))
+    }
+    if *profile != "" {
+        cfg.Profile = *profile
+    }
+    if cfg.Profile != "" {
+        if err := cfg.useProfile(cfg.Profile); err != nil {
+            fatal("Error loading config", err)
+        }
+        stateProfile = cfg.Profile
+    }
+
+    dbPath := cfg.DBPath
+    if *snapshot || cfg.Snapshot {
+        path, cleanup, err := snapshotDatabase(cfg.DBPath)
+        if err != nil {
+            fatal("Error copying database", err)
+        }
+        defer cleanup()
+        atExit = append(atExit, cleanup)
+        dbPath = path
+    }
+    db, err := sql.Open(driverName, databaseDSN(dbPath, cfg.BusyTimeout))
     if err != nil {
-        log.Fatalf("Error opening database: %v", err)
+        fatal("Error opening database", err)
     }
     defer db.Close()
 
     repo := NewRepository(db, cfg)
+    defer repo.Close()
+    if *library != "" {
+        cfg.Library = *library
+    }
+    if cfg.Library != "" {
+        if err := repo.UseLibrary(cfg.Library); err != nil {
+            fatal("Error selecting library", err)
+        }
+    }
     cli := NewCLI(repo, cfg)
+    cli.dryRun = *dryRun
+    repo.dryRun = *dryRun
+
+    flag.Visit(func(f *flag.Flag) {
+        explicitGlobalFlags[f.Name] = true
+    })
+    flagDefaults = cfg.Defaults
+    flagPresets = cfg.Presets
 
     args := flag.Args()
+    if len(args) > 0 && strings.HasPrefix(args[0], "@") && flagPresets[args[0][1:]] != nil {
+        // a preset in place of the command lists its items
+        args = append([]string{"list"}, args...)
+    }
     if len(args) == 0 {
-        if err := cli.List(*titleFlag, *tagFlag, *verboseFlag); err != nil {
-            log.Fatalf("Error listing items: %v", err)
+        applyFlagDefaults(flag.CommandLine, "list")
+        if err := cli.List(filter, opts); err != nil {
+            fatal("Error listing items", err)
         }
         return
     }
 
     command := args[0]
     switch command {
+    case "list":
+        fs := flag.NewFlagSet("list", flag.ExitOnError)
+        bindFilterFlags(fs, &filter)
+        bindListFlags(fs, &opts)
+        byAuthor := fs.String("by-author", "", "Group items under each creator matching the name")
+        parseFlags(fs, args[1:])
+        if *byAuthor != "" {
+            if err := cli.ListByAuthor(*byAuthor, filter, opts); err != nil {
+                fatal("Error listing items", err)
+            }
+            return
+        }
+        if err := cli.List(filter, opts); err != nil {
+            fatal("Error listing items", err)
+        }
+
+    case "random":
+        fs := flag.NewFlagSet("random", flag.ExitOnError)
+        bindFilterFlags(fs, &filter)
+        bindListFlags(fs, &opts)
+        parseFlags(fs, args[1:])
+        if err := cli.Random(filter, opts); err != nil {
+            fatal("Error picking an item", err)
+        }
+
+    case "libraries":
+        if err := cli.Libraries(); err != nil {
+            fatal("Error listing libraries", err)
+        }
+
+    case "authors":
+        if len(args) > 2 {
+            usage("Usage: store-zotero authors [name]\n" +
+                "       store-zotero authors lint")
+        }
+        if len(args) == 2 && args[1] == "lint" {
+            if err := cli.LintAuthors(); err != nil {
+                fatal("Error linting authors", err)
+            }
+            return
+        }
+        name := ""
+        if len(args) == 2 {
+            name = args[1]
+        }
+        if err := cli.Authors(name); err != nil {
+            fatal("Error listing authors", err)
+        }
+
+    case "venues":
+        if len(args) > 1 {
+            usage("Usage: store-zotero venues")
+        }
+        if err := cli.Venues(); err != nil {
+            fatal("Error listing venues", err)
+        }
+
     case "open":
-        if len(args) != 2 {
-            log.Fatal("Usage: store-zotero open <stableid>")
+        fs := flag.NewFlagSet("open", flag.ExitOnError)
+        browser := fs.Bool("browser", false, "Open the item's URL instead of its attachment")
+        page := fs.Int("page", 0, "Open the PDF at this page")
+        annotation := fs.String("annotation", "", "Open the PDF at this annotation")
+        viewer := fs.String("viewer", "", "PDF viewer for -page/-annotation: "+strings.Join(pageViewerNames(), ", "))
+        var kind string
+        for _, name := range attachmentKinds {
+            fs.BoolFunc(name, "Open the "+strings.ToUpper(name)+" attachment", func(string) error {
+                kind = name
+                return nil
+            })
         }
-        if err := cli.Open(args[1]); err != nil {
-            log.Fatalf("Error opening item: %v", err)
+        bindFilterFlags(fs, &filter)
+        first := fs.Bool("first", false, "Use the first item when the filters match several")
+        all := fs.Bool("all", false, "Open every item matching the filters")
+        max := fs.Int("max", defaultOpenMax, "Open at most this many items with -all")
+        positional := parseArgs(fs, args[1:])
+        if *all {
+            if err := cli.OpenMatching(filter, kind, *max); err != nil {
+                fatal("Error opening items", err)
+            }
+            return
+        }
+        if len(positional) > 1 {
+            keys, ok, err := cli.itemKeys(positional)
+            if err != nil {
+                fatal("Error opening items", err)
+            }
+            if ok {
+                if err := cli.OpenItems(keys, kind); err != nil {
+                    fatal("Error opening items", err)
+                }
+                return
+            }
+        }
+        stableID, err := cli.itemArgument(positional, filter, *first)
+        if err != nil {
+            fatal("Error opening item", err)
+        }
+        if stableID == "" {
+            usage("Usage: store-zotero open <stableid|last|title words> | open <stableid>... | open [filters] [-first | -all [-max n]] [-pdf|-epub|-html] [-browser] [-page n | -annotation key] [-viewer name]")
         }
 
+        switch {
+        case *browser:
+            err = cli.OpenURL(stableID)
+        case *page > 0 || *annotation != "":
+            err = cli.OpenAt(stableID, *page, *annotation, *viewer)
+        default:
+            err = cli.OpenKind(stableID, kind)
+        }
+        if err != nil {
+            fatal("Error opening item", err)
+        }
+        cli.recordHistory("open", stableID)
+
+    case "reveal":
+        fs := flag.NewFlagSet("reveal", flag.ExitOnError)
+        var kind string
+        for _, name := range attachmentKinds {
+            fs.BoolFunc(name, "Reveal the "+strings.ToUpper(name)+" attachment", func(string) error {
+                kind = name
+                return nil
+            })
+        }
+        bindFilterFlags(fs, &filter)
+        first := fs.Bool("first", false, "Use the first item when the filters match several")
+        positional := parseArgs(fs, args[1:])
+        stableID, err := cli.itemArgument(positional, filter, *first)
+        if err != nil {
+            fatal("Error revealing attachment", err)
+        }
+        if stableID == "" {
+            usage("Usage: store-zotero reveal <stableid|last|title words> | reveal [filters] [-first] [-pdf|-epub|-html]")
+        }
+        if err := cli.Reveal(stableID, kind); err != nil {
+            fatal("Error revealing attachment", err)
+        }
+        cli.recordHistory("reveal", stableID)
+
     case "reference":
+        fs := flag.NewFlagSet("reference", flag.ExitOnError)
+        bindFilterFlags(fs, &filter)
+        first := fs.Bool("first", false, "Use the first item when the filters match several")
+        var refOpts ReferenceOptions
+        fs.StringVar(&refOpts.Format, "format", "markdown", "Output format: markdown, latex or wikipedia")
+        fs.StringVar(&refOpts.Cite, "cite", "autocite", "LaTeX citation command, e.g. cite, citep or parencite")
+        fs.BoolVar(&refOpts.Comment, "comment", false, "Add a LaTeX comment with the title and attachment path")
+        positional := parseArgs(fs, args[1:])
+        stableID, err := cli.itemArgument(positional, filter, *first)
+        if err != nil {
+            fatal("Error generating reference", err)
+        }
+        if stableID == "" {
+            usage("Usage: store-zotero reference <stableid|last|title words> | reference [filters] [-first] [-format markdown|latex|wikipedia]")
+        }
+        if err := cli.Reference(stableID, refOpts); err != nil {
+            fatal("Error generating reference", err)
+        }
+        cli.recordHistory("reference", stableID)
+
+    case "path":
+        fs := flag.NewFlagSet("path", flag.ExitOnError)
+        bindFilterFlags(fs, &filter)
+        first := fs.Bool("first", false, "Use the first item when the filters match several")
+        all := fs.Bool("all", false, "Print every stored attachment, not just the first")
+        var print0 bool
+        bindPrint0Flag(fs, &print0)
+        positional := parseArgs(fs, args[1:])
+        stableID, err := cli.itemArgument(positional, filter, *first)
+        if err != nil {
+            fatal("Error finding attachment path", err)
+        }
+        if stableID == "" {
+            usage("Usage: store-zotero path <stableid|last|title words> | path [filters] [-first] [-all] [-0]")
+        }
+        if err := cli.Path(stableID, *all, print0); err != nil {
+            fatal("Error finding attachment path", err)
+        }
+
+    case "link":
+        fs := flag.NewFlagSet("link", flag.ExitOnError)
+        bindFilterFlags(fs, &filter)
+        first := fs.Bool("first", false, "Use the first item when the filters match several")
+        qr := fs.String("qr", "", "Also write a QR code of the link to this PNG file (needs qrencode)")
+        positional := parseArgs(fs, args[1:])
+        stableID, err := cli.itemArgument(positional, filter, *first)
+        if err != nil {
+            fatal("Error minting link", err)
+        }
+        if stableID == "" {
+            usage("Usage: store-zotero link <stableid|last|title words> [-qr code.png]")
+        }
+        if err := cli.Link(stableID, *qr); err != nil {
+            fatal("Error minting link", err)
+        }
+
+    case "text":
+        fs := flag.NewFlagSet("text", flag.ExitOnError)
+        bindFilterFlags(fs, &filter)
+        first := fs.Bool("first", false, "Use the first item when the filters match several")
+        all := fs.Bool("all", false, "Print the text of every stored attachment, not just the main one")
+        positional := parseArgs(fs, args[1:])
+        stableID, err := cli.itemArgument(positional, filter, *first)
+        if err != nil {
+            fatal("Error extracting text", err)
+        }
+        if stableID == "" {
+            usage("Usage: store-zotero text <stableid|last|title words> | text [filters] [-first] [-all]")
+        }
+        if err := cli.Text(stableID, *all); err != nil {
+            fatal("Error extracting text", err)
+        }
+
+    case "grep":
+        fs := flag.NewFlagSet("grep", flag.ExitOnError)
+        bindFilterFlags(fs, &filter)
+        ignoreCase := fs.Bool("i", false, "Ignore case")
+        positional := parseArgs(fs, args[1:])
+        if len(positional) != 1 {
+            usage("Usage: store-zotero grep <pattern> [filters] [-i]")
+        }
+        if err := cli.Grep(positional[0], *ignoreCase, filter); err != nil {
+            fatal("Error searching attachments", err)
+        }
+
+    case "annotations":
+        fs := flag.NewFlagSet("annotations", flag.ExitOnError)
+        bindFilterFlags(fs, &filter)
+        first := fs.Bool("first", false, "Use the first item when the filters match several")
+        groupBy := fs.String("group-by", "", "Group annotations by color (label) or tag")
+        format := fs.String("format", "markdown", "Output format: markdown or json")
+        images := fs.String("images", "", "Save image annotations as PNG files in this folder and link them")
+        positional := parseArgs(fs, args[1:])
+        stableID, err := cli.itemArgument(positional, filter, *first)
+        if err != nil {
+            fatal("Error exporting annotations", err)
+        }
+        if stableID == "" {
+            usage("Usage: store-zotero annotations <stableid|last|title words> | annotations [filters] [-first] [-group-by color|tag] [-format markdown|json] [-images dir]")
+        }
+        if err := cli.Review(stableID, *groupBy, *format, *images); err != nil {
+            fatal("Error exporting annotations", err)
+        }
+
+    case "alias":
+        fs := flag.NewFlagSet("alias", flag.ExitOnError)
+        positional := parseArgs(fs, args[1:])
+        aliasUsage := "Usage: store-zotero alias [list] | alias set <name> <stableid|last|title words> | alias rm <name>"
+        var err error
+        switch {
+        case len(positional) == 0 || len(positional) == 1 && positional[0] == "list":
+            err = cli.Aliases()
+        case len(positional) >= 3 && positional[0] == "set":
+            var stableID string
+            if stableID, err = cli.itemArgument(positional[2:], Filter{}, false); err == nil {
+                err = cli.SetAlias(positional[1], stableID)
+            }
+        case len(positional) == 2 && positional[0] == "rm":
+            err = cli.RemoveAlias(positional[1])
+        default:
+            usage(aliasUsage)
+        }
+        if err != nil {
+            fatal("Error updating aliases", err)
+        }
+
+    case "pin", "unpin":
+        fs := flag.NewFlagSet(command, flag.ExitOnError)
+        positional := parseArgs(fs, args[1:])
+        if len(positional) == 0 {
+            usage("Usage: store-zotero " + command + " <stableid|last|title words>")
+        }
+        if command == "unpin" {
+            // pinned items may since have been deleted from Zotero
+            keys, err := readPins()
+            if err != nil {
+                fatal("Error unpinning item", err)
+            }
+            if len(positional) == 1 && slices.Contains(keys, positional[0]) {
+                if err := cli.Unpin(positional[0]); err != nil {
+                    fatal("Error unpinning item", err)
+                }
+                return
+            }
+        }
+        stableID, err := cli.itemArgument(positional, Filter{}, false)
+        if err != nil {
+            fatal("Error resolving item", err)
+        }
+        if command == "pin" {
+            err = cli.Pin(stableID)
+        } else {
+            err = cli.Unpin(stableID)
+        }
+        if err != nil {
+            fatal("Error updating pins", err)
+        }
+
+    case "pins":
+        fs := flag.NewFlagSet("pins", flag.ExitOnError)
+        bindListFlags(fs, &opts)
+        parseFlags(fs, args[1:])
+        if err := cli.Pins(opts); err != nil {
+            fatal("Error listing pins", err)
+        }
+
+    case "dump":
+        fs := flag.NewFlagSet("dump", flag.ExitOnError)
+        out := fs.String("out", "", "Write the dump to this file instead of stdout")
+        parseFlags(fs, args[1:])
+        if err := cli.Dump(*out); err != nil {
+            fatal("Error dumping library", err)
+        }
+
+    case "diff":
+        if len(args) < 2 || len(args) > 3 {
+            usage("Usage: store-zotero diff <old.json> [new.json|live]")
+        }
+        newPath := liveDump
+        if len(args) == 3 {
+            newPath = args[2]
+        }
+        if err := cli.Diff(args[1], newPath); err != nil {
+            fatal("Error comparing dumps", err)
+        }
+
+    case "du":
+        fs := flag.NewFlagSet("du", flag.ExitOnError)
+        var duOpts DiskUsageOptions
+        bindFilterFlags(fs, &filter)
+        fs.StringVar(&duOpts.Sort, "sort", "size", "Sort by size, key or title")
+        minSize := fs.String("min", "", "Only show items using at least this much, e.g. 50M")
+        fs.BoolVar(&duOpts.Bytes, "bytes", false, "Print sizes in bytes")
+        fs.BoolVar(&duOpts.ByCollection, "by-collection", false, "Sum sizes per collection")
+        parseFlags(fs, args[1:])
+        var err error
+        if duOpts.MinBytes, err = parseSize(*minSize); err != nil {
+            usage(err.Error())
+        }
+        if err := cli.DiskUsage(filter, duOpts); err != nil {
+            fatal("Error measuring storage", err)
+        }
+
+    case "graph":
+        fs := flag.NewFlagSet("graph", flag.ExitOnError)
+        bindFilterFlags(fs, &filter)
+        format := fs.String("format", "dot", "Output format: dot or graphml")
+        parseFlags(fs, args[1:])
+        if err := cli.Graph(filter, *format); err != nil {
+            fatal("Error exporting graph", err)
+        }
+
+    case "enrich":
+        fs := flag.NewFlagSet("enrich", flag.ExitOnError)
+        all := fs.Bool("all", false, "Also show fields that agree with Crossref")
+        format := fs.String("format", "text", "Output format: text or json")
+        positional := parseArgs(fs, args[1:])
+        stableID, err := cli.itemArgument(positional, Filter{}, false)
+        if err != nil {
+            fatal("Error resolving item", err)
+        }
+        if stableID == "" {
+            usage("Usage: store-zotero enrich <stableid|last|title words> [-all] [-format json]")
+        }
+        if err := cli.PrintEnrichment(stableID, *all, *format); err != nil {
+            fatal("Error enriching item", err)
+        }
+
+    case "citations":
+        fs := flag.NewFlagSet("citations", flag.ExitOnError)
+        source := fs.String("source", "openalex", "Citation source: openalex or s2")
+        direction := fs.String("direction", "both", "Works to list: cited-by, references or both")
+        localOnly := fs.Bool("local", false, "Only list works already in the library")
+        format := fs.String("format", "text", "Output format: text or json")
+        positional := parseArgs(fs, args[1:])
+        stableID, err := cli.itemArgument(positional, Filter{}, false)
+        if err != nil {
+            fatal("Error resolving item", err)
+        }
+        if stableID == "" {
+            usage("Usage: store-zotero citations <stableid|last|title words> [-source openalex|s2] [-direction cited-by|references|both] [-local] [-format json]")
+        }
+        directions := []string{citedBy, references}
+        switch *direction {
+        case "both":
+        case citedBy, references:
+            directions = []string{*direction}
+        default:
+            usage("Unknown direction: " + *direction)
+        }
+        if err := cli.PrintCitations(stableID, *source, directions, *localOnly, *format); err != nil {
+            fatal("Error listing citations", err)
+        }
+
+    case "oa":
+        fs := flag.NewFlagSet("oa", flag.ExitOnError)
+        dir := fs.String("download", "", "Download the PDF into this folder")
+        positional := parseArgs(fs, args[1:])
+        stableID, err := cli.itemArgument(positional, Filter{}, false)
+        if err != nil {
+            fatal("Error resolving item", err)
+        }
+        if stableID == "" {
+            usage("Usage: store-zotero oa <stableid|last|title words> [-download dir]")
+        }
+        if err := cli.OpenAccess(stableID, *dir); err != nil {
+            fatal("Error locating open access PDF", err)
+        }
+
+    case "suggest-tags":
+        fs := flag.NewFlagSet("suggest-tags", flag.ExitOnError)
+        limit := fs.Int("n", 10, "Number of tags to suggest")
+        apply := fs.Bool("apply", false, "Add the suggested tags to the item through the Zotero client")
+        format := fs.String("format", "text", "Output format: text or json")
+        positional := parseArgs(fs, args[1:])
+        stableID, err := cli.itemArgument(positional, Filter{}, false)
+        if err != nil {
+            fatal("Error resolving item", err)
+        }
+        if stableID == "" {
+            usage("Usage: store-zotero suggest-tags <stableid|last|title words> [-n 10] [-apply] [-format json]")
+        }
+        if err := cli.PrintTagSuggestions(stableID, *limit, *format, *apply); err != nil {
+            fatal("Error suggesting tags", err)
+        }
+
+    case "tag", "tags":
+        fs := flag.NewFlagSet("tag", flag.ExitOnError)
+        remote := fs.Bool("remote", false, "Edit through the Zotero Web API instead of the running client")
+        dryRun := fs.Bool("dry-run", false, "List the items rename or merge would change without changing them")
+        positional := parseArgs(fs, args[1:])
+        tagUsage := "Usage: store-zotero tag add|remove <stableid|last> <tag>... [-remote]\n" +
+            "       store-zotero tag rename <old> <new> [-dry-run] [-remote]\n" +
+            "       store-zotero tag merge [tag] [-dry-run] [-remote]\n" +
+            "       store-zotero tags lint"
+        if len(positional) == 1 && positional[0] == "lint" {
+            if err := cli.LintTags(); err != nil {
+                fatal("Error linting tags", err)
+            }
+            return
+        }
+        if len(positional) > 0 && positional[0] == "rename" {
+            if len(positional) != 3 {
+                usage(tagUsage)
+            }
+            if err := cli.RenameTag(positional[1], positional[2], *dryRun || cli.dryRun, *remote); err != nil {
+                fatal("Error renaming tag", err)
+            }
+            return
+        }
+        if len(positional) > 0 && positional[0] == "merge" {
+            if len(positional) > 2 {
+                usage(tagUsage)
+            }
+            tag := ""
+            if len(positional) == 2 {
+                tag = positional[1]
+            }
+            if err := cli.MergeTags(tag, *dryRun || cli.dryRun, *remote); err != nil {
+                fatal("Error merging tags", err)
+            }
+            return
+        }
+        if len(positional) < 3 || (positional[0] != "add" && positional[0] != "remove") {
+            usage(tagUsage)
+        }
+        stableID, err := cli.itemArgument(positional[1:2], Filter{}, false)
+        if err != nil {
+            fatal("Error resolving item", err)
+        }
+        remove := positional[0] == "remove"
+        if *remote {
+            err = cli.RemoteTagItem(stableID, positional[2:], remove)
+        } else {
+            err = cli.TagItem(stableID, positional[2:], remove)
+        }
+        if err != nil {
+            fatal("Error tagging item", err)
+        }
+
+    case "status":
+        fs := flag.NewFlagSet("status", flag.ExitOnError)
+        remote := fs.Bool("remote", false, "Edit through the Zotero Web API instead of the running client")
+        positional := parseArgs(fs, args[1:])
+        if len(positional) < 1 || len(positional) > 2 {
+            usage("Usage: store-zotero status <stableid|last> [toread|reading|read] [-remote]")
+        }
+        stableID, err := cli.itemArgument(positional[:1], Filter{}, false)
+        if err != nil {
+            fatal("Error resolving item", err)
+        }
+        if len(positional) == 1 {
+            err = cli.Status(stableID)
+        } else {
+            err = cli.SetStatus(stableID, positional[1], *remote)
+        }
+        if err != nil {
+            fatal("Error setting status", err)
+        }
+
+    case "collection":
+        fs := flag.NewFlagSet("collection", flag.ExitOnError)
+        remote := fs.Bool("remote", false, "Edit through the Zotero Web API instead of the running client")
+        positional := parseArgs(fs, args[1:])
+        if len(positional) != 3 || !slices.Contains([]string{"add", "move", "remove"}, positional[0]) {
+            usage("Usage: store-zotero collection add|move|remove <stableid|last> <collection key, name or path> [-remote]")
+        }
+        stableID, err := cli.itemArgument(positional[1:2], Filter{}, false)
+        if err != nil {
+            fatal("Error resolving item", err)
+        }
+        switch action := positional[0]; {
+        case action == "remove" && *remote:
+            err = cli.RemoteRemoveFromCollection(stableID, positional[2])
+        case action == "remove":
+            err = cli.RemoveFromCollection(stableID, positional[2])
+        case *remote:
+            err = cli.RemoteAddToCollection(stableID, positional[2], action == "move")
+        default:
+            err = cli.AddToCollection(stableID, positional[2], action == "move")
+        }
+        if err != nil {
+            fatal("Error updating collections", err)
+        }
+
+    case "field":
+        fs := flag.NewFlagSet("field", flag.ExitOnError)
+        remote := fs.Bool("remote", false, "Edit through the Zotero Web API instead of the running client")
+        positional := parseArgs(fs, args[1:])
+        if len(positional) != 4 || positional[0] != "set" {
+            usage("Usage: store-zotero field set <stableid|last> <field> <value> [-remote]")
+        }
+        stableID, err := cli.itemArgument(positional[1:2], Filter{}, false)
+        if err != nil {
+            fatal("Error resolving item", err)
+        }
+        if *remote {
+            err = cli.RemoteSetField(stableID, positional[2], positional[3])
+        } else {
+            err = cli.SetField(stableID, positional[2], positional[3])
+        }
+        if err != nil {
+            fatal("Error setting field", err)
+        }
+
+    case "citekey":
+        fs := flag.NewFlagSet("citekey", flag.ExitOnError)
+        remote := fs.Bool("remote", false, "Edit through the Zotero Web API instead of the running client")
+        positional := parseArgs(fs, args[1:])
+        if len(positional) != 3 || positional[0] != "set" {
+            usage("Usage: store-zotero citekey set <stableid|last> <key> [-remote]")
+        }
+        stableID, err := cli.itemArgument(positional[1:2], Filter{}, false)
+        if err != nil {
+            fatal("Error resolving item", err)
+        }
+        if *remote {
+            err = cli.RemoteSetCitationKey(stableID, positional[2])
+        } else {
+            err = cli.SetCitationKey(stableID, positional[2])
+        }
+        if err != nil {
+            fatal("Error setting citation key", err)
+        }
+
+    case "add":
+        fs := flag.NewFlagSet("add", flag.ExitOnError)
+        doi := fs.String("doi", "", "DOI of the paper, instead of searching the PDF for one")
+        remote := fs.Bool("remote", false, "Create the item through the Zotero Web API instead of the running client")
+        force := fs.Bool("force", false, "Add the paper even if its DOI is already in the library")
+        positional := parseArgs(fs, args[1:])
+        if len(positional) != 1 {
+            usage("Usage: store-zotero add <file.pdf> [-doi doi] [-remote] [-force]")
+        }
+        if err := cli.Add(positional[0], *doi, *remote, *force); err != nil {
+            fatal("Error adding paper", err)
+        }
+
+    case "note":
+        if len(args) < 3 || args[1] != "create" {
+            usage("Usage: store-zotero note create <stableid|last> [text | -]")
+        }
+        stableID, err := cli.itemArgument(args[2:3], Filter{}, false)
+        if err != nil {
+            fatal("Error resolving item", err)
+        }
+        text := strings.Join(args[3:], " ")
+        if text == "" || text == "-" {
+            data, err := io.ReadAll(os.Stdin)
+            if err != nil {
+                fatal("Error reading note", err)
+            }
+            text = string(data)
+        }
+        if strings.TrimSpace(text) == "" {
+            usage("Note text is empty")
+        }
+        if err := cli.CreateNote(stableID, text); err != nil {
+            fatal("Error creating note", err)
+        }
+
+    case "export":
+        fs := flag.NewFlagSet("export", flag.ExitOnError)
+        var exportOpts ExportOptions
+        bindFilterFlags(fs, &filter)
+        out := fs.String("out", "", "Write the export to this file instead of stdout")
+        to := fs.String("to", "", "Folder export tree writes the collection folders to")
+        link := fs.Bool("link", false, "Symlink the attachments of export tree instead of copying them")
+        jobs := fs.Int("jobs", defaultJobs, "Number of files export tree copies at once")
+        fs.StringVar(&exportOpts.Deck, "deck", "Zotero", "Anki deck for the cards")
+        positional := parseArgs(fs, args[1:])
+        if len(positional) != 1 || (positional[0] == exportTree) != (*to != "") {
+            usage("Usage: store-zotero export <" + strings.Join(exportFormats, "|") + "> [filters] [-out file]\n" +
+                "       store-zotero export tree -to <dir> [filters] [-link] [-jobs N]")
+        }
+        var err error
+        if positional[0] == exportTree {
+            err = cli.ExportTree(*to, filter, *link, *jobs)
+        } else {
+            err = cli.Export(positional[0], *out, filter, exportOpts)
+        }
+        if err != nil {
+            fatal("Error exporting items", err)
+        }
+
+    case "coverage":
+        fs := flag.NewFlagSet("coverage", flag.ExitOnError)
+        from := fs.String("from", "", "Markdown or LaTeX document to collect citations from")
+        format := fs.String("format", "text", "Output format: text or json")
+        parseFlags(fs, args[1:])
+        if *from == "" {
+            usage("Usage: store-zotero coverage -from paper.md|paper.tex [-format json]")
+        }
+        if err := cli.Coverage(*from, *format); err != nil {
+            fatal("Error checking coverage", err)
+        }
+
+    case "match":
+        fs := flag.NewFlagSet("match", flag.ExitOnError)
+        bib := fs.String("bib", "", "BibTeX file whose entries to find in the library")
+        format := fs.String("format", "text", "Output format: text or json")
+        parseFlags(fs, args[1:])
+        if *bib == "" {
+            usage("Usage: store-zotero match -bib refs.bib [-format json]")
+        }
+        if err := cli.Match(*bib, *format); err != nil {
+            fatal("Error matching bibliography", err)
+        }
+
+    case "bib":
+        fs := flag.NewFlagSet("bib", flag.ExitOnError)
+        from := fs.String("from", "", "Markdown document to collect @citekeys from")
+        out := fs.String("out", "", "Write the BibTeX file here instead of stdout")
+        parseFlags(fs, args[1:])
+        if *from == "" {
+            usage("Usage: store-zotero bib -from document.md [-out refs.bib]")
+        }
+        if err := cli.Bibliography(*from, *out); err != nil {
+            fatal("Error building bibliography", err)
+        }
+
+    case "bundle":
+        fs := flag.NewFlagSet("bundle", flag.ExitOnError)
+        bindFilterFlags(fs, &filter)
+        out := fs.String("out", "", "Zip archive to write")
+        jobs := fs.Int("jobs", defaultJobs, "Number of attachments to download and compress at once")
+        parseFlags(fs, args[1:])
+        if *out == "" {
+            usage("Usage: store-zotero bundle [filters] -out bundle.zip [-jobs N]")
+        }
+        if err := cli.Bundle(*out, filter, *jobs); err != nil {
+            fatal("Error writing bundle", err)
+        }
+
+    case "site":
+        fs := flag.NewFlagSet("site", flag.ExitOnError)
+        bindFilterFlags(fs, &filter)
+        out := fs.String("out", "", "Folder to write the site to")
+        attachments := fs.String("attachments", "link", "Attachments: link to the stored file, copy into the site, or none")
+        parseFlags(fs, args[1:])
+        if *out == "" {
+            usage("Usage: store-zotero site -out ./public [filters] [-attachments link|copy|none]")
+        }
+        if err := cli.Site(*out, filter, *attachments); err != nil {
+            fatal("Error writing site", err)
+        }
+
+    case "index":
+        fs := flag.NewFlagSet("index", flag.ExitOnError)
+        rebuild := fs.Bool("rebuild", false, "Discard the index and build it from scratch")
+        parseFlags(fs, args[1:])
+        if err := cli.Index(*rebuild); err != nil {
+            fatal("Error building search index", err)
+        }
+
+    case "tui":
+        fs := flag.NewFlagSet("tui", flag.ExitOnError)
+        bindFilterFlags(fs, &filter)
+        parseFlags(fs, args[1:])
+        if err := cli.TUI(filter); err != nil {
+            fatal("Error running tui", err)
+        }
+
+    case "semsearch":
+        fs := flag.NewFlagSet("semsearch", flag.ExitOnError)
+        bindFilterFlags(fs, &filter)
+        limit := fs.Int("n", 10, "Number of items to show, 0 for all")
+        format := fs.String("format", "text", "Output format: text or json")
+        positional := parseArgs(fs, args[1:])
+        if len(positional) == 0 {
+            usage("Usage: store-zotero semsearch \"<what it was about>\" [filters] [-n 10] [-format json]")
+        }
+        if err := cli.PrintSemanticSearch(strings.Join(positional, " "), filter, *limit, *format); err != nil {
+            fatal("Error searching by meaning", err)
+        }
+
+    case "history":
+        fs := flag.NewFlagSet("history", flag.ExitOnError)
+        limit := fs.Int("n", 20, "Number of entries to show, 0 for all")
+        parseFlags(fs, args[1:])
+        if err := cli.History(*limit); err != nil {
+            fatal("Error reading history", err)
+        }
+
+    case "related":
+        fs := flag.NewFlagSet("related", flag.ExitOnError)
+        bindListFlags(fs, &opts)
+        depth := fs.Int("depth", 1, "Follow relations this many hops away")
+        positional := parseArgs(fs, args[1:])
+        if len(positional) != 1 {
+            usage("Usage: store-zotero related <stableid> [-depth n] [-v] [-format f]")
+        }
+        if err := cli.Related(positional[0], *depth, opts); err != nil {
+            fatal("Error listing related items", err)
+        }
+
+    case "verify":
+        fs := flag.NewFlagSet("verify", flag.ExitOnError)
+        bindFilterFlags(fs, &filter)
+        all := fs.Bool("all", false, "Verify every item matching the filters")
+        jobs := fs.Int("jobs", defaultJobs, "Number of files to hash at once")
+        positional := parseArgs(fs, args[1:])
+
+        var err error
+        switch {
+        case *all && len(positional) == 0:
+            err = cli.VerifyAll(filter, *jobs)
+        case !*all && len(positional) == 1:
+            err = cli.Verify(positional[0], *jobs)
+        default:
+            usage("Usage: store-zotero verify <stableid> | verify -all [filters] [-jobs N]")
+        }
+        if err != nil {
+            fatal("Error verifying attachments", err)
+        }
+
+    case "fetch":
         if len(args) != 2 {
-            log.Fatal("Usage: store-zotero reference <stableid>")
+            usage("Usage: store-zotero fetch <stableid>")
         }
-        if err := cli.Reference(args[1]); err != nil {
-            log.Fatalf("Error generating reference: %v", err)
+        if err := cli.Fetch(args[1]); err != nil {
+            fatal("Error fetching attachments", err)
+        }
+
+    case "serve":
+        fs := flag.NewFlagSet("serve", flag.ExitOnError)
+        socket := fs.String("socket", defaultSocketPath(), "Unix socket to listen on")
+        bind := fs.String("bind", "", "Answer JSON-RPC over HTTP on this address instead, e.g. 127.0.0.1:8765")
+        fs.StringVar(&cli.cfg.Server.TLSCert, "tls-cert", cli.cfg.Server.TLSCert, "PEM certificate to serve HTTPS with")
+        fs.StringVar(&cli.cfg.Server.TLSKey, "tls-key", cli.cfg.Server.TLSKey, "PEM key of the certificate")
+        parseFlags(fs, args[1:])
+        var err error
+        if *bind != "" {
+            err = cli.ServeHTTP(*bind)
+        } else {
+            err = cli.Serve(*socket)
+        }
+        if err != nil {
+            fatal("Error serving", err)
+        }
+
+    case "doctor":
+        fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+        parseFlags(fs, args[1:])
+        if err := cli.Doctor(); err != nil {
+            fatal("Database check failed", err)
+        }
+
+    case "capabilities":
+        fs := flag.NewFlagSet("capabilities", flag.ExitOnError)
+        jsonFlag := fs.Bool("json", false, "Machine-readable JSON output")
+        parseFlags(fs, args[1:])
+        if err := cli.Capabilities(*jsonFlag); err != nil {
+            fatal("Error reporting capabilities", err)
         }
 
     default:
-        log.Fatalf("Unknown command: %s", command)
+        usage(fmt.Sprintf("Unknown command: %s", command))
     }
 }
@@ -0,0 +1,209 @@
+package main
+
+import (
+    "fmt"
+    "os"
+    "regexp"
+    "strings"
+    "unicode"
+)
+
+// How match tied a BibTeX entry to a library item
+const (
+    matchedByCitekey = "citekey"
+    matchedByDOI     = "doi"
+    matchedByTitle   = "title"
+)
+
+// BibEntry is an entry of a BibTeX file, with field names lowercased and
+// values stripped of their outer braces or quotes
+type BibEntry struct {
+    Type   string
+    Key    string
+    Fields map[string]string
+}
+
+// BibMatch is the library item a BibTeX entry was matched to; Item is
+// empty for entries without one
+type BibMatch struct {
+    Entry string `json:"entry"`
+    Item  string `json:"key,omitempty"`
+    By    string `json:"by,omitempty"`
+}
+
+// latexCommandPattern finds LaTeX commands and accents such as \emph and \"
+var latexCommandPattern = regexp.MustCompile(`\\([a-zA-Z]+|.)`)
+
+// parseBibTeX reads the entries of a BibTeX file, skipping @comment,
+// @preamble and @string blocks. Values keep their inner braces.
+func parseBibTeX(data string) ([]BibEntry, error) {
+    var entries []BibEntry
+    for {
+        at := strings.IndexByte(data, '@')
+        if at < 0 {
+            return entries, nil
+        }
+        data = data[at+1:]
+        open := strings.IndexAny(data, "{(")
+        if open < 0 {
+            return entries, nil
+        }
+        entryType := strings.ToLower(strings.TrimSpace(data[:open]))
+        if entryType == "" || strings.ContainsFunc(entryType, func(r rune) bool { return !unicode.IsLetter(r) }) {
+            // an @ outside an entry, such as in an address in a comment
+            continue
+        }
+        body, rest, err := bibBlock(data[open:])
+        if err != nil {
+            return nil, fmt.Errorf("reading @%s: %w", entryType, err)
+        }
+        data = rest
+        switch entryType {
+        case "comment", "preamble", "string":
+            continue
+        }
+
+        key, fields, _ := strings.Cut(body, ",")
+        entry := BibEntry{Type: entryType, Key: strings.TrimSpace(key), Fields: make(map[string]string)}
+        for fields = strings.TrimSpace(fields); fields != ""; fields = strings.TrimLeft(fields, ", \t\r\n") {
+            name, value, ok := strings.Cut(fields, "=")
+            if !ok {
+                break
+            }
+            value = strings.TrimSpace(value)
+            var parsed string
+            switch {
+            case strings.HasPrefix(value, "{"):
+                parsed, fields, err = bibBlock(value)
+            case strings.HasPrefix(value, `"`):
+                end := strings.IndexByte(value[1:], '"')
+                if end < 0 {
+                    err = fmt.Errorf("unterminated string")
+                } else {
+                    parsed, fields = value[1:end+1], value[end+2:]
+                }
+            default:
+                // numbers and @string macros run up to the next comma
+                parsed, fields, _ = strings.Cut(value, ",")
+            }
+            if err != nil {
+                return nil, fmt.Errorf("reading %s: %w", entry.Key, err)
+            }
+            entry.Fields[strings.ToLower(strings.TrimSpace(name))] = strings.TrimSpace(parsed)
+        }
+        entries = append(entries, entry)
+    }
+}
+
+// bibBlock splits s, starting with an opening brace or parenthesis, into
+// what the matching closing one encloses and what follows it
+func bibBlock(s string) (string, string, error) {
+    closing := byte('}')
+    if s[0] == '(' {
+        closing = ')'
+    }
+    depth := 0
+    for i := 1; i < len(s); i++ {
+        switch {
+        case s[i] == '{':
+            depth++
+        case s[i] == '}' && depth > 0:
+            depth--
+        case s[i] == closing && depth == 0:
+            return s[1:i], s[i+1:], nil
+        }
+    }
+    return "", "", fmt.Errorf("missing closing %q", closing)
+}
+
+// matchTitle reduces a title to its words for comparison, dropping LaTeX
+// markup, punctuation, case and diacritics
+func matchTitle(title string) string {
+    title = latexCommandPattern.ReplaceAllStringFunc(title, func(command string) string {
+        // accents go, the letter they sit on stays
+        if len(command) == 2 && !unicode.IsLetter(rune(command[1])) {
+            return ""
+        }
+        return " "
+    })
+    return strings.Join(strings.FieldsFunc(fold(title), func(r rune) bool {
+        return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+    }), " ")
+}
+
+// MatchBib maps each entry of the BibTeX file at path to a library item:
+// by citation key first, then by DOI, then by title
+func (c *CLI) MatchBib(path string) ([]BibMatch, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, fmt.Errorf("reading %s: %w", path, err)
+    }
+    entries, err := parseBibTeX(string(data))
+    if err != nil {
+        return nil, fmt.Errorf("parsing %s: %w", path, err)
+    }
+
+    index, err := c.repo.citationKeyIndex()
+    if err != nil {
+        return nil, err
+    }
+    byDOI := make(map[string]string)
+    byTitle := make(map[string]string)
+    for _, item := range index {
+        if doi := item.Fields["DOI"]; doi != "" {
+            byDOI[normalizeDOI(doi)] = item.Key
+        }
+        if title := matchTitle(item.Fields["title"]); title != "" {
+            byTitle[title] = item.Key
+        }
+    }
+
+    matches := make([]BibMatch, 0, len(entries))
+    for _, entry := range entries {
+        match := BibMatch{Entry: entry.Key}
+        if item, ok := index[entry.Key]; ok {
+            match.Item, match.By = item.Key, matchedByCitekey
+        } else if key, ok := byDOI[normalizeDOI(entry.Fields["doi"])]; ok && entry.Fields["doi"] != "" {
+            match.Item, match.By = key, matchedByDOI
+        } else if key, ok := byTitle[matchTitle(entry.Fields["title"])]; ok && entry.Fields["title"] != "" {
+            match.Item, match.By = key, matchedByTitle
+        }
+        matches = append(matches, match)
+    }
+    return matches, nil
+}
+
+// Match prints the library item of every entry of the BibTeX file at path
+// in format, text or json, and fails listing the entries without one so that a
+// manuscript's bibliography can be checked against the library
+func (c *CLI) Match(path, format string) error {
+    matches, err := c.MatchBib(path)
+    if err != nil {
+        return err
+    }
+    asJSON := format == "json"
+    if asJSON {
+        if err := writeJSON(matches); err != nil {
+            return err
+        }
+    }
+
+    var unmatched []string
+    for _, match := range matches {
+        if match.Item == "" {
+            unmatched = append(unmatched, match.Entry)
+        }
+        if asJSON {
+            continue
+        }
+        if match.Item == "" {
+            fmt.Printf("%s\t-\tunmatched\n", match.Entry)
+        } else {
+            fmt.Printf("%s\t%s\t%s\n", match.Entry, match.Item, match.By)
+        }
+    }
+    if len(unmatched) > 0 {
+        return fmt.Errorf("%d of %d entries %w in the library: %s", len(unmatched), len(matches), errNotFound, strings.Join(unmatched, ", "))
+    }
+    return nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+    "bufio"
+    "encoding/json"
+    "fmt"
+    "io"
+)
+
+// ExportNDJSON streams items matching filter as newline delimited JSON,
+// one item in the list -format json schema per line, writing each item as
+// soon as its row is read
+func (c *CLI) ExportNDJSON(w io.Writer, filter Filter) error {
+    buffered := bufio.NewWriter(w)
+    enc := json.NewEncoder(buffered)
+    enc.SetEscapeHTML(false)
+
+    opts := ListOptions{Abstract: true}
+    err := c.repo.EachItem(filter, func(item *Item) error {
+        encoded, err := c.itemJSON(item, opts)
+        if err != nil {
+            return err
+        }
+        if err := enc.Encode(encoded); err != nil {
+            return fmt.Errorf("encoding json: %w", err)
+        }
+        return nil
+    })
+    if err != nil {
+        return err
+    }
+    if err := buffered.Flush(); err != nil {
+        return fmt.Errorf("writing export: %w", err)
+    }
+    return nil
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+    "encoding/xml"
+    "fmt"
+    "log/slog"
+    "net/http"
+    "strings"
+    "time"
+)
+
+// opdsPrefix is where serve -bind publishes the OPDS catalog
+const opdsPrefix = "/opds/"
+
+// OPDS link types of the catalog's feeds
+const (
+    opdsNavigation  = "application/atom+xml;profile=opds-catalog;kind=navigation"
+    opdsAcquisition = "application/atom+xml;profile=opds-catalog;kind=acquisition"
+)
+
+// opdsKinds are the attachment kinds offered for download, the ones
+// e-readers open
+var opdsKinds = []string{"pdf", "epub"}
+
+// opdsFeed is an Atom feed of the OPDS catalog
+type opdsFeed struct {
+    XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+    ID      string      `xml:"id"`
+    Title   string      `xml:"title"`
+    Updated string      `xml:"updated"`
+    Links   []opdsLink  `xml:"link"`
+    Entries []opdsEntry `xml:"entry"`
+}
+
+// opdsEntry is a collection to browse or an item to download
+type opdsEntry struct {
+    ID      string       `xml:"id"`
+    Title   string       `xml:"title"`
+    Updated string       `xml:"updated"`
+    Authors []opdsAuthor `xml:"author,omitempty"`
+    Summary string       `xml:"summary,omitempty"`
+    Links   []opdsLink   `xml:"link"`
+}
+
+type opdsAuthor struct {
+    Name string `xml:"name"`
+}
+
+type opdsLink struct {
+    Rel   string `xml:"rel,attr,omitempty"`
+    Href  string `xml:"href,attr"`
+    Type  string `xml:"type,attr"`
+    Title string `xml:"title,attr,omitempty"`
+}
+
+// opdsTime formats a Zotero timestamp as Atom wants it, falling back to
+// now for missing or malformed ones
+func opdsTime(zotero string) string {
+    t, err := time.Parse(time.DateTime, zotero)
+    if err != nil {
+        t = time.Now()
+    }
+    return t.UTC().Format(time.RFC3339)
+}
+
+// newOPDSFeed starts a feed at path with the links every feed carries
+func newOPDSFeed(path, title, kind string) *opdsFeed {
+    return &opdsFeed{
+        ID:      "urn:zotero-fetch:" + path,
+        Title:   title,
+        Updated: opdsTime(""),
+        Links: []opdsLink{
+            {Rel: "self", Href: path, Type: kind},
+            {Rel: "start", Href: opdsPrefix, Type: opdsNavigation},
+        },
+    }
+}
+
+// opdsFileURL returns where the catalog serves an attachment's file
+func opdsFileURL(item *Item, att *Attachment) string {
+    return opdsPrefix + "files/" + item.StableID + "/" + att.StableID
+}
+
+// collectionEntry links to the feed of a collection
+func collectionEntry(collection *Collection) opdsEntry {
+    return opdsEntry{
+        ID:      "urn:zotero:collection:" + collection.Key,
+        Title:   collection.Name,
+        Updated: opdsTime(""),
+        Links: []opdsLink{{
+            Rel:  "subsection",
+            Href: opdsPrefix + "collections/" + collection.Key,
+            Type: opdsAcquisition,
+        }},
+    }
+}
+
+// itemEntry describes an item with a download link per PDF or EPUB file.
+// Items without one are left out, reported by ok.
+func (c *CLI) itemEntry(item *Item) (entry opdsEntry, ok bool, err error) {
+    for _, kind := range opdsKinds {
+        att := attachmentOfKind(item, kind)
+        if att == nil || !att.Exists() {
+            continue
+        }
+        entry.Links = append(entry.Links, opdsLink{
+            Rel:   "http://opds-spec.org/acquisition",
+            Href:  opdsFileURL(item, att),
+            Type:  att.ContentType,
+            Title: att.Title,
+        })
+    }
+    if len(entry.Links) == 0 {
+        return entry, false, nil
+    }
+
+    for _, creator := range item.Creators {
+        entry.Authors = append(entry.Authors, opdsAuthor{strings.TrimSpace(creator.FirstName + " " + creator.LastName)})
+    }
+    entry.ID = "urn:zotero:item:" + item.StableID
+    entry.Title = item.Title
+    entry.Updated = opdsTime(item.DateAdded)
+    entry.Summary = item.Abstract.String
+    return entry, true, nil
+}
+
+// addItems adds the downloadable items matching filter to feed
+func (c *CLI) addItems(feed *opdsFeed, filter Filter) error {
+    items, err := c.repo.ListItems(filter)
+    if err != nil {
+        return fmt.Errorf("listing items: %w", err)
+    }
+    for _, item := range items {
+        entry, ok, err := c.itemEntry(item)
+        if err != nil {
+            return err
+        }
+        if ok {
+            feed.Entries = append(feed.Entries, entry)
+        }
+    }
+    return nil
+}
+
+// itemsFeed lists every downloadable item
+func (c *CLI) itemsFeed() (*opdsFeed, error) {
+    feed := newOPDSFeed(opdsPrefix+"items", "All items", opdsAcquisition)
+    return feed, c.addItems(feed, Filter{})
+}
+
+// rootFeed offers every item and the top-level collections
+func (c *CLI) rootFeed() (*opdsFeed, error) {
+    collections, err := c.repo.ListCollections()
+    if err != nil {
+        return nil, fmt.Errorf("listing collections: %w", err)
+    }
+    feed := newOPDSFeed(opdsPrefix, "Zotero", opdsNavigation)
+    feed.Entries = append(feed.Entries, opdsEntry{
+        ID:      "urn:zotero-fetch:" + opdsPrefix + "items",
+        Title:   "All items",
+        Updated: feed.Updated,
+        Links:   []opdsLink{{Rel: "subsection", Href: opdsPrefix + "items", Type: opdsAcquisition}},
+    })
+    for _, collection := range collections {
+        if collection.ParentKey == "" {
+            feed.Entries = append(feed.Entries, collectionEntry(collection))
+        }
+    }
+    return feed, nil
+}
+
+// collectionFeed lists the subcollections of a collection followed by
+// the items filed in it or below
+func (c *CLI) collectionFeed(key string) (*opdsFeed, error) {
+    collection, err := c.repo.findCollection(key)
+    if err != nil {
+        return nil, err
+    }
+    collections, err := c.repo.ListCollections()
+    if err != nil {
+        return nil, fmt.Errorf("listing collections: %w", err)
+    }
+    path := opdsPrefix + "collections/" + collection.Key
+    feed := newOPDSFeed(path, collection.Name, opdsAcquisition)
+    for _, child := range collections {
+        if child.ParentKey == collection.Key {
+            feed.Entries = append(feed.Entries, collectionEntry(child))
+        }
+    }
+    return feed, c.addItems(feed, Filter{Collection: collection.Key})
+}
+
+// serveAttachment sends the PDF or EPUB file of an item
+func (c *CLI) serveAttachment(w http.ResponseWriter, r *http.Request, itemKey, attKey string) error {
+    item, err := c.repo.GetByStableID(itemKey)
+    if err != nil {
+        return err
+    }
+    for _, kind := range opdsKinds {
+        att := attachmentOfKind(item, kind)
+        if att != nil && att.StableID == attKey && att.Exists() {
+            w.Header().Set("Content-Type", att.ContentType)
+            http.ServeFile(w, r, att.Path)
+            return nil
+        }
+    }
+    return fmt.Errorf("attachment %w: %s", errNotFound, attKey)
+}
+
+// opdsHandler serves the OPDS catalog: the root feed, one feed per
+// collection, one of every item and the files themselves
+func (c *CLI) opdsHandler() http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if r.Method != http.MethodGet && r.Method != http.MethodHead {
+            w.Header().Set("Allow", http.MethodGet)
+            http.Error(w, "the catalog is read-only", http.StatusMethodNotAllowed)
+            return
+        }
+        parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, opdsPrefix), "/"), "/")
+
+        var feed *opdsFeed
+        var err error
+        switch {
+        case parts[0] == "":
+            feed, err = c.rootFeed()
+        case parts[0] == "items" && len(parts) == 1:
+            feed, err = c.itemsFeed()
+        case parts[0] == "collections" && len(parts) == 2:
+            feed, err = c.collectionFeed(parts[1])
+        case parts[0] == "files" && len(parts) == 3:
+            err = c.serveAttachment(w, r, parts[1], parts[2])
+        default:
+            err = fmt.Errorf("page %w: %s", errNotFound, r.URL.Path)
+        }
+
+        switch {
+        case err != nil:
+            serveError(w, r, err)
+        case feed != nil:
+            w.Header().Set("Content-Type", feed.Links[0].Type)
+            w.Write([]byte(xml.Header))
+            enc := xml.NewEncoder(w)
+            enc.Indent("", "  ")
+            if err := enc.Encode(feed); err != nil {
+                slog.Debug("writing feed", "path", r.URL.Path, "error", err)
+            }
+        }
+    })
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "log/slog"
+)
+
+// defaultOpenMax caps how many items open -all launches at once
+const defaultOpenMax = 10
+
+// itemKeys resolves every positional argument as a stable ID, alias or
+// "last". It reports false when one of them is none of these, in which
+// case the arguments are meant as title words.
+func (c *CLI) itemKeys(positional []string) ([]string, bool, error) {
+    var keys []string
+    for _, arg := range positional {
+        key := arg
+        var err error
+        if arg == lastKeyword {
+            key, err = lastAccessed()
+        } else if alias, aliasErr := lookupAlias(arg); alias != "" || aliasErr != nil {
+            key, err = alias, aliasErr
+        }
+        if err != nil {
+            return nil, false, err
+        }
+        _, err = c.repo.GetByStableID(key)
+        if errors.Is(err, errNotFound) {
+            return nil, false, nil
+        }
+        if err != nil {
+            return nil, false, fmt.Errorf("getting item: %w", err)
+        }
+        keys = append(keys, key)
+    }
+    return keys, true, nil
+}
+
+// OpenItems opens the attachment of kind of every item, going on past the
+// ones that fail and reporting them at the end
+func (c *CLI) OpenItems(keys []string, kind string) error {
+    failed := 0
+    for _, key := range keys {
+        if err := c.OpenKind(key, kind); err != nil {
+            slog.Warn("could not open item", "key", key, "error", err)
+            failed++
+            continue
+        }
+        c.recordHistory("open", key)
+    }
+    if failed > 0 {
+        return fmt.Errorf("%d of %d items could not be opened", failed, len(keys))
+    }
+    return nil
+}
+
+// OpenMatching opens the items matching filter, at most max of them
+func (c *CLI) OpenMatching(filter Filter, kind string, max int) error {
+    keys, err := c.repo.ListKeys(filter)
+    if err != nil {
+        return fmt.Errorf("listing items: %w", err)
+    }
+    if len(keys) == 0 {
+        return fmt.Errorf("no matching items %w", errNotFound)
+    }
+    if max > 0 && len(keys) > max {
+        slog.Warn("opening only the first items", "max", max, "matching", len(keys))
+        keys = keys[:max]
+    }
+    return c.OpenItems(keys, kind)
+}
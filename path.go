@@ -0,0 +1,37 @@
+package main
+
+import (
+    "fmt"
+    "strings"
+)
+
+// Path prints the file path of the item's attachment, or of every stored
+// attachment with all. Records end with a NUL byte instead of a newline
+// with print0.
+func (c *CLI) Path(stableID string, all, print0 bool) error {
+    item, err := c.repo.GetByStableID(stableID)
+    if err != nil {
+        return fmt.Errorf("getting item: %w", err)
+    }
+
+    var paths []string
+    for _, att := range item.Attachments {
+        if att.Path == "" {
+            continue
+        }
+        if err := c.ensureLocal(att); err != nil {
+            return err
+        }
+        paths = append(paths, att.Path)
+        if !all {
+            break
+        }
+    }
+    if len(paths) == 0 {
+        return fmt.Errorf("%w for item: %s", errNoAttachment, stableID)
+    }
+
+    end := ListOptions{Print0: print0}.recordEnd()
+    fmt.Print(strings.Join(paths, end) + end)
+    return nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+    "bytes"
+    "compress/zlib"
+    "fmt"
+    "io"
+    "os"
+    "regexp"
+)
+
+// pdfStreamPattern finds the content streams of a PDF file
+var pdfStreamPattern = regexp.MustCompile(`(?s)stream\r?\n(.*?)\r?\nendstream`)
+
+// maxInflatedStream bounds how much of a single stream is decompressed
+// while looking for a DOI
+const maxInflatedStream = 4 << 20
+
+// findDOIInPDF looks for a DOI in the document information and XMP
+// metadata of a PDF and then in its compressed content streams, which
+// usually print the DOI on the first page
+func findDOIInPDF(path string) (string, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return "", fmt.Errorf("reading pdf: %w", err)
+    }
+    if !bytes.HasPrefix(data, []byte("%PDF")) {
+        return "", fmt.Errorf("%s is not a PDF file", path)
+    }
+
+    if doi := doiPattern.Find(data); doi != nil {
+        return normalizeDOI(string(doi)), nil
+    }
+    for _, match := range pdfStreamPattern.FindAllSubmatch(data, -1) {
+        r, err := zlib.NewReader(bytes.NewReader(match[1]))
+        if err != nil {
+            continue
+        }
+        // streams are often truncated or use other filters, so whatever
+        // could be inflated is searched
+        text, _ := io.ReadAll(io.LimitReader(r, maxInflatedStream))
+        r.Close()
+        if doi := doiPattern.Find(text); doi != nil {
+            return normalizeDOI(string(doi)), nil
+        }
+    }
+    return "", nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+    "database/sql"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net/url"
+    "os/exec"
+    "sort"
+    "strconv"
+)
+
+// pageViewers build the command that opens a PDF at a 1-based page in
+// viewers that accept a page on the command line. Preview cannot be told
+// which page to show, so it falls back to Zotero's reader.
+var pageViewers = map[string]func(path string, page int) *exec.Cmd{
+    "evince": func(path string, page int) *exec.Cmd {
+        return exec.Command("evince", "--page-index="+strconv.Itoa(page), path)
+    },
+    "okular": func(path string, page int) *exec.Cmd {
+        return exec.Command("okular", "--page", strconv.Itoa(page), path)
+    },
+    "zathura": func(path string, page int) *exec.Cmd {
+        return exec.Command("zathura", "--page="+strconv.Itoa(page), path)
+    },
+    "sumatra": func(path string, page int) *exec.Cmd {
+        return exec.Command("SumatraPDF.exe", "-reuse-instance", "-page", strconv.Itoa(page), path)
+    },
+    "preview": nil,
+    "skim": func(path string, page int) *exec.Cmd {
+        return exec.Command("osascript",
+            "-e", `on run argv`,
+            "-e", `tell application "Skim"`,
+            "-e", `open POSIX file (item 1 of argv)`,
+            "-e", `go front document to page (item 2 of argv as integer) of front document`,
+            "-e", `activate`,
+            "-e", `end tell`,
+            "-e", `end run`,
+            path, strconv.Itoa(page))
+    },
+}
+
+// pageViewerNames lists the viewers that support opening at a page
+func pageViewerNames() []string {
+    names := make([]string, 0, len(pageViewers))
+    for name := range pageViewers {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names
+}
+
+const annotationQuery = `
+    SELECT parent.key, a.position
+    FROM itemAnnotations a
+    JOIN items annotation ON a.itemID = annotation.itemID
+    JOIN items parent ON a.parentItemID = parent.itemID
+    WHERE annotation.key = ?`
+
+// annotationPage returns the attachment key and 1-based page of an annotation
+func (r *Repository) annotationPage(annotationKey string) (string, int, error) {
+    if !r.hasTable("itemAnnotations") {
+        return "", 0, fmt.Errorf("annotation %w: %s, the database predates Zotero 6's annotations", errNotFound, annotationKey)
+    }
+    var attachmentKey, position string
+    err := r.queryRow(annotationQuery, annotationKey).Scan(&attachmentKey, &position)
+    if errors.Is(err, sql.ErrNoRows) {
+        return "", 0, fmt.Errorf("annotation %w: %s", errNotFound, annotationKey)
+    }
+    if err != nil {
+        return "", 0, fmt.Errorf("fetching annotation: %w", err)
+    }
+
+    var pos struct {
+        PageIndex int `json:"pageIndex"`
+    }
+    if err := json.Unmarshal([]byte(position), &pos); err != nil {
+        return "", 0, fmt.Errorf("parsing annotation position: %w", err)
+    }
+    return attachmentKey, pos.PageIndex + 1, nil
+}
+
+// pdfAttachment returns the first PDF attached to item
+func pdfAttachment(item *Item) *Attachment {
+    for _, att := range item.Attachments {
+        if att.ContentType == "application/pdf" {
+            return att
+        }
+    }
+    return nil
+}
+
+// OpenAt opens the item's PDF at a page, or at the page of an annotation.
+// Without a viewer that supports pages the PDF is opened in Zotero's own
+// reader through a zotero://open-pdf link.
+func (c *CLI) OpenAt(stableID string, page int, annotationKey, viewer string) error {
+    item, err := c.repo.GetByStableID(stableID)
+    if err != nil {
+        return fmt.Errorf("getting item: %w", err)
+    }
+
+    var att *Attachment
+    if annotationKey != "" {
+        attachmentKey, annotationPage, err := c.repo.annotationPage(annotationKey)
+        if err != nil {
+            return err
+        }
+        for _, candidate := range item.Attachments {
+            if candidate.StableID == attachmentKey {
+                att = candidate
+            }
+        }
+        if att == nil {
+            return fmt.Errorf("annotation %s does not belong to item: %s", annotationKey, stableID)
+        }
+        page = annotationPage
+    } else {
+        att = pdfAttachment(item)
+    }
+    if att == nil {
+        return fmt.Errorf("%w for item: %s (no pdf)", errNoAttachment, stableID)
+    }
+
+    build, ok := pageViewers[viewer]
+    if build != nil && att.Path != "" {
+        if err := build(att.Path, page).Run(); err != nil {
+            return fmt.Errorf("running %s: %w", viewer, err)
+        }
+        return nil
+    }
+    if !ok && viewer != "" {
+        return fmt.Errorf("unsupported viewer %q, expected one of %v", viewer, pageViewerNames())
+    }
+
+    query := url.Values{}
+    if annotationKey != "" {
+        query.Set("annotation", annotationKey)
+    } else {
+        query.Set("page", strconv.Itoa(page))
+    }
+    uri := "zotero://open-pdf/library/items/" + att.StableID + "?" + query.Encode()
+
+    cmd := exec.Command("open", uri)
+    if err := cmd.Run(); err != nil {
+        return fmt.Errorf("opening %s: %w", uri, err)
+    }
+    return nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+    "bufio"
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "slices"
+    "strings"
+)
+
+// pinsPath returns the location of the pinned items file
+func pinsPath() (string, error) {
+    dir, err := stateDir()
+    if err != nil {
+        return "", err
+    }
+    return filepath.Join(dir, "pins.txt"), nil
+}
+
+// readPins returns the stable IDs of pinned items in the order they were
+// pinned
+func readPins() ([]string, error) {
+    path, err := pinsPath()
+    if err != nil {
+        return nil, err
+    }
+    f, err := os.Open(path)
+    if errors.Is(err, os.ErrNotExist) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("opening pins: %w", err)
+    }
+    defer f.Close()
+
+    var keys []string
+    scanner := bufio.NewScanner(f)
+    for scanner.Scan() {
+        if key := strings.TrimSpace(scanner.Text()); key != "" {
+            keys = append(keys, key)
+        }
+    }
+    if err := scanner.Err(); err != nil {
+        return nil, fmt.Errorf("reading pins: %w", err)
+    }
+    return keys, nil
+}
+
+// writePins replaces the pinned items file with keys
+func writePins(keys []string) error {
+    path, err := pinsPath()
+    if err != nil {
+        return err
+    }
+    if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+        return fmt.Errorf("creating state folder: %w", err)
+    }
+    var content strings.Builder
+    for _, key := range keys {
+        content.WriteString(key + "\n")
+    }
+    if err := os.WriteFile(path, []byte(content.String()), 0644); err != nil {
+        return fmt.Errorf("writing pins: %w", err)
+    }
+    return nil
+}
+
+// pinnedCondition restricts a query to pinned items. It reports false
+// when nothing is pinned, in which case no item can match.
+func pinnedCondition() (string, []interface{}, bool, error) {
+    keys, err := readPins()
+    if err != nil || len(keys) == 0 {
+        return "", nil, false, err
+    }
+    args := make([]interface{}, len(keys))
+    for i, key := range keys {
+        args[i] = key
+    }
+    placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(keys)), ", ")
+    return "i.key IN (" + placeholders + ")", args, true, nil
+}
+
+// Pin adds the item to the end of the pinned list
+func (c *CLI) Pin(stableID string) error {
+    keys, err := readPins()
+    if err != nil {
+        return err
+    }
+    if slices.Contains(keys, stableID) || c.pretend("pin %s", stableID) {
+        return nil
+    }
+    return writePins(append(keys, stableID))
+}
+
+// Unpin removes the item from the pinned list
+func (c *CLI) Unpin(stableID string) error {
+    keys, err := readPins()
+    if err != nil {
+        return err
+    }
+    i := slices.Index(keys, stableID)
+    if i < 0 {
+        return fmt.Errorf("pinned item %w: %s", errNotFound, stableID)
+    }
+    if c.pretend("unpin %s", stableID) {
+        return nil
+    }
+    return writePins(slices.Delete(keys, i, i+1))
+}
+
+// Pins displays the pinned items in the order they were pinned
+func (c *CLI) Pins(opts ListOptions) error {
+    keys, err := readPins()
+    if err != nil {
+        return err
+    }
+    items, err := c.repo.ListItems(Filter{Pinned: true})
+    if err != nil {
+        return fmt.Errorf("listing items: %w", err)
+    }
+    slices.SortFunc(items, func(a, b *Item) int {
+        return slices.Index(keys, a.StableID) - slices.Index(keys, b.StableID)
+    })
+    return c.printItems(items, opts)
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+    "fmt"
+    "math/rand/v2"
+)
+
+// Random displays one item picked at random among those matching filter
+func (c *CLI) Random(filter Filter, opts ListOptions) error {
+    items, err := c.repo.ListItems(filter)
+    if err != nil {
+        return fmt.Errorf("listing items: %w", err)
+    }
+    // the pick has to come from the items the scripts keep
+    script, err := opts.itemScript()
+    if err != nil {
+        return err
+    }
+    if script != nil {
+        if items, err = script.filter(items); err != nil {
+            return err
+        }
+    }
+    if len(items) == 0 {
+        return fmt.Errorf("item %w: nothing matches the filters", errNotFound)
+    }
+    return c.printKept([]*Item{items[rand.IntN(len(items))]}, opts, script)
+}
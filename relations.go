@@ -0,0 +1,100 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+)
+
+// relationPredicate is the predicate Zotero uses for the "Related" pane
+const relationPredicate = "dc:relation"
+
+// Relation objects are URIs ending in the 8 character item key, e.g.
+// http://zotero.org/users/local/abcd1234/items/ABCD2345
+const relatedKeysQuery = `
+    SELECT SUBSTR(ir.object, -8)
+    FROM itemRelations ir
+    JOIN relationPredicates rp ON ir.predicateID = rp.predicateID
+    JOIN items i ON ir.itemID = i.itemID
+    WHERE rp.predicate = ? AND i.key = ?
+    UNION
+    SELECT i.key
+    FROM itemRelations ir
+    JOIN relationPredicates rp ON ir.predicateID = rp.predicateID
+    JOIN items i ON ir.itemID = i.itemID
+    WHERE rp.predicate = ? AND SUBSTR(ir.object, -8) = ?
+    ORDER BY 1`
+
+// relatedToCondition matches items related to a given key in either direction
+const relatedToCondition = `EXISTS (
+            SELECT 1 FROM itemRelations ir
+            JOIN relationPredicates rp ON ir.predicateID = rp.predicateID
+            WHERE rp.predicate = '` + relationPredicate + `'
+            AND ((ir.itemID = i.itemID AND SUBSTR(ir.object, -8) = ?)
+                OR (SUBSTR(ir.object, -8) = i.key
+                    AND ir.itemID IN (SELECT itemID FROM items WHERE key = ?))))`
+
+// RelatedKeys retrieves the stable IDs of items related to stableID
+func (r *Repository) RelatedKeys(stableID string) ([]string, error) {
+    if !r.hasTable("itemRelations") {
+        return nil, nil
+    }
+    rows, err := r.query(relatedKeysQuery,
+        relationPredicate, stableID,
+        relationPredicate, stableID)
+    if err != nil {
+        return nil, fmt.Errorf("executing query: %w", err)
+    }
+    defer rows.Close()
+
+    var keys []string
+    for rows.Next() {
+        var key string
+        if err := rows.Scan(&key); err != nil {
+            return nil, fmt.Errorf("scanning row: %w", err)
+        }
+        keys = append(keys, key)
+    }
+
+    if err = rows.Err(); err != nil {
+        return nil, fmt.Errorf("iterating rows: %w", err)
+    }
+
+    return keys, nil
+}
+
+// Related prints the items related to stableID, following relations up to
+// depth hops away
+func (c *CLI) Related(stableID string, depth int, opts ListOptions) error {
+    seen := map[string]bool{stableID: true}
+    frontier := []string{stableID}
+    var items []*Item
+
+    for hop := 0; hop < depth && len(frontier) > 0; hop++ {
+        var next []string
+        for _, key := range frontier {
+            related, err := c.repo.RelatedKeys(key)
+            if err != nil {
+                return fmt.Errorf("getting relations: %w", err)
+            }
+            for _, relatedKey := range related {
+                if seen[relatedKey] {
+                    continue
+                }
+                seen[relatedKey] = true
+
+                item, err := c.repo.GetByStableID(relatedKey)
+                if errors.Is(err, errNotFound) {
+                    // relation points at a deleted item or another library
+                    continue
+                }
+                if err != nil {
+                    return fmt.Errorf("getting item: %w", err)
+                }
+                items = append(items, item)
+                next = append(next, relatedKey)
+            }
+        }
+        frontier = next
+    }
+    return c.printItems(items, opts)
+}
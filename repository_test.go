@@ -0,0 +1,280 @@
+package main
+
+import (
+    "context"
+    "database/sql"
+    "database/sql/driver"
+    "fmt"
+    "os"
+    "path/filepath"
+    "sync"
+    "sync/atomic"
+    "testing"
+)
+
+// benchmarkSchema is the part of Zotero's schema the item queries read
+const benchmarkSchema = `
+    CREATE TABLE libraries (libraryID INTEGER PRIMARY KEY, type TEXT NOT NULL, editable INT NOT NULL, filesEditable INT NOT NULL, version INT NOT NULL DEFAULT 0, storageVersion INT NOT NULL DEFAULT 0, lastSync INT NOT NULL DEFAULT 0, archived INT NOT NULL DEFAULT 0);
+    INSERT INTO libraries VALUES (1, 'user', 1, 1, 0, 0, 0, 0);
+    CREATE TABLE itemTypes (itemTypeID INTEGER PRIMARY KEY, typeName TEXT, templateItemTypeID INT, display INT DEFAULT 1);
+    INSERT INTO itemTypes VALUES (1, 'annotation', NULL, 0), (2, 'attachment', NULL, 0), (3, 'note', NULL, 0), (4, 'journalArticle', NULL, 1);
+    CREATE TABLE fields (fieldID INTEGER PRIMARY KEY, fieldName TEXT, fieldFormatID INT);
+    INSERT INTO fields VALUES (1, 'title', NULL), (2, 'abstractNote', NULL), (3, 'date', NULL), (4, 'publicationTitle', NULL), (5, 'DOI', NULL), (6, 'extra', NULL);
+    CREATE TABLE items (itemID INTEGER PRIMARY KEY, itemTypeID INT NOT NULL, dateAdded TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, dateModified TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, clientDateModified TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, libraryID INT NOT NULL, key TEXT NOT NULL, version INT NOT NULL DEFAULT 0, synced INT NOT NULL DEFAULT 0, UNIQUE (libraryID, key));
+    CREATE TABLE itemDataValues (valueID INTEGER PRIMARY KEY, value UNIQUE);
+    CREATE TABLE itemData (itemID INT, fieldID INT, valueID, PRIMARY KEY (itemID, fieldID));
+    CREATE TABLE itemNotes (itemID INTEGER PRIMARY KEY, parentItemID INT, note TEXT, title TEXT);
+    CREATE TABLE itemAttachments (itemID INTEGER PRIMARY KEY, parentItemID INT, linkMode INT, contentType TEXT, charsetID INT, path TEXT, syncState INT DEFAULT 0, storageModTime INT, storageHash TEXT, lastProcessedModificationTime INT);
+    CREATE TABLE tags (tagID INTEGER PRIMARY KEY, name TEXT NOT NULL UNIQUE);
+    INSERT INTO tags VALUES (1, 'alpha'), (2, 'beta');
+    CREATE TABLE itemTags (itemID INT NOT NULL, tagID INT NOT NULL, type INT NOT NULL, PRIMARY KEY (itemID, tagID));
+    CREATE TABLE creators (creatorID INTEGER PRIMARY KEY, firstName TEXT NOT NULL, lastName TEXT NOT NULL, fieldMode INT, UNIQUE (lastName, firstName, fieldMode));
+    CREATE TABLE creatorTypes (creatorTypeID INTEGER PRIMARY KEY, creatorType TEXT);
+    INSERT INTO creatorTypes VALUES (1, 'author');
+    CREATE TABLE itemCreators (itemID INT NOT NULL, creatorID INT NOT NULL, creatorTypeID INT NOT NULL DEFAULT 1, orderIndex INT NOT NULL DEFAULT 0, PRIMARY KEY (itemID, creatorID, creatorTypeID, orderIndex));
+    CREATE TABLE collections (collectionID INTEGER PRIMARY KEY, collectionName TEXT NOT NULL, parentCollectionID INT DEFAULT NULL, clientDateModified TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, libraryID INT NOT NULL, key TEXT NOT NULL, version INT NOT NULL DEFAULT 0, synced INT NOT NULL DEFAULT 0);
+    INSERT INTO collections (collectionID, collectionName, libraryID, key) VALUES (1, 'Papers', 1, 'COLL0001');
+    CREATE TABLE collectionItems (collectionID INT NOT NULL, itemID INT NOT NULL, orderIndex INT NOT NULL DEFAULT 0, PRIMARY KEY (collectionID, itemID));
+    CREATE TABLE deletedItems (itemID INTEGER PRIMARY KEY, dateDeleted DEFAULT CURRENT_TIMESTAMP NOT NULL);
+    CREATE TABLE retractedItems (itemID INTEGER PRIMARY KEY, data TEXT, flag INT DEFAULT 0);
+    CREATE TABLE syncedSettings (setting TEXT NOT NULL, libraryID INT NOT NULL, value NOT NULL, version INT NOT NULL DEFAULT 0, synced INT NOT NULL DEFAULT 0, PRIMARY KEY (setting, libraryID));
+    INSERT INTO syncedSettings VALUES ('tagColors', 1, '[{"name":"alpha","color":"#FF6666"}]', 0, 0);`
+
+// seriesPrefix defines the numbers from 1 to the argument as series for
+// the statements of benchmarkItems
+const seriesPrefix = `WITH RECURSIVE series(value) AS (
+        SELECT 1 UNION ALL SELECT value + 1 FROM series WHERE value < ?) `
+
+// benchmarkItems fills the schema with items, each with a title, two
+// creators, two tags, a PDF attachment, a note and a collection
+var benchmarkItems = []string{
+    `INSERT INTO items (itemID, itemTypeID, libraryID, key)
+        SELECT value, 4, 1, printf('ITEM%04d', value) FROM series`,
+    `INSERT INTO itemDataValues SELECT value, printf('Paper %d', value) FROM series`,
+    `INSERT INTO itemData SELECT value, 1, value FROM series`,
+    `INSERT INTO creators SELECT value, 'Ada', printf('Author %d', value), 0 FROM series`,
+    `INSERT INTO itemCreators SELECT value, value, 1, 0 FROM series`,
+    `INSERT INTO itemCreators SELECT value, 1, 1, 1 FROM series`,
+    `INSERT INTO itemTags SELECT value, 1, 0 FROM series`,
+    `INSERT INTO itemTags SELECT value, 2, 0 FROM series`,
+    `INSERT INTO collectionItems SELECT 1, value, 0 FROM series`,
+    `INSERT INTO items (itemID, itemTypeID, libraryID, key)
+        SELECT 100000 + value, 2, 1, printf('ATTA%04d', value) FROM series`,
+    `INSERT INTO itemAttachments (itemID, parentItemID, linkMode, contentType, path)
+        SELECT 100000 + value, value, 0, 'application/pdf', 'storage:paper.pdf' FROM series`,
+    `INSERT INTO items (itemID, itemTypeID, libraryID, key)
+        SELECT 200000 + value, 3, 1, printf('NOTE%04d', value) FROM series`,
+    `INSERT INTO itemNotes SELECT 200000 + value, value, '<p>Read it</p>', 'Read it' FROM series`,
+}
+
+// countingConnector opens connections of the repository's driver that
+// count the statements run on them
+type countingConnector struct {
+    dsn     string
+    driver  driver.Driver
+    queries *atomic.Int64
+}
+
+// Connect implements driver.Connector
+func (c countingConnector) Connect(context.Context) (driver.Conn, error) {
+    conn, err := c.driver.Open(c.dsn)
+    if err != nil {
+        return nil, err
+    }
+    return countingConn{Conn: conn, queries: c.queries}, nil
+}
+
+// Driver implements driver.Connector
+func (c countingConnector) Driver() driver.Driver {
+    return c.driver
+}
+
+// countingConn hands out statements counting their queries
+type countingConn struct {
+    driver.Conn
+    queries *atomic.Int64
+}
+
+// Prepare implements driver.Conn
+func (c countingConn) Prepare(query string) (driver.Stmt, error) {
+    stmt, err := c.Conn.Prepare(query)
+    if err != nil {
+        return nil, err
+    }
+    return countingStmt{Stmt: stmt, queries: c.queries}, nil
+}
+
+// countingStmt counts the times it is queried
+type countingStmt struct {
+    driver.Stmt
+    queries *atomic.Int64
+}
+
+// Query implements driver.Stmt
+func (s countingStmt) Query(args []driver.Value) (driver.Rows, error) {
+    s.queries.Add(1)
+    return s.Stmt.Query(args)
+}
+
+// benchmarkRepository creates a library of n items and returns a
+// repository on it along with the count of queries it runs
+func benchmarkRepository(b testing.TB, n int) (*Repository, *atomic.Int64) {
+    b.Helper()
+    path := filepath.Join(b.TempDir(), "zotero.sqlite")
+
+    setup, err := sql.Open(driverName, path)
+    if err != nil {
+        b.Fatal(err)
+    }
+    defer setup.Close()
+    if _, err := setup.Exec(benchmarkSchema); err != nil {
+        b.Fatalf("creating schema: %v", err)
+    }
+    for _, statement := range benchmarkItems {
+        if _, err := setup.Exec(seriesPrefix+statement, n); err != nil {
+            b.Fatalf("adding items: %v", err)
+        }
+    }
+
+    queries := new(atomic.Int64)
+    db := sql.OpenDB(countingConnector{dsn: path, driver: setup.Driver(), queries: queries})
+    b.Cleanup(func() { db.Close() })
+    repo := NewRepository(db, Config{})
+    b.Cleanup(func() { repo.Close() })
+    return repo, queries
+}
+
+// benchmarkSizes are the library sizes each benchmark runs against; an
+// operation costing a query per item shows as queries/op growing with them
+var benchmarkSizes = []int{10, 1000}
+
+// benchmarkQueries runs op against libraries of every benchmark size,
+// reporting the queries it runs, and fails when that number depends on
+// the size of the library
+func benchmarkQueries(b *testing.B, op func(b *testing.B, repo *Repository, n int)) {
+    perOp := make(map[int]float64)
+    for _, n := range benchmarkSizes {
+        b.Run(fmt.Sprintf("items=%d", n), func(b *testing.B) {
+            repo, queries := benchmarkRepository(b, n)
+            // the first round fills the statement and schema caches
+            op(b, repo, n)
+            queries.Store(0)
+            b.ResetTimer()
+            for range b.N {
+                op(b, repo, n)
+            }
+            b.StopTimer()
+            perOp[n] = float64(queries.Load()) / float64(b.N)
+            b.ReportMetric(perOp[n], "queries/op")
+        })
+    }
+    first := perOp[benchmarkSizes[0]]
+    for _, n := range benchmarkSizes[1:] {
+        if queries, ok := perOp[n]; ok && queries != first {
+            b.Errorf("%v queries/op for %d items, %v for %d", queries, n, first, benchmarkSizes[0])
+        }
+    }
+}
+
+// TestStatementEviction runs more distinct queries than the statement
+// cache holds from several goroutines at once, as serve does, none of
+// which may find its statement closed under it
+func TestStatementEviction(t *testing.T) {
+    repo, _ := benchmarkRepository(t, 10)
+    var wg sync.WaitGroup
+    errs := make(chan error, 8)
+    for worker := range 8 {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for i := range 2 * maxStatements {
+                var n int
+                query := fmt.Sprintf("SELECT %d + ?", worker*1000+i)
+                if err := repo.queryRow(query, 1).Scan(&n); err != nil {
+                    errs <- err
+                    return
+                }
+            }
+        }()
+    }
+    wg.Wait()
+    close(errs)
+    for err := range errs {
+        t.Error(err)
+    }
+}
+
+func BenchmarkListItems(b *testing.B) {
+    benchmarkQueries(b, func(b *testing.B, repo *Repository, n int) {
+        items, err := repo.ListItems(Filter{})
+        if err != nil {
+            b.Fatal(err)
+        }
+        if len(items) != n || len(items[0].Creators) != 2 || len(items[0].Attachments) != 1 {
+            b.Fatalf("got %d items, expected %d with their children", len(items), n)
+        }
+    })
+}
+
+func BenchmarkGetByStableID(b *testing.B) {
+    benchmarkQueries(b, func(b *testing.B, repo *Repository, n int) {
+        item, err := repo.GetByStableID(fmt.Sprintf("ITEM%04d", n))
+        if err != nil {
+            b.Fatal(err)
+        }
+        if len(item.Tags) != 2 {
+            b.Fatalf("got tags %v, expected two", item.Tags)
+        }
+    })
+}
+
+func BenchmarkLoadChildren(b *testing.B) {
+    var items []*Item
+    benchmarkQueries(b, func(b *testing.B, repo *Repository, n int) {
+        if len(items) != n {
+            var err error
+            if items, err = repo.ListItems(Filter{}); err != nil {
+                b.Fatal(err)
+            }
+        }
+        if err := repo.loadChildren(items); err != nil {
+            b.Fatal(err)
+        }
+    })
+}
+
+func BenchmarkPrintItemsJSON(b *testing.B) {
+    discardStdout(b)
+    benchmarkQueries(b, func(b *testing.B, repo *Repository, n int) {
+        if err := NewCLI(repo, Config{}).List(Filter{}, ListOptions{Format: "json"}); err != nil {
+            b.Fatal(err)
+        }
+    })
+}
+
+func BenchmarkBuildDump(b *testing.B) {
+    benchmarkQueries(b, func(b *testing.B, repo *Repository, n int) {
+        dump, err := repo.BuildDump()
+        if err != nil {
+            b.Fatal(err)
+        }
+        if len(dump.Items) != n || len(dump.Items[0].Notes) != 1 || len(dump.Items[0].Fields) != 1 {
+            b.Fatalf("got %d items, expected %d with their notes and fields", len(dump.Items), n)
+        }
+    })
+}
+
+// discardStdout sends what the benchmark prints to /dev/null
+func discardStdout(b *testing.B) {
+    b.Helper()
+    null, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+    if err != nil {
+        b.Fatal(err)
+    }
+    stdout := os.Stdout
+    os.Stdout = null
+    b.Cleanup(func() {
+        os.Stdout = stdout
+        null.Close()
+    })
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+    "bufio"
+    "errors"
+    "fmt"
+    "io"
+    "os"
+    "strconv"
+    "strings"
+
+    "golang.org/x/term"
+)
+
+// itemArgument determines the item a command operates on: the stable ID
+// given as the only positional argument, or else the item matching filter.
+// A positional argument that is not a stable ID is taken as a fuzzy title,
+// except for "last" which names the most recently accessed item and the
+// aliases set with alias. It returns an empty ID when neither was given.
+func (c *CLI) itemArgument(positional []string, filter Filter, first bool) (string, error) {
+    if len(positional) == 1 && positional[0] == lastKeyword {
+        return lastAccessed()
+    }
+    if len(positional) == 1 {
+        key, err := lookupAlias(positional[0])
+        if err != nil || key != "" {
+            return key, err
+        }
+        _, err = c.repo.GetByStableID(positional[0])
+        if err == nil {
+            return positional[0], nil
+        }
+        if !errors.Is(err, errNotFound) {
+            return "", fmt.Errorf("getting item: %w", err)
+        }
+    }
+
+    switch {
+    case len(positional) > 0:
+        filter.TitleWords = strings.Join(positional, " ")
+        return c.resolveItem(filter, first)
+    case filter != (Filter{}):
+        return c.resolveItem(filter, first)
+    default:
+        return "", nil
+    }
+}
+
+// isTerminal reports whether f is attached to a terminal
+func isTerminal(f *os.File) bool {
+    return term.IsTerminal(int(f.Fd()))
+}
+
+// resolveItem returns the stable ID of the single item matching filter.
+// When several items match the user picks one from a numbered list, unless
+// first is set, in which case the first match wins.
+func (c *CLI) resolveItem(filter Filter, first bool) (string, error) {
+    items, err := c.repo.ListItems(filter)
+    if err != nil {
+        return "", fmt.Errorf("listing items: %w", err)
+    }
+
+    switch {
+    case len(items) == 0:
+        return "", fmt.Errorf("item %w: nothing matches the filters", errNotFound)
+    case len(items) == 1 || first:
+        return items[0].StableID, nil
+    case !isTerminal(os.Stdin):
+        // nobody to ask, so refuse to guess
+        return "", fmt.Errorf("%d items match, narrow the filters or use -first", len(items))
+    }
+
+    item, err := chooseItem(items, os.Stdin, os.Stderr)
+    if err != nil {
+        return "", err
+    }
+    return item.StableID, nil
+}
+
+// chooseItem prints a numbered list of items to out and reads the number of
+// the chosen one from in
+func chooseItem(items []*Item, in io.Reader, out io.Writer) (*Item, error) {
+    for i, item := range items {
+        fmt.Fprintf(out, "%3d) %-8s  %s\n", i+1, item.StableID, item.Title)
+    }
+    fmt.Fprintf(out, "Select item [1-%d]: ", len(items))
+
+    line, err := bufio.NewReader(in).ReadString('\n')
+    if err != nil && line == "" {
+        return nil, fmt.Errorf("reading selection: %w", err)
+    }
+    n, err := strconv.Atoi(strings.TrimSpace(line))
+    if err != nil || n < 1 || n > len(items) {
+        return nil, fmt.Errorf("invalid selection: %q", strings.TrimSpace(line))
+    }
+    return items[n-1], nil
+}
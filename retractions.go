@@ -0,0 +1,91 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "log/slog"
+)
+
+// retractedMarker flags retracted items in listings and citations
+const retractedMarker = "[RETRACTED]"
+
+// Retraction is Zotero's record of an item's retraction, taken from the
+// Retraction Watch data it checks DOIs and PMIDs against
+type Retraction struct {
+    Date    string   `json:"date,omitempty"`
+    Reasons []string `json:"reasons,omitempty"`
+}
+
+// retractionsQuery reads the retraction records of the items with the
+// given stable IDs. Items whose notice the user hid in Zotero are still
+// returned: the paper stays retracted.
+const retractionsQuery = `
+    SELECT i.key, COALESCE(r.data, '')
+    FROM retractedItems r
+    JOIN items i ON r.itemID = i.itemID
+    WHERE i.key IN (SELECT value FROM json_each(?))`
+
+// Retractions retrieves the retractions of the items with the given stable
+// IDs, keyed by stable ID. Databases of Zotero versions before 5.0.57 have
+// none.
+func (r *Repository) Retractions(keys []string) (map[string]*Retraction, error) {
+    retractions := make(map[string]*Retraction)
+    if len(keys) == 0 || !r.hasTable("retractedItems") {
+        return retractions, nil
+    }
+    encoded, err := json.Marshal(keys)
+    if err != nil {
+        return nil, fmt.Errorf("encoding keys: %w", err)
+    }
+    rows, err := r.query(retractionsQuery, string(encoded))
+    if err != nil {
+        return nil, fmt.Errorf("executing query: %w", err)
+    }
+    defer rows.Close()
+
+    for rows.Next() {
+        var key, data string
+        if err := rows.Scan(&key, &data); err != nil {
+            return nil, fmt.Errorf("scanning row: %w", err)
+        }
+        var retraction Retraction
+        if err := json.Unmarshal([]byte(data), &retraction); err != nil && data != "" {
+            slog.Warn("unreadable retraction data", "key", key, "error", err)
+        }
+        retractions[key] = &retraction
+    }
+
+    if err = rows.Err(); err != nil {
+        return nil, fmt.Errorf("iterating rows: %w", err)
+    }
+
+    return retractions, nil
+}
+
+// markedTitle returns the item's title, led by the retracted marker when
+// the item was retracted
+func markedTitle(item *Item) string {
+    if item.Retraction != nil {
+        return retractedMarker + " " + item.Title
+    }
+    return item.Title
+}
+
+// warnRetracted warns about the retracted items among the cited entries
+// of a bibliography, which still get written
+func (c *CLI) warnRetracted(entries []string, index map[string]DumpItem) error {
+    keys := make([]string, len(entries))
+    for i, entry := range entries {
+        keys[i] = index[entry].Key
+    }
+    retractions, err := c.repo.Retractions(keys)
+    if err != nil {
+        return fmt.Errorf("fetching retractions: %w", err)
+    }
+    for i, entry := range entries {
+        if retraction, ok := retractions[keys[i]]; ok {
+            slog.Warn("citing a retracted item", "citekey", entry, "key", keys[i], "date", retraction.Date)
+        }
+    }
+    return nil
+}
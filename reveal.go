@@ -0,0 +1,65 @@
+package main
+
+import (
+    "bytes"
+    "fmt"
+    "net/url"
+    "os/exec"
+    "path/filepath"
+    "runtime"
+)
+
+// revealCommand builds the command showing path in the system's file
+// manager, selected where the file manager supports it
+func revealCommand(path string) *exec.Cmd {
+    switch runtime.GOOS {
+    case "darwin":
+        return exec.Command("open", "-R", path)
+    case "windows":
+        return exec.Command("explorer", "/select,"+path)
+    }
+    // Nautilus, Dolphin and Nemo select files asked for over D-Bus; other
+    // desktops get the folder opened
+    if _, err := exec.LookPath("dbus-send"); err == nil {
+        uri := (&url.URL{Scheme: "file", Path: path}).String()
+        return exec.Command("dbus-send", "--session", "--print-reply",
+            "--dest=org.freedesktop.FileManager1", "--type=method_call",
+            "/org/freedesktop/FileManager1", "org.freedesktop.FileManager1.ShowItems",
+            "array:string:"+uri, "string:")
+    }
+    return exec.Command("xdg-open", filepath.Dir(path))
+}
+
+// Reveal shows the item's attachment of the given kind, or the one
+// storageAttachment picks when kind is empty, in the file manager
+func (c *CLI) Reveal(stableID, kind string) error {
+    item, err := c.repo.GetByStableID(stableID)
+    if err != nil {
+        return fmt.Errorf("getting item: %w", err)
+    }
+    att := storageAttachment(item)
+    if kind != "" {
+        att = attachmentOfKind(item, kind)
+    }
+    if att == nil {
+        return fmt.Errorf("%w for item: %s", errNoAttachment, stableID)
+    }
+    if err := c.ensureLocal(att); err != nil {
+        return err
+    }
+    if !att.Exists() {
+        return fmt.Errorf("%w for item: %s (%s is not on disk)", errNoAttachment, stableID, att.Path)
+    }
+
+    cmd := revealCommand(att.Path)
+    if out, err := cmd.CombinedOutput(); err != nil {
+        // without a file manager answering on D-Bus the folder still opens
+        if cmd.Args[0] == "dbus-send" {
+            if err := exec.Command("xdg-open", filepath.Dir(att.Path)).Run(); err == nil {
+                return nil
+            }
+        }
+        return fmt.Errorf("revealing file: %w: %s", err, bytes.TrimSpace(out))
+    }
+    return nil
+}
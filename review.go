@@ -0,0 +1,175 @@
+package main
+
+import (
+    "cmp"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "slices"
+    "strings"
+)
+
+// zoteroColors names the colors of the annotation palette of Zotero's
+// reader
+var zoteroColors = map[string]string{
+    "#ffd400": "yellow",
+    "#ff6666": "red",
+    "#5fb236": "green",
+    "#2ea8e5": "blue",
+    "#a28ae5": "purple",
+    "#e56eee": "magenta",
+    "#f19837": "orange",
+    "#aaaaaa": "gray",
+}
+
+// untaggedGroup heads the annotations without tags when grouping by tag
+const untaggedGroup = "Untagged"
+
+// colorLabel returns the label configured for an annotation color, looked
+// up by hex value or palette name and falling back to the palette name
+func (cfg Config) colorLabel(color string) string {
+    name := zoteroColors[strings.ToLower(color)]
+    for _, want := range []string{color, name} {
+        for key, label := range cfg.AnnotationColors {
+            if want != "" && label != "" && strings.EqualFold(key, want) {
+                return label
+            }
+        }
+    }
+    return cmp.Or(name, color)
+}
+
+// JSONAnnotation is the JSON representation of an annotation
+type JSONAnnotation struct {
+    Key        string   `json:"key"`
+    Type       string   `json:"type"`
+    Text       string   `json:"text,omitempty"`
+    Comment    string   `json:"comment,omitempty"`
+    Color      string   `json:"color,omitempty"`
+    ColorLabel string   `json:"colorLabel,omitempty"`
+    Page       string   `json:"page,omitempty"`
+    Image      string   `json:"image,omitempty"`
+    Tags       []string `json:"tags"`
+}
+
+// annotationGroup is a heading of a review document and the annotations
+// under it
+type annotationGroup struct {
+    Name        string           `json:"group,omitempty"`
+    Annotations []JSONAnnotation `json:"annotations"`
+}
+
+// groupAnnotations sorts annotations under their color label or tags, in
+// order of first appearance. Annotations with several tags appear under
+// each of them. Without groupBy everything goes into one unnamed group.
+// images holds the extracted images by annotation key.
+func (c *CLI) groupAnnotations(annotations []*Annotation, groupBy string, images map[string]string) []*annotationGroup {
+    groups := []*annotationGroup{}
+    byName := make(map[string]*annotationGroup)
+    add := func(name string, annotation JSONAnnotation) {
+        group, ok := byName[name]
+        if !ok {
+            group = &annotationGroup{Name: name}
+            byName[name] = group
+            groups = append(groups, group)
+        }
+        group.Annotations = append(group.Annotations, annotation)
+    }
+
+    for _, annotation := range annotations {
+        encoded := JSONAnnotation{
+            Key:        annotation.StableID,
+            Type:       annotation.TypeName(),
+            Text:       annotation.Text,
+            Comment:    annotation.Comment,
+            Color:      annotation.Color,
+            ColorLabel: c.cfg.colorLabel(annotation.Color),
+            Page:       annotation.PageLabel,
+            Image:      images[annotation.StableID],
+            Tags:       append([]string{}, annotation.Tags...),
+        }
+        switch groupBy {
+        case "color":
+            add(encoded.ColorLabel, encoded)
+        case "tag":
+            if len(annotation.Tags) == 0 {
+                add(untaggedGroup, encoded)
+            }
+            for _, tag := range annotation.Tags {
+                add(tag, encoded)
+            }
+        default:
+            add("", encoded)
+        }
+    }
+    return groups
+}
+
+// writeReview writes the annotation groups of item as a Markdown document
+func writeReview(w io.Writer, item *Item, groups []*annotationGroup) {
+    fmt.Fprintf(w, "# %s\n", item.Title)
+    for _, group := range groups {
+        if group.Name != "" {
+            fmt.Fprintf(w, "\n## %s\n", group.Name)
+        }
+        for _, annotation := range group.Annotations {
+            fmt.Fprintln(w)
+            source := "p. " + annotation.Page
+            if annotation.Page == "" {
+                source = annotation.Key
+            }
+            switch {
+            case annotation.Image != "":
+                fmt.Fprintf(w, "![%s annotation, %s](%s)\n", annotation.Type, source, filepath.ToSlash(annotation.Image))
+            case annotation.Text != "":
+                for _, line := range strings.Split(annotation.Text, "\n") {
+                    fmt.Fprintf(w, "> %s\n", line)
+                }
+                fmt.Fprintf(w, "> — %s\n", source)
+            default:
+                fmt.Fprintf(w, "*%s annotation, %s*\n", annotation.Type, source)
+            }
+            if annotation.Comment != "" {
+                fmt.Fprintf(w, "\n%s\n", annotation.Comment)
+            }
+        }
+    }
+}
+
+// annotationGroupings are the values accepted by -group-by
+var annotationGroupings = []string{"", "color", "tag"}
+
+// Review prints the annotations of an item as a Markdown review document
+// or as JSON, grouped by color label or tag. With imagesDir the regions of
+// image annotations are saved there as PNG files and linked.
+func (c *CLI) Review(stableID, groupBy, format, imagesDir string) error {
+    if !slices.Contains(annotationGroupings, groupBy) {
+        return fmt.Errorf("unknown grouping %q, expected color or tag", groupBy)
+    }
+    item, err := c.repo.GetByStableID(stableID)
+    if err != nil {
+        return fmt.Errorf("getting item: %w", err)
+    }
+    annotations, err := c.repo.Annotations(item.ID)
+    if err != nil {
+        return fmt.Errorf("fetching annotations: %w", err)
+    }
+    var images map[string]string
+    if imagesDir != "" {
+        if images, err = c.extractAnnotationImages(item, annotations, imagesDir); err != nil {
+            return err
+        }
+    }
+    groups := c.groupAnnotations(annotations, groupBy, images)
+
+    switch format {
+    case "", "markdown":
+        writeReview(os.Stdout, item, groups)
+        return nil
+    case "json":
+        return writeJSON(groups)
+    default:
+        return fmt.Errorf("unknown format: %s", format)
+    }
+}
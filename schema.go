@@ -0,0 +1,86 @@
+package main
+
+import (
+    "database/sql"
+    "errors"
+    "fmt"
+    "log/slog"
+    "sync"
+)
+
+// schemaVersionQuery reads the version of one of Zotero's schemas
+const schemaVersionQuery = `SELECT version FROM version WHERE schema = ?`
+
+const tablesQuery = `SELECT name FROM sqlite_master WHERE type = 'table'`
+
+// schemaInfo describes the database as the Zotero version that wrote it
+// laid it out
+type schemaInfo struct {
+    // Userdata is the version of Zotero's userdata schema, zero when the
+    // database does not record one
+    Userdata int
+    tables   map[string]bool
+}
+
+// has reports whether the database has the table
+func (s *schemaInfo) has(table string) bool {
+    return s.tables[table]
+}
+
+// schemaCache holds the schema of the database, detected on first use
+type schemaCache struct {
+    once sync.Once
+    info *schemaInfo
+    err  error
+}
+
+// detectSchema reads the tables and userdata schema version of the
+// database
+func (r *Repository) detectSchema() (*schemaInfo, error) {
+    rows, err := r.query(tablesQuery)
+    if err != nil {
+        return nil, fmt.Errorf("executing query: %w", err)
+    }
+    defer rows.Close()
+    info := &schemaInfo{tables: make(map[string]bool)}
+    for rows.Next() {
+        var name string
+        if err := rows.Scan(&name); err != nil {
+            return nil, fmt.Errorf("scanning row: %w", err)
+        }
+        info.tables[name] = true
+    }
+    if err = rows.Err(); err != nil {
+        return nil, fmt.Errorf("iterating rows: %w", err)
+    }
+
+    if info.has("version") {
+        err := r.queryRow(schemaVersionQuery, "userdata").Scan(&info.Userdata)
+        if err != nil && !errors.Is(err, sql.ErrNoRows) {
+            return nil, fmt.Errorf("fetching schema version: %w", err)
+        }
+    }
+    return info, nil
+}
+
+// schema returns the schema of the database, detecting it once
+func (r *Repository) schema() (*schemaInfo, error) {
+    r.schemaCache.once.Do(func() {
+        r.schemaCache.info, r.schemaCache.err = r.detectSchema()
+    })
+    return r.schemaCache.info, r.schemaCache.err
+}
+
+// hasTable reports whether the database has a table that only some Zotero
+// versions create, such as itemAnnotations (Zotero 6) or retractedItems.
+// Queries reading such tables check first and treat a missing one as
+// holding nothing. When the schema cannot be read the table is assumed
+// present, so the query itself reports the problem.
+func (r *Repository) hasTable(table string) bool {
+    info, err := r.schema()
+    if err != nil {
+        slog.Warn("cannot detect database schema", "error", err)
+        return true
+    }
+    return info.has(table)
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+    "fmt"
+    "os"
+    "strconv"
+    "strings"
+
+    "go.starlark.net/starlark"
+    "go.starlark.net/starlarkstruct"
+    "go.starlark.net/syntax"
+)
+
+// itemScript filters and prints items through Starlark: the -keep and
+// -print expressions, which see the fields of an item as variables, and
+// the keep(item) and format(item) functions of a -script file
+type itemScript struct {
+    thread *starlark.Thread
+
+    keepExpr, printExpr syntax.Expr
+    keepFunc, formatFunc starlark.Callable
+}
+
+// maxScriptSteps bounds the Starlark steps of loading a -script file and
+// of each run on an item, so that a runaway loop fails instead of hanging
+const maxScriptSteps = 10_000_000
+
+// scriptBuiltins are predeclared for -script files on top of Starlark's
+// universe
+var scriptBuiltins = starlark.StringDict{
+    "struct": starlark.NewBuiltin("struct", starlarkstruct.Make),
+}
+
+// itemScript compiles the scripts given in opts, returning nil when there
+// are none
+func (opts ListOptions) itemScript() (*itemScript, error) {
+    if opts.Keep == "" && opts.Print == "" && opts.Script == "" {
+        return nil, nil
+    }
+    script := &itemScript{thread: &starlark.Thread{
+        Name: "zotero-fetch",
+        // print() goes to stderr, keeping the listing clean
+        Print: func(_ *starlark.Thread, msg string) { fmt.Fprintln(os.Stderr, msg) },
+    }}
+
+    var err error
+    if opts.Keep != "" {
+        if script.keepExpr, err = syntax.ParseExpr("-keep", opts.Keep, 0); err != nil {
+            return nil, fmt.Errorf("parsing -keep: %w", err)
+        }
+    }
+    if opts.Print != "" {
+        if script.printExpr, err = syntax.ParseExpr("-print", opts.Print, 0); err != nil {
+            return nil, fmt.Errorf("parsing -print: %w", err)
+        }
+    }
+    if opts.Script != "" {
+        script.limit()
+        globals, err := starlark.ExecFile(script.thread, opts.Script, nil, scriptBuiltins)
+        if err != nil {
+            return nil, fmt.Errorf("loading script: %w", err)
+        }
+        for name, fn := range map[string]*starlark.Callable{"keep": &script.keepFunc, "format": &script.formatFunc} {
+            value, ok := globals[name]
+            if !ok {
+                continue
+            }
+            if *fn, ok = value.(starlark.Callable); !ok {
+                return nil, fmt.Errorf("%s: %s is a %s, expected a function", opts.Script, name, value.Type())
+            }
+        }
+        if script.keepFunc == nil && script.formatFunc == nil {
+            return nil, fmt.Errorf("%s defines neither keep(item) nor format(item)", opts.Script)
+        }
+    }
+    return script, nil
+}
+
+// limit allows the thread maxScriptSteps more steps, for the run about to
+// start
+func (s *itemScript) limit() {
+    s.thread.SetMaxExecutionSteps(s.thread.ExecutionSteps() + maxScriptSteps)
+}
+
+// formats reports whether the script prints items in place of the output
+// format
+func (s *itemScript) formats() bool {
+    return s.printExpr != nil || s.formatFunc != nil
+}
+
+// filter returns the items both -keep and keep(item) hold for
+func (s *itemScript) filter(items []*Item) ([]*Item, error) {
+    if s.keepExpr == nil && s.keepFunc == nil {
+        return items, nil
+    }
+    var kept []*Item
+    for _, item := range items {
+        fields := itemFields(item)
+        if s.keepExpr != nil {
+            s.limit()
+            value, err := starlark.EvalExpr(s.thread, s.keepExpr, fields)
+            if err != nil {
+                return nil, fmt.Errorf("running -keep on %s: %w", item.StableID, err)
+            }
+            if !value.Truth() {
+                continue
+            }
+        }
+        if s.keepFunc != nil {
+            s.limit()
+            value, err := starlark.Call(s.thread, s.keepFunc, starlark.Tuple{itemValue(fields)}, nil)
+            if err != nil {
+                return nil, fmt.Errorf("running keep on %s: %w", item.StableID, err)
+            }
+            if !value.Truth() {
+                continue
+            }
+        }
+        kept = append(kept, item)
+    }
+    return kept, nil
+}
+
+// print writes each item as -print or format(item) renders it, the
+// former taking precedence
+func (s *itemScript) print(items []*Item, end string) error {
+    for _, item := range items {
+        fields := itemFields(item)
+        var value starlark.Value
+        var err error
+        s.limit()
+        if s.printExpr != nil {
+            if value, err = starlark.EvalExpr(s.thread, s.printExpr, fields); err != nil {
+                return fmt.Errorf("running -print on %s: %w", item.StableID, err)
+            }
+        } else if value, err = starlark.Call(s.thread, s.formatFunc, starlark.Tuple{itemValue(fields)}, nil); err != nil {
+            return fmt.Errorf("running format on %s: %w", item.StableID, err)
+        }
+        if value == starlark.None {
+            continue
+        }
+        text, ok := starlark.AsString(value)
+        if !ok {
+            text = value.String()
+        }
+        fmt.Print(text + end)
+    }
+    return nil
+}
+
+// itemFields are the fields scripts see of an item. Year is 0 when the
+// item has no date, so that it compares with numbers.
+func itemFields(item *Item) starlark.StringDict {
+    year, _ := strconv.Atoi(item.Year)
+
+    var authors []starlark.Value
+    creators := make([]starlark.Value, len(item.Creators))
+    for i, creator := range item.Creators {
+        if creator.CreatorType == "author" {
+            authors = append(authors, starlark.String(strings.TrimSpace(creator.FirstName+" "+creator.LastName)))
+        }
+        creators[i] = starlarkstruct.FromStringDict(starlark.String("creator"), starlark.StringDict{
+            "first": starlark.String(creator.FirstName),
+            "last":  starlark.String(creator.LastName),
+            "role":  starlark.String(creator.CreatorType),
+        })
+    }
+    attachments := make([]starlark.Value, len(item.Attachments))
+    for i, att := range item.Attachments {
+        attachments[i] = starlark.String(att.Path)
+    }
+    extra := starlark.NewDict(len(item.Extra))
+    for name, value := range item.Extra {
+        extra.SetKey(starlark.String(name), starlark.String(value))
+    }
+
+    fields := starlark.StringDict{
+        "key":         starlark.String(item.StableID),
+        "title":       starlark.String(item.Title),
+        "type":        starlark.String(item.ItemType),
+        "year":        starlark.MakeInt(year),
+        "publication": starlark.String(item.Publication),
+        "doi":         starlark.String(item.DOI),
+        "abstract":    starlark.String(item.Abstract.String),
+        "added":       starlark.String(item.DateAdded),
+        "citekey":     starlark.String(item.CitationKey),
+        "extra":       extra,
+        "authors":     starlark.NewList(authors),
+        "creators":    starlark.NewList(creators),
+        "tags":        stringList(item.displayTags()),
+        "collections": stringList(item.Collections),
+        "attachments": starlark.NewList(attachments),
+        "retracted":   starlark.Bool(item.Retraction != nil),
+    }
+    fields.Freeze()
+    return fields
+}
+
+// itemValue is the item passed to the functions of a -script file, a
+// struct of its fields
+func itemValue(fields starlark.StringDict) starlark.Value {
+    return starlarkstruct.FromStringDict(starlark.String("item"), fields)
+}
+
+// stringList converts strings to a Starlark list
+func stringList(values []string) *starlark.List {
+    elems := make([]starlark.Value, len(values))
+    for i, value := range values {
+        elems[i] = starlark.String(value)
+    }
+    return starlark.NewList(elems)
+}
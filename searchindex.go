@@ -0,0 +1,327 @@
+package main
+
+import (
+    "database/sql"
+    "errors"
+    "fmt"
+    "html"
+    "io/fs"
+    "log/slog"
+    "os"
+    "path/filepath"
+    "regexp"
+    "slices"
+    "strings"
+)
+
+// searchIndexName is the file of the full-text index kept next to
+// zotero.sqlite
+const searchIndexName = "zotero-fetch-search.sqlite"
+
+// errNoFTS5 reports a binary built without FTS5 support
+var errNoFTS5 = errors.New("full-text search is not available in this build, rebuild with -tags sqlite_fts5")
+
+const searchIndexSchema = `
+    CREATE VIRTUAL TABLE IF NOT EXISTS search USING fts5(
+        title, abstract, creators, tags, notes, fields,
+        tokenize = 'unicode61 remove_diacritics 2');
+    CREATE TABLE IF NOT EXISTS indexed (
+        itemID INTEGER PRIMARY KEY,
+        modified TEXT NOT NULL)`
+
+// indexItemsQuery lists every top-level item of all libraries with the
+// time it or one of its notes was last modified
+const indexItemsQuery = `
+    SELECT
+        i.itemID,
+        MAX(i.dateModified, COALESCE((
+            SELECT MAX(child.dateModified) FROM itemNotes n
+            JOIN items child ON n.itemID = child.itemID
+            WHERE n.parentItemID = i.itemID), ''))
+    FROM items i
+    JOIN itemTypes it ON i.itemTypeID = it.itemTypeID
+    WHERE it.display = 1
+        AND NOT EXISTS (
+            SELECT 1 FROM itemAttachments ia
+            WHERE ia.itemID = i.itemID AND ia.parentItemID IS NOT NULL)
+        AND NOT EXISTS (
+            SELECT 1 FROM itemNotes n
+            WHERE n.itemID = i.itemID AND n.parentItemID IS NOT NULL)`
+
+// htmlTagPattern matches the markup of note HTML
+var htmlTagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// noteText reduces note HTML to its plain text
+func noteText(s string) string {
+    s = htmlTagPattern.ReplaceAllString(s, " ")
+    return strings.Join(strings.Fields(html.UnescapeString(s)), " ")
+}
+
+// searchIndexPath returns the location of the full-text index
+func (r *Repository) searchIndexPath() string {
+    return filepath.Join(filepath.Dir(r.cfg.DBPath), searchIndexName)
+}
+
+// openSearchIndex opens the full-text index, creating it when create is
+// set. It returns nil when the index does not exist and is not created.
+// Under -dry-run an existing index is opened read-only.
+func (r *Repository) openSearchIndex(create bool) (*sql.DB, error) {
+    if !fts5Available {
+        return nil, errNoFTS5
+    }
+    path := r.searchIndexPath()
+    if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) && (!create || r.dryRun) {
+        return nil, nil
+    }
+    if r.dryRun {
+        db, err := sql.Open(driverName, readOnlyDSN(path))
+        if err != nil {
+            return nil, fmt.Errorf("opening search index: %w", err)
+        }
+        return db, nil
+    }
+
+    db, err := sql.Open(driverName, path)
+    if err != nil {
+        return nil, fmt.Errorf("opening search index: %w", err)
+    }
+    if _, err := db.Exec(searchIndexSchema); err != nil {
+        db.Close()
+        return nil, fmt.Errorf("creating search index: %w", err)
+    }
+    return db, nil
+}
+
+// indexDocument holds the searchable text of an item
+type indexDocument struct {
+    title, abstract, creators, tags, notes, fields string
+}
+
+// indexDocuments gathers the searchable text of the items with the given
+// IDs, with one batched query for each kind of text
+func (r *Repository) indexDocuments(itemIDs []int64) (map[int64]indexDocument, error) {
+    fields, err := r.ItemFields(itemIDs)
+    if err != nil {
+        return nil, err
+    }
+    creators, err := r.Creators(itemIDs)
+    if err != nil {
+        return nil, err
+    }
+    tags, err := r.Tags(itemIDs)
+    if err != nil {
+        return nil, err
+    }
+    notes, err := r.ChildNotes(itemIDs)
+    if err != nil {
+        return nil, err
+    }
+
+    docs := make(map[int64]indexDocument, len(itemIDs))
+    for _, itemID := range itemIDs {
+        var doc indexDocument
+        doc.title = fields[itemID]["title"]
+        doc.abstract = fields[itemID]["abstractNote"]
+        var other []string
+        for name, value := range fields[itemID] {
+            if name != "title" && name != "abstractNote" {
+                other = append(other, value)
+            }
+        }
+        doc.fields = strings.Join(other, "\n")
+
+        var names []string
+        for _, creator := range creators[itemID] {
+            names = append(names, strings.TrimSpace(creator.FirstName+" "+creator.LastName))
+        }
+        doc.creators = strings.Join(names, "\n")
+        doc.tags = strings.Join(tags[itemID], "\n")
+
+        var texts []string
+        for _, note := range notes[itemID] {
+            texts = append(texts, noteText(note.HTML))
+        }
+        doc.notes = strings.Join(texts, "\n")
+        docs[itemID] = doc
+    }
+    return docs, nil
+}
+
+// refreshSearchIndex brings the index up to date with the library,
+// re-indexing only items modified since they were last indexed and
+// dropping deleted ones. It returns the number of items updated and
+// removed.
+func (r *Repository) refreshSearchIndex(index *sql.DB) (int, int, error) {
+    rows, err := r.query(indexItemsQuery)
+    if err != nil {
+        return 0, 0, fmt.Errorf("executing query: %w", err)
+    }
+    current := make(map[int64]string)
+    for rows.Next() {
+        var itemID int64
+        var modified string
+        if err := rows.Scan(&itemID, &modified); err != nil {
+            rows.Close()
+            return 0, 0, fmt.Errorf("scanning row: %w", err)
+        }
+        current[itemID] = modified
+    }
+    rows.Close()
+    if err = rows.Err(); err != nil {
+        return 0, 0, fmt.Errorf("iterating rows: %w", err)
+    }
+
+    indexed := make(map[int64]string)
+    indexRows, err := index.Query(`SELECT itemID, modified FROM indexed`)
+    if err != nil {
+        return 0, 0, fmt.Errorf("reading search index: %w", err)
+    }
+    for indexRows.Next() {
+        var itemID int64
+        var modified string
+        if err := indexRows.Scan(&itemID, &modified); err != nil {
+            indexRows.Close()
+            return 0, 0, fmt.Errorf("scanning row: %w", err)
+        }
+        indexed[itemID] = modified
+    }
+    indexRows.Close()
+    if err = indexRows.Err(); err != nil {
+        return 0, 0, fmt.Errorf("iterating rows: %w", err)
+    }
+
+    tx, err := index.Begin()
+    if err != nil {
+        return 0, 0, fmt.Errorf("updating search index: %w", err)
+    }
+    defer tx.Rollback()
+
+    removed := 0
+    for itemID := range indexed {
+        if _, ok := current[itemID]; ok {
+            continue
+        }
+        if _, err := tx.Exec(`DELETE FROM search WHERE rowid = ?`, itemID); err != nil {
+            return 0, 0, fmt.Errorf("removing item from search index: %w", err)
+        }
+        if _, err := tx.Exec(`DELETE FROM indexed WHERE itemID = ?`, itemID); err != nil {
+            return 0, 0, fmt.Errorf("removing item from search index: %w", err)
+        }
+        removed++
+    }
+
+    var stale []int64
+    for itemID, modified := range current {
+        if last, ok := indexed[itemID]; !ok || last != modified {
+            stale = append(stale, itemID)
+        }
+    }
+    for batch := range slices.Chunk(stale, eachItemBatch) {
+        docs, err := r.indexDocuments(batch)
+        if err != nil {
+            return 0, 0, fmt.Errorf("reading items: %w", err)
+        }
+        for _, itemID := range batch {
+            doc := docs[itemID]
+            if _, err := tx.Exec(`DELETE FROM search WHERE rowid = ?`, itemID); err != nil {
+                return 0, 0, fmt.Errorf("indexing item: %w", err)
+            }
+            if _, err := tx.Exec(`
+                INSERT INTO search (rowid, title, abstract, creators, tags, notes, fields)
+                VALUES (?, ?, ?, ?, ?, ?, ?)`,
+                itemID, doc.title, doc.abstract, doc.creators, doc.tags, doc.notes, doc.fields,
+            ); err != nil {
+                return 0, 0, fmt.Errorf("indexing item: %w", err)
+            }
+            if _, err := tx.Exec(`INSERT OR REPLACE INTO indexed (itemID, modified) VALUES (?, ?)`,
+                itemID, current[itemID]); err != nil {
+                return 0, 0, fmt.Errorf("indexing item: %w", err)
+            }
+        }
+    }
+    updated := len(stale)
+
+    if err := tx.Commit(); err != nil {
+        return 0, 0, fmt.Errorf("updating search index: %w", err)
+    }
+    return updated, removed, nil
+}
+
+// matchExpression turns the words of a -query search into an FTS5 query
+// requiring every word as a prefix of some indexed word
+func matchExpression(query string) string {
+    var terms []string
+    for _, word := range strings.Fields(query) {
+        terms = append(terms, `"`+strings.ReplaceAll(word, `"`, `""`)+`"*`)
+    }
+    return strings.Join(terms, " AND ")
+}
+
+// searchIndexMatches returns the IDs of items matching query in the
+// full-text index, refreshing it first unless under -dry-run. It reports
+// false when there is no index to search, so that callers fall back to
+// scanning the library.
+func (r *Repository) searchIndexMatches(query string) ([]int64, bool, error) {
+    if !fts5Available {
+        return nil, false, nil
+    }
+    index, err := r.openSearchIndex(false)
+    if err != nil || index == nil {
+        return nil, false, err
+    }
+    defer index.Close()
+
+    if r.dryRun {
+        slog.Debug("searching the index as last built under -dry-run")
+    } else if _, _, err := r.refreshSearchIndex(index); err != nil {
+        slog.Warn("search index may be stale", "error", err)
+    }
+
+    rows, err := index.Query(`SELECT rowid FROM search WHERE search MATCH ?`, matchExpression(query))
+    if err != nil {
+        return nil, false, fmt.Errorf("searching index: %w", err)
+    }
+    defer rows.Close()
+
+    ids := []int64{}
+    for rows.Next() {
+        var itemID int64
+        if err := rows.Scan(&itemID); err != nil {
+            return nil, false, fmt.Errorf("scanning row: %w", err)
+        }
+        ids = append(ids, itemID)
+    }
+    if err = rows.Err(); err != nil {
+        return nil, false, fmt.Errorf("iterating rows: %w", err)
+    }
+    return ids, true, nil
+}
+
+// Index builds or refreshes the full-text index used by -query searches,
+// starting from scratch with rebuild
+func (c *CLI) Index(rebuild bool) error {
+    if !fts5Available {
+        return errNoFTS5
+    }
+    if c.pretend("index the library into %s", c.repo.searchIndexPath()) {
+        return nil
+    }
+    if rebuild {
+        if err := os.Remove(c.repo.searchIndexPath()); err != nil && !errors.Is(err, fs.ErrNotExist) {
+            return fmt.Errorf("removing search index: %w", err)
+        }
+    }
+
+    index, err := c.repo.openSearchIndex(true)
+    if err != nil {
+        return err
+    }
+    defer index.Close()
+
+    updated, removed, err := c.repo.refreshSearchIndex(index)
+    if err != nil {
+        return err
+    }
+    fmt.Printf("indexed %d items, removed %d: %s\n", updated, removed, c.repo.searchIndexPath())
+    return nil
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+    "cmp"
+    "database/sql"
+    "encoding/binary"
+    "errors"
+    "fmt"
+    "io/fs"
+    "log/slog"
+    "math"
+    "os"
+    "path/filepath"
+    "slices"
+    "strings"
+)
+
+// vectorIndexName is the file of the embeddings kept next to zotero.sqlite
+const vectorIndexName = "zotero-fetch-vectors.sqlite"
+
+const vectorIndexSchema = `
+    CREATE TABLE IF NOT EXISTS vectors (
+        itemID INTEGER PRIMARY KEY,
+        modified TEXT NOT NULL,
+        model TEXT NOT NULL,
+        vector BLOB NOT NULL)`
+
+// SemanticMatch is an item ranked by its similarity to a semsearch query
+type SemanticMatch struct {
+    Key   string  `json:"key"`
+    Title string  `json:"title"`
+    Score float64 `json:"score"`
+}
+
+// encodeVector packs a vector as little-endian float32s
+func encodeVector(v []float32) []byte {
+    b := make([]byte, 4*len(v))
+    for i, f := range v {
+        binary.LittleEndian.PutUint32(b[4*i:], math.Float32bits(f))
+    }
+    return b
+}
+
+// decodeVector unpacks a vector written by encodeVector
+func decodeVector(b []byte) []float32 {
+    v := make([]float32, len(b)/4)
+    for i := range v {
+        v[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:]))
+    }
+    return v
+}
+
+// cosine returns the cosine similarity of two vectors, 0 when their sizes
+// differ or either is zero
+func cosine(a, b []float32) float64 {
+    if len(a) != len(b) {
+        return 0
+    }
+    var dot, na, nb float64
+    for i := range a {
+        dot += float64(a[i]) * float64(b[i])
+        na += float64(a[i]) * float64(a[i])
+        nb += float64(b[i]) * float64(b[i])
+    }
+    if na == 0 || nb == 0 {
+        return 0
+    }
+    return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+// openVectorIndex opens the embeddings sidecar, creating it on first use.
+// Under -dry-run an existing sidecar is opened read-only and a missing one
+// gives nil.
+func (c *CLI) openVectorIndex() (*sql.DB, error) {
+    path := filepath.Join(filepath.Dir(c.cfg.DBPath), vectorIndexName)
+    if c.dryRun {
+        if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+            return nil, nil
+        }
+        db, err := sql.Open(driverName, readOnlyDSN(path))
+        if err != nil {
+            return nil, fmt.Errorf("opening vector index: %w", err)
+        }
+        return db, nil
+    }
+    db, err := sql.Open(driverName, path)
+    if err != nil {
+        return nil, fmt.Errorf("opening vector index: %w", err)
+    }
+    if _, err := db.Exec(vectorIndexSchema); err != nil {
+        db.Close()
+        return nil, fmt.Errorf("creating vector index: %w", err)
+    }
+    return db, nil
+}
+
+// refreshVectors embeds the titles and abstracts of items added or
+// modified since they were last embedded, or embedded with another model,
+// and drops items that were deleted
+func (c *CLI) refreshVectors(index *sql.DB) error {
+    rows, err := c.repo.query(indexItemsQuery)
+    if err != nil {
+        return fmt.Errorf("executing query: %w", err)
+    }
+    current := make(map[int64]string)
+    for rows.Next() {
+        var itemID int64
+        var modified string
+        if err := rows.Scan(&itemID, &modified); err != nil {
+            rows.Close()
+            return fmt.Errorf("scanning row: %w", err)
+        }
+        current[itemID] = modified
+    }
+    rows.Close()
+    if err = rows.Err(); err != nil {
+        return fmt.Errorf("iterating rows: %w", err)
+    }
+
+    stored := make(map[int64]string)
+    indexRows, err := index.Query(`SELECT itemID, modified || ' ' || model FROM vectors`)
+    if err != nil {
+        return fmt.Errorf("reading vector index: %w", err)
+    }
+    for indexRows.Next() {
+        var itemID int64
+        var version string
+        if err := indexRows.Scan(&itemID, &version); err != nil {
+            indexRows.Close()
+            return fmt.Errorf("scanning row: %w", err)
+        }
+        stored[itemID] = version
+    }
+    indexRows.Close()
+    if err = indexRows.Err(); err != nil {
+        return fmt.Errorf("iterating rows: %w", err)
+    }
+
+    for itemID := range stored {
+        if _, ok := current[itemID]; !ok {
+            if _, err := index.Exec(`DELETE FROM vectors WHERE itemID = ?`, itemID); err != nil {
+                return fmt.Errorf("removing item from vector index: %w", err)
+            }
+        }
+    }
+
+    var stale []int64
+    for itemID, modified := range current {
+        if stored[itemID] != modified+" "+c.cfg.Embeddings.Model {
+            stale = append(stale, itemID)
+        }
+    }
+    slices.Sort(stale)
+
+    for start := 0; start < len(stale); start += embeddingBatch {
+        batch := stale[start:min(start+embeddingBatch, len(stale))]
+        texts := make([]string, len(batch))
+        for i, itemID := range batch {
+            fields, err := c.repo.Fields(itemID)
+            if err != nil {
+                return fmt.Errorf("reading item %d: %w", itemID, err)
+            }
+            texts[i] = strings.TrimSpace(fields["title"] + "\n\n" + fields["abstractNote"])
+        }
+        vectors, err := c.embed(texts)
+        if err != nil {
+            return err
+        }
+
+        // each batch is committed on its own so that an interrupted
+        // first run over a large library keeps its progress
+        tx, err := index.Begin()
+        if err != nil {
+            return fmt.Errorf("updating vector index: %w", err)
+        }
+        for i, itemID := range batch {
+            if _, err := tx.Exec(`INSERT OR REPLACE INTO vectors (itemID, modified, model, vector) VALUES (?, ?, ?, ?)`,
+                itemID, current[itemID], c.cfg.Embeddings.Model, encodeVector(vectors[i])); err != nil {
+                tx.Rollback()
+                return fmt.Errorf("updating vector index: %w", err)
+            }
+        }
+        if err := tx.Commit(); err != nil {
+            return fmt.Errorf("updating vector index: %w", err)
+        }
+        slog.Info("embedded items", "done", start+len(batch), "total", len(stale))
+    }
+    return nil
+}
+
+// SemanticSearch ranks the items matching filter by how close their
+// title and abstract are in meaning to query, returning the best limit
+func (c *CLI) SemanticSearch(query string, filter Filter, limit int) ([]SemanticMatch, error) {
+    index, err := c.openVectorIndex()
+    if err != nil {
+        return nil, err
+    }
+    if index == nil {
+        slog.Warn("no embeddings yet, run semsearch without -dry-run to compute them")
+        return nil, nil
+    }
+    defer index.Close()
+
+    // under -dry-run items changed since the last run keep their old
+    // embeddings, and new ones are left out
+    if !c.dryRun {
+        if err := c.refreshVectors(index); err != nil {
+            return nil, err
+        }
+    }
+    vectors, err := c.embed([]string{query})
+    if err != nil {
+        return nil, err
+    }
+    target := vectors[0]
+
+    items, err := c.repo.ListItems(filter)
+    if err != nil {
+        return nil, fmt.Errorf("listing items: %w", err)
+    }
+    byID := make(map[int64]*Item, len(items))
+    for _, item := range items {
+        byID[item.ID] = item
+    }
+
+    rows, err := index.Query(`SELECT itemID, vector FROM vectors WHERE model = ?`, c.cfg.Embeddings.Model)
+    if err != nil {
+        return nil, fmt.Errorf("reading vector index: %w", err)
+    }
+    defer rows.Close()
+
+    var matches []SemanticMatch
+    for rows.Next() {
+        var itemID int64
+        var blob []byte
+        if err := rows.Scan(&itemID, &blob); err != nil {
+            return nil, fmt.Errorf("scanning row: %w", err)
+        }
+        item, ok := byID[itemID]
+        if !ok {
+            continue
+        }
+        matches = append(matches, SemanticMatch{
+            Key:   item.StableID,
+            Title: item.Title,
+            Score: cosine(target, decodeVector(blob)),
+        })
+    }
+    if err = rows.Err(); err != nil {
+        return nil, fmt.Errorf("iterating rows: %w", err)
+    }
+
+    slices.SortFunc(matches, func(a, b SemanticMatch) int {
+        return cmp.Or(cmp.Compare(b.Score, a.Score), strings.Compare(a.Key, b.Key))
+    })
+    if limit > 0 && len(matches) > limit {
+        matches = matches[:limit]
+    }
+    return matches, nil
+}
+
+// PrintSemanticSearch prints the items closest to query as text or JSON
+func (c *CLI) PrintSemanticSearch(query string, filter Filter, limit int, format string) error {
+    matches, err := c.SemanticSearch(query, filter, limit)
+    if err != nil {
+        return err
+    }
+    switch format {
+    case "", "text":
+        for _, match := range matches {
+            fmt.Printf("%.3f\t%s\t%s\n", match.Score, match.Key, match.Title)
+        }
+        return nil
+    case "json":
+        if matches == nil {
+            matches = []SemanticMatch{}
+        }
+        return writeJSON(matches)
+    default:
+        return fmt.Errorf("unknown format: %s", format)
+    }
+}
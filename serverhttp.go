@@ -0,0 +1,154 @@
+package main
+
+import (
+    "crypto/subtle"
+    "errors"
+    "fmt"
+    "io"
+    "log/slog"
+    "net"
+    "net/http"
+    "net/rpc"
+    "net/rpc/jsonrpc"
+    "os"
+    "os/signal"
+    "strings"
+    "syscall"
+)
+
+// ServerConfig secures serve when it listens on the network. Token is
+// expected as "Authorization: Bearer <token>"; TLSCert and TLSKey are the
+// PEM files of the certificate served over HTTPS. URL is the address
+// others reach the server at, which short links are minted with;
+// PublicLinks lets anyone open them.
+type ServerConfig struct {
+    Token       string `json:"token"`
+    TLSCert     string `json:"tlsCert"`
+    TLSKey      string `json:"tlsKey"`
+    URL         string `json:"url"`
+    PublicLinks bool   `json:"publicLinks"`
+}
+
+// httpConn joins a request body and its response into the connection a
+// JSON-RPC codec expects
+type httpConn struct {
+    io.Reader
+    io.Writer
+}
+
+// Close implements io.Closer; the HTTP server owns the connection
+func (httpConn) Close() error {
+    return nil
+}
+
+// isLoopback reports whether the host of addr only accepts connections
+// from this machine
+func isLoopback(addr string) bool {
+    host, _, err := net.SplitHostPort(addr)
+    if err != nil {
+        return false
+    }
+    if host == "localhost" {
+        return true
+    }
+    ip := net.ParseIP(host)
+    return ip != nil && ip.IsLoopback()
+}
+
+// hasToken reports whether a request carries the token, as a bearer
+// token or as the password of basic auth, which is what e-reader apps
+// offer. Every request has it when no token is configured.
+func hasToken(r *http.Request, token string) bool {
+    if token == "" {
+        return true
+    }
+    given, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+    if _, password, ok := r.BasicAuth(); ok {
+        given = password
+    }
+    return subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+}
+
+// authorized passes on requests carrying the token, and with public those
+// for the pages of short links
+func authorized(token string, public bool, next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        isLink := strings.HasPrefix(r.URL.Path, shortLinkPrefix) || strings.HasPrefix(r.URL.Path, itemPagePrefix)
+        if !hasToken(r, token) && !(public && isLink) {
+            slog.Warn("rejected request", "remote", r.RemoteAddr)
+            w.Header().Add("WWW-Authenticate", "Bearer")
+            w.Header().Add("WWW-Authenticate", `Basic realm="zotero-fetch"`)
+            http.Error(w, "invalid or missing token", http.StatusUnauthorized)
+            return
+        }
+        next.ServeHTTP(w, r)
+    })
+}
+
+// serveError answers with 404 for missing items and pages and logs other
+// failures behind a bare 500
+func serveError(w http.ResponseWriter, r *http.Request, err error) {
+    if errors.Is(err, errNotFound) {
+        http.Error(w, err.Error(), http.StatusNotFound)
+        return
+    }
+    slog.Warn("serving request", "path", r.URL.Path, "error", err)
+    http.Error(w, "internal error", http.StatusInternalServerError)
+}
+
+// rpcHandler answers JSON-RPC requests POSTed to it, one per request
+func rpcHandler(server *rpc.Server) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if r.Method != http.MethodPost {
+            w.Header().Set("Allow", http.MethodPost)
+            http.Error(w, "JSON-RPC requests must be POSTed", http.StatusMethodNotAllowed)
+            return
+        }
+        w.Header().Set("Content-Type", "application/json")
+        if err := server.ServeRequest(jsonrpc.NewServerCodec(httpConn{r.Body, w})); err != nil {
+            slog.Debug("serving request", "remote", r.RemoteAddr, "error", err)
+        }
+    })
+}
+
+// ServeHTTP answers JSON-RPC requests POSTed to addr and serves the OPDS
+// catalog below /opds/ and the short links of items until interrupted,
+// over HTTPS when a certificate is configured. Listening beyond this
+// machine requires a token.
+func (c *CLI) ServeHTTP(addr string) error {
+    cfg := c.cfg.Server
+    if cfg.Token == "" && !isLoopback(addr) {
+        return fmt.Errorf("%w: server.token must be set to listen on %s", errConfig, addr)
+    }
+    if (cfg.TLSCert == "") != (cfg.TLSKey == "") {
+        return fmt.Errorf("%w: server.tlsCert and server.tlsKey must be set together", errConfig)
+    }
+    server, err := c.rpcServer()
+    if err != nil {
+        return err
+    }
+
+    mux := http.NewServeMux()
+    mux.Handle("/", rpcHandler(server))
+    mux.Handle(opdsPrefix, c.opdsHandler())
+    mux.Handle(shortLinkPrefix, c.shortLinkHandler(cfg.Token))
+    mux.Handle(itemPagePrefix, c.itemPageHandler())
+    srv := &http.Server{Addr: addr, Handler: authorized(cfg.Token, cfg.PublicLinks, mux)}
+    signals := make(chan os.Signal, 1)
+    signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+    go func() {
+        <-signals
+        srv.Close()
+    }()
+
+    slog.Info("serving", "addr", addr, "tls", cfg.TLSCert != "", "auth", cfg.Token != "")
+    if cfg.TLSCert != "" {
+        err = srv.ListenAndServeTLS(cfg.TLSCert, cfg.TLSKey)
+    } else {
+        err = srv.ListenAndServe()
+    }
+    if errors.Is(err, http.ErrServerClosed) {
+        return nil
+    }
+    return fmt.Errorf("serving on %s: %w", addr, err)
+}
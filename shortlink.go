@@ -0,0 +1,101 @@
+package main
+
+import (
+    "fmt"
+    "html/template"
+    "log/slog"
+    "net/http"
+    "os/exec"
+    "strings"
+)
+
+// Paths of the short links serve -bind answers and of the item pages they
+// fall back to
+const (
+    shortLinkPrefix = "/i/"
+    itemPagePrefix  = "/items/"
+)
+
+// itemKeyFrom returns the item key following prefix in a request path
+func itemKeyFrom(r *http.Request, prefix string) string {
+    return strings.Trim(strings.TrimPrefix(r.URL.Path, prefix), "/")
+}
+
+// itemFile returns the catalog path of the item's PDF, or else its EPUB,
+// empty when it has neither on disk
+func itemFile(item *Item) string {
+    for _, kind := range opdsKinds {
+        if att := attachmentOfKind(item, kind); att != nil && att.Exists() {
+            return opdsFileURL(item, att)
+        }
+    }
+    return ""
+}
+
+// shortLinkHandler redirects /i/<key> to the item's file, or to its page
+// when it has none or the request lacks the token the file needs
+func (c *CLI) shortLinkHandler(token string) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        key := itemKeyFrom(r, shortLinkPrefix)
+        item, err := c.repo.GetByStableID(key)
+        if err != nil {
+            serveError(w, r, err)
+            return
+        }
+        target := itemPagePrefix + item.StableID
+        if file := itemFile(item); file != "" && hasToken(r, token) {
+            target = file
+        }
+        http.Redirect(w, r, target, http.StatusFound)
+    })
+}
+
+// itemPageHandler renders the metadata page of /items/<key>, like the
+// item pages of site, linking the files to the catalog
+func (c *CLI) itemPageHandler() http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        item, err := c.repo.GetByStableID(itemKeyFrom(r, itemPagePrefix))
+        if err != nil {
+            serveError(w, r, err)
+            return
+        }
+        page, err := c.siteItemOf(item, "", "none")
+        if err != nil {
+            serveError(w, r, err)
+            return
+        }
+        page.Served = true
+        for _, kind := range opdsKinds {
+            if att := attachmentOfKind(item, kind); att != nil && att.Exists() {
+                page.Attachments = append(page.Attachments, siteLink{Name: att.Title, URL: template.URL(opdsFileURL(item, att))})
+            }
+        }
+        w.Header().Set("Content-Type", "text/html; charset=utf-8")
+        if err := siteTemplates.ExecuteTemplate(w, "item", page); err != nil {
+            slog.Debug("writing page", "path", r.URL.Path, "error", err)
+        }
+    })
+}
+
+// Link prints the short link of an item on the server at server.url. With
+// qr a QR code of it is also written there as PNG, by qrencode.
+func (c *CLI) Link(stableID, qr string) error {
+    base := strings.TrimSuffix(c.cfg.Server.URL, "/")
+    if base == "" {
+        return fmt.Errorf("%w: server.url must be set to mint short links", errConfig)
+    }
+    item, err := c.repo.GetByStableID(stableID)
+    if err != nil {
+        return fmt.Errorf("getting item: %w", err)
+    }
+    link := base + shortLinkPrefix + item.StableID
+    fmt.Println(link)
+
+    if qr == "" || c.pretend("write a QR code of %s to %s", link, qr) {
+        return nil
+    }
+    if out, err := exec.Command("qrencode", "-o", qr, link).CombinedOutput(); err != nil {
+        return fmt.Errorf("running qrencode: %w: %s", err, out)
+    }
+    return nil
+}
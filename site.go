@@ -0,0 +1,313 @@
+package main
+
+import (
+    "fmt"
+    "hash/fnv"
+    "html/template"
+    "os"
+    "path/filepath"
+    "slices"
+    "strings"
+    "unicode"
+)
+
+// siteAttachmentModes are the ways site links attachments: to the file
+// where it is stored, to a copy inside the site, or not at all
+var siteAttachmentModes = []string{"link", "copy", "none"}
+
+// siteTemplates renders the pages of the static site. Every page lives at
+// the top of the output folder so that links work however it is served.
+var siteTemplates = template.Must(template.New("site").Parse(`
+{{define "head"}}<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.}}</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 50em; margin: 2em auto; padding: 0 1em; line-height: 1.5; }
+nav a { margin-right: 1em; }
+ul.items li { margin: .4em 0; }
+.meta { color: #666; font-size: .9em; }
+.tag { display: inline-block; background: #eee; border-radius: 3px; padding: 0 .4em; margin: 0 .2em .2em 0; }
+input[type=search] { width: 100%; font-size: 1.1em; padding: .3em; box-sizing: border-box; }
+</style>
+</head>
+<body>
+{{end}}
+
+{{define "nav"}}<nav><a href="index.html">Items</a><a href="tags.html">Tags</a><a href="collections.html">Collections</a></nav>
+{{end}}
+
+{{define "foot"}}</body>
+</html>
+{{end}}
+
+{{define "list"}}<ul class="items">
+{{range .}}<li data-search="{{.Search}}"><a href="{{.Page}}">{{.Title}}</a>
+<div class="meta">{{.Authors}}{{if .Year}} ({{.Year}}){{end}}{{if .Publication}}, {{.Publication}}{{end}}</div></li>
+{{end}}</ul>
+{{end}}
+
+{{define "index"}}{{template "head" .Title}}{{template "nav"}}
+<h1>{{.Title}}</h1>
+<input type="search" id="search" placeholder="Search titles, authors, tags and years" autofocus>
+<p class="meta" id="count">{{len .Items}} items</p>
+{{template "list" .Items}}
+<script>
+const items = document.querySelectorAll("li[data-search]");
+const count = document.getElementById("count");
+document.getElementById("search").addEventListener("input", e => {
+    const words = e.target.value.toLowerCase().split(/\s+/).filter(w => w);
+    let shown = 0;
+    for (const li of items) {
+        const match = words.every(w => li.dataset.search.includes(w));
+        li.hidden = !match;
+        if (match) shown++;
+    }
+    count.textContent = shown + " items";
+});
+</script>
+{{template "foot"}}{{end}}
+
+{{define "item"}}{{template "head" .Title}}{{if not .Served}}{{template "nav"}}{{end}}
+<h1>{{.Title}}</h1>
+<p class="meta">{{.Authors}}{{if .Year}} ({{.Year}}){{end}}{{if .Publication}}, {{.Publication}}{{end}}</p>
+<dl>
+<dt>Key</dt><dd>{{.Key}}</dd>
+{{if .CitationKey}}<dt>Citation key</dt><dd>{{.CitationKey}}</dd>{{end}}
+{{if .DOI}}<dt>DOI</dt><dd><a href="https://doi.org/{{.DOI}}">{{.DOI}}</a></dd>{{end}}
+{{if .Tags}}<dt>Tags</dt><dd>{{range .Tags}}{{if $.Served}}<span class="tag">{{.Name}}</span>{{else}}<a class="tag" href="{{.Page}}">{{.Name}}</a>{{end}}{{end}}</dd>{{end}}
+{{if .Collections}}<dt>Collections</dt><dd>{{range .Collections}}{{if $.Served}}{{.Name}}{{else}}<a href="{{.Page}}">{{.Name}}</a>{{end}}<br>{{end}}</dd>{{end}}
+{{if .Attachments}}<dt>Attachments</dt><dd>{{range .Attachments}}{{if .URL}}<a href="{{.URL}}">{{.Name}}</a>{{else}}{{.Name}}{{end}}<br>{{end}}</dd>{{end}}
+</dl>
+{{if .Abstract}}<h2>Abstract</h2>
+<p>{{.Abstract}}</p>{{end}}
+{{template "foot"}}{{end}}
+
+{{define "group"}}{{template "head" .Title}}{{template "nav"}}
+<h1>{{.Title}}</h1>
+{{template "list" .Items}}
+{{template "foot"}}{{end}}
+
+{{define "groups"}}{{template "head" .Title}}{{template "nav"}}
+<h1>{{.Title}}</h1>
+<ul>
+{{range .Groups}}<li><a href="{{.Page}}">{{.Name}}</a> <span class="meta">{{len .Items}}</span></li>
+{{end}}</ul>
+{{template "foot"}}{{end}}
+`))
+
+// siteLink is a named link to a page of the site or to a file
+type siteLink struct {
+    Name string
+    Page string
+    URL  template.URL
+}
+
+// siteItem is an item as rendered on the site
+type siteItem struct {
+    Key         string
+    Title       string
+    Page        string
+    Authors     string
+    Year        string
+    Publication string
+    DOI         string
+    CitationKey string
+    Abstract    string
+    Search      string
+    Tags        []siteLink
+    Collections []siteLink
+    Attachments []siteLink
+    // Served marks a page serve renders on its own, outside of a site, so
+    // without links to the other pages
+    Served      bool
+}
+
+// siteGroup is a tag or collection page listing its items
+type siteGroup struct {
+    Name  string
+    Page  string
+    Items []*siteItem
+}
+
+// siteSlug turns a tag or collection name into a file name that is safe
+// everywhere; the hash keeps names differing only in punctuation apart
+func siteSlug(prefix, name string) string {
+    var b strings.Builder
+    for _, r := range strings.ToLower(fold(name)) {
+        switch {
+        case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+            b.WriteRune(r)
+        case b.Len() > 0 && !strings.HasSuffix(b.String(), "-"):
+            b.WriteRune('-')
+        }
+    }
+    h := fnv.New32a()
+    h.Write([]byte(name))
+    slug := strings.Trim(b.String(), "-")
+    if len(slug) > 40 {
+        slug = slug[:40]
+    }
+    if slug == "" {
+        return fmt.Sprintf("%s-%08x.html", prefix, h.Sum32())
+    }
+    return fmt.Sprintf("%s-%s-%08x.html", prefix, slug, h.Sum32())
+}
+
+// siteGroups files items under each of the links names returns for them,
+// ordered by name
+func siteGroups(items []*siteItem, names func(*siteItem) []siteLink) []*siteGroup {
+    byName := make(map[string]*siteGroup)
+    for _, item := range items {
+        for _, link := range names(item) {
+            group, ok := byName[link.Name]
+            if !ok {
+                group = &siteGroup{Name: link.Name, Page: link.Page}
+                byName[link.Name] = group
+            }
+            group.Items = append(group.Items, item)
+        }
+    }
+    var groups []*siteGroup
+    for _, name := range sortedKeys(byName) {
+        groups = append(groups, byName[name])
+    }
+    return groups
+}
+
+// writePage renders one template of the site into a file of dir
+func writePage(dir, name, tmpl string, data interface{}) error {
+    f, err := os.Create(filepath.Join(dir, name))
+    if err != nil {
+        return fmt.Errorf("creating %s: %w", name, err)
+    }
+    if err := siteTemplates.ExecuteTemplate(f, tmpl, data); err != nil {
+        f.Close()
+        return fmt.Errorf("rendering %s: %w", name, err)
+    }
+    if err := f.Close(); err != nil {
+        return fmt.Errorf("writing %s: %w", name, err)
+    }
+    return nil
+}
+
+// siteItemOf gathers what the site shows of item. In copy mode the files
+// are copied below dir/files.
+func (c *CLI) siteItemOf(item *Item, dir, attachments string) (*siteItem, error) {
+    var names []string
+    for _, creator := range item.Creators {
+        names = append(names, strings.TrimSpace(creator.FirstName+" "+creator.LastName))
+    }
+
+    page := &siteItem{
+        Key:         item.StableID,
+        Title:       item.Title,
+        Page:        "item-" + item.StableID + ".html",
+        Authors:     strings.Join(names, ", "),
+        Year:        item.Year,
+        Publication: item.Publication,
+        DOI:         item.DOI,
+        CitationKey: item.CitationKey,
+        Abstract:    item.Abstract.String,
+    }
+    for _, tag := range item.displayTags() {
+        page.Tags = append(page.Tags, siteLink{Name: tag, Page: siteSlug("tag", tag)})
+    }
+    for _, path := range item.Collections {
+        page.Collections = append(page.Collections, siteLink{Name: path, Page: siteSlug("collection", path)})
+    }
+    for _, att := range item.Attachments {
+        if att.Path == "" || attachments == "none" {
+            continue
+        }
+        link := siteLink{Name: att.Title}
+        switch {
+        case attachments == "copy" && att.Exists():
+            rel := filepath.Join("files", att.StableID, filepath.Base(att.Path))
+            if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(rel)), 0755); err != nil {
+                return nil, fmt.Errorf("creating attachment folder: %w", err)
+            }
+            if err := copyFile(att.Path, filepath.Join(dir, rel)); err != nil {
+                return nil, fmt.Errorf("copying %s: %w", att.StableID, err)
+            }
+            link.URL = template.URL(filepath.ToSlash(rel))
+        case attachments == "link":
+            link.URL = template.URL("file://" + filepath.ToSlash(att.Path))
+        }
+        page.Attachments = append(page.Attachments, link)
+    }
+
+    search := []string{item.Title, page.Authors, item.Year, item.Publication}
+    search = append(search, item.displayTags()...)
+    page.Search = strings.ToLower(fold(strings.Join(search, " ")))
+    return page, nil
+}
+
+// Site writes a static HTML site of the items matching filter to dir: an
+// index searchable in the browser, a page per item and pages per tag and
+// collection. attachments is one of siteAttachmentModes.
+func (c *CLI) Site(dir string, filter Filter, attachments string) error {
+    if !slices.Contains(siteAttachmentModes, attachments) {
+        return fmt.Errorf("unknown attachment mode %q, expected link, copy or none", attachments)
+    }
+    items, err := c.repo.ListItems(filter)
+    if err != nil {
+        return fmt.Errorf("listing items: %w", err)
+    }
+    if c.pretend("write a site of %d items to %s", len(items), dir) {
+        return nil
+    }
+    if err := os.MkdirAll(dir, 0755); err != nil {
+        return fmt.Errorf("creating %s: %w", dir, err)
+    }
+
+    var pages []*siteItem
+    for _, item := range items {
+        page, err := c.siteItemOf(item, dir, attachments)
+        if err != nil {
+            return err
+        }
+        if err := writePage(dir, page.Page, "item", page); err != nil {
+            return err
+        }
+        pages = append(pages, page)
+    }
+
+    indexes := []struct {
+        name, title string
+        groups      []*siteGroup
+    }{
+        {"tags.html", "Tags", siteGroups(pages, func(item *siteItem) []siteLink { return item.Tags })},
+        {"collections.html", "Collections", siteGroups(pages, func(item *siteItem) []siteLink { return item.Collections })},
+    }
+    for _, index := range indexes {
+        for _, group := range index.groups {
+            data := struct {
+                Title string
+                Items []*siteItem
+            }{group.Name, group.Items}
+            if err := writePage(dir, group.Page, "group", data); err != nil {
+                return err
+            }
+        }
+        data := struct {
+            Title  string
+            Groups []*siteGroup
+        }{index.title, index.groups}
+        if err := writePage(dir, index.name, "groups", data); err != nil {
+            return err
+        }
+    }
+
+    data := struct {
+        Title string
+        Items []*siteItem
+    }{"Library", pages}
+    if err := writePage(dir, "index.html", "index", data); err != nil {
+        return err
+    }
+    fmt.Printf("%d items written to %s\n", len(pages), dir)
+    return nil
+}
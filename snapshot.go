@@ -0,0 +1,54 @@
+package main
+
+import (
+    "database/sql"
+    "errors"
+    "fmt"
+    "io/fs"
+    "os"
+    "path/filepath"
+)
+
+// walSuffixes name the files SQLite keeps next to a database in WAL mode:
+// the log of changes not yet written back and its shared-memory index
+var walSuffixes = []string{"-wal", "-shm"}
+
+// snapshotDatabase copies the database at path into a temporary folder
+// so that it can be read while Zotero keeps it locked. The WAL files are
+// copied along and checkpointed into the copy, otherwise items Zotero
+// added since its last checkpoint would be missing. It returns the path of
+// the copy and a function removing it.
+func snapshotDatabase(path string) (string, func(), error) {
+    dir, err := os.MkdirTemp("", "zotero-fetch-snapshot-")
+    if err != nil {
+        return "", nil, fmt.Errorf("creating snapshot folder: %w", err)
+    }
+    cleanup := func() { os.RemoveAll(dir) }
+
+    target := filepath.Join(dir, filepath.Base(path))
+    if err := copyFile(path, target); err != nil {
+        cleanup()
+        return "", nil, fmt.Errorf("copying database: %w", err)
+    }
+    // the log is copied after the database so that it holds at least
+    // every change the copied database lacks
+    for _, suffix := range walSuffixes {
+        err := copyFile(path+suffix, target+suffix)
+        if err != nil && !errors.Is(err, fs.ErrNotExist) {
+            cleanup()
+            return "", nil, fmt.Errorf("copying %s: %w", filepath.Base(path+suffix), err)
+        }
+    }
+
+    db, err := sql.Open(driverName, target)
+    if err != nil {
+        cleanup()
+        return "", nil, fmt.Errorf("opening snapshot: %w", err)
+    }
+    defer db.Close()
+    if _, err := db.Exec("PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+        cleanup()
+        return "", nil, fmt.Errorf("checkpointing snapshot: %w", err)
+    }
+    return target, cleanup, nil
+}
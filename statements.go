@@ -0,0 +1,97 @@
+package main
+
+import (
+    "database/sql"
+    "errors"
+    "fmt"
+    "sync"
+)
+
+// maxStatements bounds the statement cache; filters produce a new query
+// text for every combination of conditions, so a long-running daemon
+// would otherwise accumulate them
+const maxStatements = 128
+
+// statements caches prepared statements by their SQL so that repeated
+// queries, such as the batched child lookups, are parsed only once
+type statements struct {
+    mu      sync.Mutex
+    byQuery map[string]*cachedStatement
+    // uses counts the statements handed out, ordering them by last use
+    uses uint64
+}
+
+// cachedStatement is a prepared statement of the cache
+type cachedStatement struct {
+    stmt *sql.Stmt
+    // lastUse is the value of uses when the statement was last handed out
+    lastUse uint64
+    // users counts the queries about to run the statement, which keep it
+    // from being evicted
+    users int
+}
+
+// prepare returns the cached statement for query, preparing it on first
+// use. The caller runs release once it has started the query; rows
+// already returned stay valid when the statement is evicted later.
+func (r *Repository) prepare(query string) (stmt *sql.Stmt, release func(), err error) {
+    r.stmts.mu.Lock()
+    defer r.stmts.mu.Unlock()
+
+    cached, ok := r.stmts.byQuery[query]
+    if !ok {
+        if r.stmts.byQuery == nil {
+            r.stmts.byQuery = make(map[string]*cachedStatement)
+        }
+        r.stmts.evict()
+        stmt, err := r.db.Prepare(query)
+        if err != nil {
+            return nil, nil, fmt.Errorf("preparing statement: %w", err)
+        }
+        cached = &cachedStatement{stmt: stmt}
+        r.stmts.byQuery[query] = cached
+    }
+    r.stmts.uses++
+    cached.lastUse = r.stmts.uses
+    cached.users++
+    release = func() {
+        r.stmts.mu.Lock()
+        defer r.stmts.mu.Unlock()
+        cached.users--
+    }
+    return cached.stmt, release, nil
+}
+
+// evict closes the least recently used statements no query is about to
+// run until there is room for another. When every statement is in use
+// the cache grows past maxStatements for the time being. The caller holds
+// mu.
+func (s *statements) evict() {
+    for len(s.byQuery) >= maxStatements {
+        var oldest string
+        var found *cachedStatement
+        for query, cached := range s.byQuery {
+            if cached.users == 0 && (found == nil || cached.lastUse < found.lastUse) {
+                oldest, found = query, cached
+            }
+        }
+        if found == nil {
+            return
+        }
+        found.stmt.Close()
+        delete(s.byQuery, oldest)
+    }
+}
+
+// Close releases the prepared statements of the repository
+func (r *Repository) Close() error {
+    r.stmts.mu.Lock()
+    defer r.stmts.mu.Unlock()
+
+    var errs []error
+    for _, cached := range r.stmts.byQuery {
+        errs = append(errs, cached.stmt.Close())
+    }
+    r.stmts.byQuery = nil
+    return errors.Join(errs...)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+    "cmp"
+    "fmt"
+    "slices"
+    "strings"
+)
+
+// readingStatuses are the steps of the reading workflow, in order
+var readingStatuses = []string{"toread", "reading", "read"}
+
+// readingStatus is a reading status given on the command line
+type readingStatus string
+
+// String implements flag.Value
+func (s *readingStatus) String() string {
+    return string(*s)
+}
+
+// Set implements flag.Value
+func (s *readingStatus) Set(value string) error {
+    if !slices.Contains(readingStatuses, value) {
+        return fmt.Errorf("expected %s", strings.Join(readingStatuses, ", "))
+    }
+    *s = readingStatus(value)
+    return nil
+}
+
+// statusTag returns the tag marking items with the given reading status
+func (cfg Config) statusTag(status string) (string, error) {
+    if !slices.Contains(readingStatuses, status) {
+        return "", fmt.Errorf("unknown status %q, expected %s", status, strings.Join(readingStatuses, ", "))
+    }
+    if tag := cfg.StatusTags[status]; tag != "" {
+        return tag, nil
+    }
+    return status, nil
+}
+
+// itemStatus returns the reading status of item, empty when it carries
+// none of the status tags
+func (cfg Config) itemStatus(item *Item) string {
+    for _, status := range readingStatuses {
+        tag, _ := cfg.statusTag(status)
+        for _, t := range item.Tags {
+            if fold(t) == fold(tag) {
+                return status
+            }
+        }
+    }
+    return ""
+}
+
+// Status prints the reading status of an item
+func (c *CLI) Status(stableID string) error {
+    item, err := c.repo.GetByStableID(stableID)
+    if err != nil {
+        return fmt.Errorf("getting item: %w", err)
+    }
+    fmt.Println(cmp.Or(c.cfg.itemStatus(item), "none"))
+    return nil
+}
+
+// SetStatus moves an item to the given reading status by adding its tag
+// and removing those of the other statuses, through the Zotero client or
+// with remote through the Web API
+func (c *CLI) SetStatus(stableID, status string, remote bool) error {
+    tag, err := c.cfg.statusTag(status)
+    if err != nil {
+        return err
+    }
+    var others []string
+    for _, other := range readingStatuses {
+        if other != status {
+            otherTag, _ := c.cfg.statusTag(other)
+            others = append(others, otherTag)
+        }
+    }
+    if c.pretend("set the status of %s to %s", stableID, status) {
+        return nil
+    }
+
+    var result []string
+    if remote {
+        result, err = c.remoteEditTags(stableID, []string{tag}, others)
+    } else {
+        result, err = c.editTags(stableID, []string{tag}, others)
+    }
+    if err != nil {
+        return err
+    }
+    fmt.Println(strings.Join(result, ", "))
+    return nil
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+    "cmp"
+    "fmt"
+    "math"
+    "slices"
+    "strings"
+    "unicode"
+)
+
+// suggestStopWords are words of titles and abstracts too common to
+// suggest a tag
+var suggestStopWords = map[string]bool{
+    "the": true, "and": true, "for": true, "with": true, "from": true, "that": true,
+    "this": true, "these": true, "those": true, "are": true, "was": true, "were": true,
+    "been": true, "being": true, "have": true, "has": true, "had": true, "not": true,
+    "but": true, "can": true, "could": true, "will": true, "would": true, "should": true,
+    "may": true, "might": true, "our": true, "their": true, "its": true, "which": true,
+    "what": true, "when": true, "where": true, "who": true, "how": true, "why": true,
+    "into": true, "onto": true, "over": true, "under": true, "than": true, "then": true,
+    "such": true, "also": true, "more": true, "most": true, "both": true, "each": true,
+    "other": true, "between": true, "using": true, "used": true, "use": true, "based": true,
+    "via": true, "new": true, "two": true, "one": true, "all": true, "any": true,
+    "you": true, "your": true, "we": true, "they": true, "them": true, "there": true,
+    "here": true, "about": true, "paper": true, "study": true, "results": true, "show": true,
+    "approach": true, "method": true, "methods": true, "propose": true, "proposed": true,
+}
+
+// Reasons a tag is suggested for
+const (
+    suggestedByKeyword  = "in title or abstract"
+    suggestedBySimilar  = "on similar items"
+    suggestedByNewWord  = "new, distinctive word"
+    suggestedByCooccurs = "often with "
+)
+
+// TagSuggestion is a tag proposed for an item with its score, higher
+// being more likely, and the main reason for it
+type TagSuggestion struct {
+    Tag    string  `json:"tag"`
+    Score  float64 `json:"score"`
+    Reason string  `json:"reason"`
+}
+
+// suggestWords returns the distinct significant words of text, folded
+func suggestWords(text string) map[string]bool {
+    words := make(map[string]bool)
+    for _, word := range strings.FieldsFunc(fold(text), func(r rune) bool {
+        return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+    }) {
+        if len([]rune(word)) >= 3 && !suggestStopWords[word] {
+            words[word] = true
+        }
+    }
+    return words
+}
+
+// itemWords returns the significant words of an item's title and abstract
+func itemWords(item *Item) map[string]bool {
+    return suggestWords(item.Title + " " + item.Abstract.String)
+}
+
+// SuggestTags proposes up to limit tags for the item: library tags whose
+// words appear in its title or abstract, tags that often go with the
+// tags it has, and tags of the items sharing most of its words. Frequent
+// words of the item no tag covers are proposed as new tags.
+func (c *CLI) SuggestTags(stableID string, limit int) ([]TagSuggestion, error) {
+    target, err := c.repo.GetByStableID(stableID)
+    if err != nil {
+        return nil, fmt.Errorf("getting item: %w", err)
+    }
+    items, err := c.repo.ListItems(Filter{})
+    if err != nil {
+        return nil, fmt.Errorf("listing items: %w", err)
+    }
+
+    has := make(map[string]bool)
+    for _, tag := range target.Tags {
+        has[fold(tag)] = true
+    }
+    words := itemWords(target)
+
+    // how many items use each tag and each word, and which tags go together
+    tagCount := make(map[string]int)
+    wordCount := make(map[string]int)
+    together := make(map[string]map[string]int)
+    type neighbor struct {
+        item       *Item
+        similarity float64
+    }
+    var neighbors []neighbor
+    for _, item := range items {
+        if item.StableID == target.StableID {
+            continue
+        }
+        for _, tag := range item.Tags {
+            tagCount[tag]++
+            if !has[fold(tag)] {
+                continue
+            }
+            for _, other := range item.Tags {
+                if together[tag] == nil {
+                    together[tag] = make(map[string]int)
+                }
+                together[tag][other]++
+            }
+        }
+        other := itemWords(item)
+        shared := 0
+        for word := range other {
+            wordCount[word]++
+            if words[word] {
+                shared++
+            }
+        }
+        if shared > 0 && len(item.Tags) > 0 {
+            similarity := float64(shared) / math.Sqrt(float64(len(words)*len(other)))
+            neighbors = append(neighbors, neighbor{item, similarity})
+        }
+    }
+
+    // variants of a tag differing in case count as one, named like the
+    // most used variant
+    scores := make(map[string]map[string]float64)
+    names := make(map[string]string)
+    add := func(tag, reason string, score float64) {
+        key := fold(tag)
+        if has[key] || score <= 0 {
+            return
+        }
+        if scores[key] == nil {
+            scores[key] = make(map[string]float64)
+        }
+        scores[key][reason] += score
+        if name, ok := names[key]; !ok || tagCount[tag] > tagCount[name] {
+            names[key] = tag
+        }
+    }
+
+    found := make(map[string]bool)
+    for tag := range tagCount {
+        tagWords := suggestWords(tag)
+        if len(tagWords) == 0 || found[fold(tag)] {
+            continue
+        }
+        inText := true
+        for word := range tagWords {
+            inText = inText && words[word]
+        }
+        if inText {
+            found[fold(tag)] = true
+            add(tag, suggestedByKeyword, 1)
+        }
+    }
+    for _, own := range target.Tags {
+        for tag, n := range together[own] {
+            add(tag, suggestedByCooccurs+own, float64(n)/float64(tagCount[own]))
+        }
+    }
+    slices.SortFunc(neighbors, func(a, b neighbor) int { return cmp.Compare(b.similarity, a.similarity) })
+    for _, n := range neighbors[:min(len(neighbors), 10)] {
+        for _, tag := range n.item.Tags {
+            add(tag, suggestedBySimilar, n.similarity)
+        }
+    }
+
+    suggestions := make([]TagSuggestion, 0, len(scores))
+    covered := make(map[string]bool)
+    for key, reasons := range scores {
+        suggestion := TagSuggestion{Tag: names[key]}
+        best := 0.0
+        for _, reason := range sortedKeys(reasons) {
+            suggestion.Score += reasons[reason]
+            if reasons[reason] > best {
+                best, suggestion.Reason = reasons[reason], reason
+            }
+        }
+        suggestions = append(suggestions, suggestion)
+        for word := range suggestWords(key) {
+            covered[word] = true
+        }
+    }
+
+    // the rarest words of the item in the library make new tags, weighed
+    // below any existing tag found in its text
+    rare := make([]string, 0, len(words))
+    for word := range words {
+        if !covered[word] && !has[word] && strings.ContainsFunc(word, unicode.IsLetter) {
+            rare = append(rare, word)
+        }
+    }
+    idf := func(word string) float64 { return math.Log(float64(len(items)) / float64(wordCount[word]+1)) }
+    slices.SortFunc(rare, func(a, b string) int { return cmp.Or(cmp.Compare(idf(b), idf(a)), strings.Compare(a, b)) })
+    for _, word := range rare[:min(len(rare), 3)] {
+        score := idf(word) / math.Log(float64(len(items))+1) / 2
+        suggestions = append(suggestions, TagSuggestion{Tag: word, Score: score, Reason: suggestedByNewWord})
+    }
+
+    slices.SortFunc(suggestions, func(a, b TagSuggestion) int {
+        return cmp.Or(cmp.Compare(b.Score, a.Score), strings.Compare(a.Tag, b.Tag))
+    })
+    for i := range suggestions {
+        suggestions[i].Score = math.Round(suggestions[i].Score*100) / 100
+    }
+    return suggestions[:min(len(suggestions), limit)], nil
+}
+
+// PrintTagSuggestions prints the tags suggested for the item as text or
+// JSON and, with apply, adds them to it through the Zotero client
+func (c *CLI) PrintTagSuggestions(stableID string, limit int, format string, apply bool) error {
+    suggestions, err := c.SuggestTags(stableID, limit)
+    if err != nil {
+        return err
+    }
+    if format == "json" {
+        if err := writeJSON(suggestions); err != nil {
+            return err
+        }
+    } else {
+        for _, suggestion := range suggestions {
+            fmt.Printf("%s\t%.2f\t%s\n", suggestion.Tag, suggestion.Score, suggestion.Reason)
+        }
+    }
+
+    if !apply || len(suggestions) == 0 {
+        return nil
+    }
+    tags := make([]string, len(suggestions))
+    for i, suggestion := range suggestions {
+        tags[i] = suggestion.Tag
+    }
+    if c.pretend("tag %s with %s", stableID, strings.Join(tags, ", ")) {
+        return nil
+    }
+    return c.TagItem(stableID, tags, false)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+    "fmt"
+    "os"
+    "strconv"
+    "strings"
+    "unicode/utf8"
+)
+
+// tagColorsQuery looks up the colors assigned to the tags of the given
+// items. Zotero keeps them per library as a JSON array in syncedSettings.
+const tagColorsQuery = `
+    SELECT itag.itemID, t.name, json_extract(c.value, '$.color')
+    FROM itemTags itag
+    JOIN tags t ON itag.tagID = t.tagID
+    JOIN items ci ON itag.itemID = ci.itemID
+    JOIN syncedSettings s ON s.setting = 'tagColors' AND s.libraryID = ci.libraryID
+    JOIN json_each(s.value) c ON json_extract(c.value, '$.name') = t.name
+    WHERE itag.itemID IN (SELECT value FROM json_each(?))`
+
+// coloredTagsCondition matches items carrying at least one colored tag
+const coloredTagsCondition = `EXISTS (
+            SELECT 1 FROM itemTags ctag
+            JOIN tags ct ON ctag.tagID = ct.tagID
+            JOIN syncedSettings cs ON cs.setting = 'tagColors' AND cs.libraryID = i.libraryID
+            JOIN json_each(cs.value) cc ON json_extract(cc.value, '$.name') = ct.name
+            WHERE ctag.itemID = i.itemID)`
+
+// TagColors retrieves the colors of the colored tags of the items with the
+// given IDs, keyed by item ID and tag name
+func (r *Repository) TagColors(itemIDs []int64) (map[int64]map[string]string, error) {
+    if !r.hasTable("syncedSettings") {
+        return map[int64]map[string]string{}, nil
+    }
+    rows, err := r.query(tagColorsQuery, idList(itemIDs))
+    if err != nil {
+        return nil, fmt.Errorf("executing query: %w", err)
+    }
+    defer rows.Close()
+
+    colors := make(map[int64]map[string]string)
+    for rows.Next() {
+        var itemID int64
+        var tag, color string
+        if err := rows.Scan(&itemID, &tag, &color); err != nil {
+            return nil, fmt.Errorf("scanning row: %w", err)
+        }
+        if colors[itemID] == nil {
+            colors[itemID] = make(map[string]string)
+        }
+        // keyed like the normalized tags of items
+        colors[itemID][normalizeTag(tag)] = color
+    }
+
+    if err = rows.Err(); err != nil {
+        return nil, fmt.Errorf("iterating rows: %w", err)
+    }
+
+    return colors, nil
+}
+
+// useColor reports whether output to stdout may contain ANSI colors
+func useColor() bool {
+    return os.Getenv("NO_COLOR") == "" && isTerminal(os.Stdout)
+}
+
+// ansiColor returns the escape sequence switching to a "#RRGGBB" color
+func ansiColor(hex string) (string, bool) {
+    hex = strings.TrimPrefix(hex, "#")
+    if len(hex) != 6 {
+        return "", false
+    }
+    rgb, err := strconv.ParseUint(hex, 16, 32)
+    if err != nil {
+        return "", false
+    }
+    return fmt.Sprintf("\x1b[38;2;%d;%d;%dm", rgb>>16, rgb>>8&0xff, rgb&0xff), true
+}
+
+// colorTagCell paints the colored tags within cell, the comma-separated
+// tags as printed. Only the first visible runes of cell show tag names,
+// the rest being an ellipsis or padding.
+func colorTagCell(cell string, visible int, tags []string, colors map[string]string) string {
+    starts := make(map[int]string)
+    ends := make(map[int]bool)
+    pos := 0
+    for _, tag := range tags {
+        end := min(pos+utf8.RuneCountInString(tag), visible)
+        if code, ok := ansiColor(colors[tag]); ok && pos < end {
+            starts[pos] = code
+            ends[end] = true
+        }
+        pos += utf8.RuneCountInString(tag) + 1
+    }
+    if len(starts) == 0 {
+        return cell
+    }
+
+    var b strings.Builder
+    i := 0
+    for _, r := range cell {
+        if code, ok := starts[i]; ok {
+            b.WriteString(code)
+        }
+        b.WriteRune(r)
+        i++
+        if ends[i] {
+            b.WriteString("\x1b[0m")
+        }
+    }
+    return b.String()
+}
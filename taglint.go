@@ -0,0 +1,86 @@
+package main
+
+import (
+    "fmt"
+    "slices"
+    "strconv"
+    "strings"
+)
+
+// normalizeTag trims a tag and collapses the whitespace inside it
+func normalizeTag(tag string) string {
+    return strings.Join(strings.Fields(tag), " ")
+}
+
+// tagKey is the same for the spellings of a tag that differ only in case,
+// diacritics or whitespace
+func tagKey(tag string) string {
+    return fold(normalizeTag(tag))
+}
+
+// normalizeTags returns an item's tags as output shows them: with
+// whitespace normalized and a single spelling of each, the first one
+func normalizeTags(tags []string) []string {
+    var normalized []string
+    seen := make(map[string]bool)
+    for _, tag := range tags {
+        key := tagKey(tag)
+        if key == "" || seen[key] {
+            continue
+        }
+        seen[key] = true
+        normalized = append(normalized, normalizeTag(tag))
+    }
+    return normalized
+}
+
+// displayTags returns the item's tags normalized for output; item.Tags
+// keeps them as stored, for the dump and for changing them
+func (item *Item) displayTags() []string {
+    return normalizeTags(item.Tags)
+}
+
+// showTag quotes tags with stray whitespace, which would not show
+// otherwise
+func showTag(tag string) string {
+    if tag != normalizeTag(tag) {
+        return strconv.Quote(tag)
+    }
+    return tag
+}
+
+// LintTags reports the tags of the library spelled inconsistently: the
+// same tag in several cases, diacritics or spacings, and tags with stray
+// whitespace, along with the items carrying more than one spelling. It
+// fails when there is anything to report; tag merge fixes all of it.
+func (c *CLI) LintTags() error {
+    groups, err := c.tagMergeGroups("")
+    if err != nil {
+        return err
+    }
+    if len(groups) == 0 {
+        fmt.Println("No inconsistent tags")
+        return nil
+    }
+
+    for _, group := range groups {
+        var names []string
+        var doubled []string
+        carriers := make(map[string]int)
+        for _, variant := range group {
+            names = append(names, fmt.Sprintf("%s (%d)", showTag(variant.Name), len(variant.Items)))
+            for _, item := range variant.Items {
+                if carriers[item.StableID]++; carriers[item.StableID] == 2 {
+                    doubled = append(doubled, item.StableID)
+                }
+            }
+        }
+        line := normalizeTag(group[0].Name) + "\t" + strings.Join(names, ", ")
+        if len(doubled) > 0 {
+            slices.Sort(doubled)
+            line += "\tseveral on " + strings.Join(doubled, ",")
+        }
+        fmt.Println(line)
+    }
+    return fmt.Errorf("%d tags spelled inconsistently, run tag merge to fix them", len(groups))
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+    "slices"
+    "testing"
+)
+
+func TestNormalizeTags(t *testing.T) {
+    tests := []struct {
+        tags, normalized []string
+    }{
+        {nil, nil},
+        {[]string{"alpha", "beta"}, []string{"alpha", "beta"}},
+        // the first spelling of a tag wins
+        {[]string{"ToRead ", "toread", "TOREAD"}, []string{"ToRead"}},
+        {[]string{"  machine \t learning", "Machine Learning"}, []string{"machine learning"}},
+        {[]string{"Résumé", "resume"}, []string{"Résumé"}},
+        {[]string{"", "   ", "kept"}, []string{"kept"}},
+    }
+    for _, test := range tests {
+        if normalized := normalizeTags(test.tags); !slices.Equal(normalized, test.normalized) {
+            t.Errorf("normalizeTags(%q) = %q, expected %q", test.tags, normalized, test.normalized)
+        }
+    }
+}
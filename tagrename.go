@@ -0,0 +1,172 @@
+package main
+
+import (
+    "cmp"
+    "fmt"
+    "slices"
+    "strings"
+)
+
+// tagVariant is one spelling of a tag along with the items carrying it
+type tagVariant struct {
+    Name  string
+    Items []*Item
+}
+
+// taggedItems groups the top-level items of the selected library, trash
+// excluded, under each tag they carry. Only items with a tag matching tag
+// are considered, ignoring case and diacritics; empty takes every item.
+// Tags are named as stored rather than normalized like item.Tags, since
+// edits have to name them exactly.
+func (c *CLI) taggedItems(tag string) (map[string][]*Item, error) {
+    items, err := c.repo.ListItems(Filter{
+        Tag:     tag,
+        Include: inclusion(includeStandaloneAttachments + "," + includeNotes),
+    })
+    if err != nil {
+        return nil, fmt.Errorf("listing items: %w", err)
+    }
+    ids := make([]int64, len(items))
+    for i, item := range items {
+        ids[i] = item.ID
+    }
+    stored, err := c.repo.Tags(ids)
+    if err != nil {
+        return nil, fmt.Errorf("fetching tags: %w", err)
+    }
+    byTag := make(map[string][]*Item)
+    for _, item := range items {
+        for _, name := range stored[item.ID] {
+            byTag[name] = append(byTag[name], item)
+        }
+    }
+    return byTag, nil
+}
+
+// retag replaces tag old with tag new on every item, through the Zotero
+// client or with remote through the Web API, printing each item done. It
+// stops at the first failure, leaving the remaining items untouched.
+func (c *CLI) retag(items []*Item, old, new string, remote bool) error {
+    for _, item := range items {
+        var err error
+        if remote {
+            _, err = c.remoteEditTags(item.StableID, []string{new}, []string{old})
+        } else {
+            _, err = c.editTags(item.StableID, []string{new}, []string{old})
+        }
+        if err != nil {
+            return fmt.Errorf("retagging %s: %w", item.StableID, err)
+        }
+        fmt.Printf("%s\t%s -> %s\n", item.StableID, old, new)
+    }
+    return nil
+}
+
+// printAffected lists the items a rename would touch
+func printAffected(items []*Item, old, new string) {
+    for _, item := range items {
+        fmt.Printf("%s\t%s\n", item.StableID, item.Title)
+    }
+    fmt.Printf("%d items would be retagged from %q to %q\n", len(items), old, new)
+}
+
+// RenameTag renames a tag on every item carrying it. With dryRun the
+// affected items are only listed.
+func (c *CLI) RenameTag(old, new string, dryRun, remote bool) error {
+    if old == new {
+        return fmt.Errorf("tag %q would be renamed to itself", old)
+    }
+    byTag, err := c.taggedItems(old)
+    if err != nil {
+        return err
+    }
+    items := byTag[old]
+    if len(items) == 0 {
+        return fmt.Errorf("tag %w: %s", errNotFound, old)
+    }
+    if dryRun {
+        printAffected(items, old, new)
+        return nil
+    }
+    return c.retag(items, old, new, remote)
+}
+
+// tagMergeGroups finds the tags spelled differently only in case,
+// diacritics or whitespace, and the tags with stray whitespace. Each group
+// starts with the variant kept, the one on the most items preferring
+// those spaced right, and is ordered by that variant.
+func (c *CLI) tagMergeGroups(tag string) ([][]tagVariant, error) {
+    byTag, err := c.taggedItems(tag)
+    if err != nil {
+        return nil, err
+    }
+    byFold := make(map[string][]tagVariant)
+    for name, items := range byTag {
+        if tag == "" || tagKey(name) == tagKey(tag) {
+            byFold[tagKey(name)] = append(byFold[tagKey(name)], tagVariant{Name: name, Items: items})
+        }
+    }
+
+    stray := func(v tagVariant) int {
+        if v.Name == normalizeTag(v.Name) {
+            return 0
+        }
+        return 1
+    }
+    var groups [][]tagVariant
+    for _, variants := range byFold {
+        if len(variants) < 2 && stray(variants[0]) == 0 {
+            continue
+        }
+        slices.SortFunc(variants, func(a, b tagVariant) int {
+            return cmp.Or(
+                cmp.Compare(stray(a), stray(b)),
+                cmp.Compare(len(b.Items), len(a.Items)),
+                strings.Compare(a.Name, b.Name))
+        })
+        groups = append(groups, variants)
+    }
+    slices.SortFunc(groups, func(a, b []tagVariant) int {
+        return strings.Compare(a[0].Name, b[0].Name)
+    })
+    return groups, nil
+}
+
+// MergeTags folds the spellings of tags differing only in case,
+// diacritics or whitespace, such as "Machine Learning" and "machine
+// learning", into the most used one, trimming stray whitespace. With tag
+// only its variants are merged; with dryRun the merges are only reported.
+func (c *CLI) MergeTags(tag string, dryRun, remote bool) error {
+    groups, err := c.tagMergeGroups(tag)
+    if err != nil {
+        return err
+    }
+    if len(groups) == 0 {
+        if dryRun {
+            fmt.Println("No tags to merge")
+        }
+        return nil
+    }
+
+    for _, group := range groups {
+        kept := normalizeTag(group[0].Name)
+        carrying := 0
+        if group[0].Name == kept {
+            carrying = len(group[0].Items)
+            group = group[1:]
+        }
+        if dryRun {
+            fmt.Printf("%s (%d)\n", kept, carrying)
+            for _, variant := range group {
+                fmt.Printf("  <- %s (%d)\n", showTag(variant.Name), len(variant.Items))
+            }
+            continue
+        }
+        for _, variant := range group {
+            if err := c.retag(variant.Items, variant.Name, kept, remote); err != nil {
+                return err
+            }
+        }
+    }
+    return nil
+}
@@ -0,0 +1,379 @@
+package main
+
+import (
+    "encoding/base64"
+    "errors"
+    "fmt"
+    "os"
+    "strings"
+    "unicode"
+
+    "golang.org/x/term"
+    "golang.org/x/text/width"
+)
+
+// tuiMode is what keystrokes currently edit in the TUI
+type tuiMode int
+
+const (
+    tuiBrowse tuiMode = iota
+    tuiSearch
+    tuiTag
+)
+
+// tuiHelp lists the key bindings in the status line
+const tuiHelp = "j/k move  / search  t tag  o open  b browser  y copy reference  esc clear  q quit"
+
+// tui holds the state of the library browser
+type tui struct {
+    cli   *CLI
+    items []*Item
+
+    // visible are the items passing the search and tag filters
+    visible []*Item
+    cursor  int
+    offset  int
+
+    mode    tuiMode
+    search  string
+    tag     string
+    message string
+
+    // annotations caches the annotations of items shown in the detail
+    // pane
+    annotations map[int64][]*Annotation
+}
+
+// cellWidth returns the number of terminal cells r occupies
+func cellWidth(r rune) int {
+    if unicode.Is(unicode.Mn, r) || unicode.IsControl(r) {
+        return 0
+    }
+    switch width.LookupRune(r).Kind() {
+    case width.EastAsianWide, width.EastAsianFullwidth:
+        return 2
+    }
+    return 1
+}
+
+// fitCells truncates s to n terminal cells and pads it with spaces to
+// exactly n
+func fitCells(s string, n int) string {
+    var b strings.Builder
+    used := 0
+    for _, r := range s {
+        if r == '\n' || r == '\t' {
+            r = ' '
+        }
+        w := cellWidth(r)
+        if used+w > n {
+            break
+        }
+        b.WriteRune(r)
+        used += w
+    }
+    return b.String() + strings.Repeat(" ", n-used)
+}
+
+// TUI browses the items matching filter in a full-screen terminal UI
+func (c *CLI) TUI(filter Filter) error {
+    if !isTerminal(os.Stdin) || !isTerminal(os.Stdout) {
+        return errors.New("tui needs an interactive terminal")
+    }
+    items, err := c.repo.ListItems(filter)
+    if err != nil {
+        return fmt.Errorf("listing items: %w", err)
+    }
+
+    fd := int(os.Stdin.Fd())
+    state, err := term.MakeRaw(fd)
+    if err != nil {
+        return fmt.Errorf("setting up terminal: %w", err)
+    }
+    defer term.Restore(fd, state)
+    // alternate screen and hidden cursor, undone in reverse on exit
+    fmt.Print("\x1b[?1049h\x1b[?25l")
+    defer fmt.Print("\x1b[?25h\x1b[?1049l")
+
+    ui := &tui{
+        cli:         c,
+        items:       items,
+        message:     tuiHelp,
+        annotations: make(map[int64][]*Annotation),
+    }
+    ui.applyFilters()
+
+    buf := make([]byte, 64)
+    for {
+        ui.render()
+        n, err := os.Stdin.Read(buf)
+        if err != nil {
+            return fmt.Errorf("reading keys: %w", err)
+        }
+        if ui.handle(string(buf[:n])) {
+            return nil
+        }
+    }
+}
+
+// matches reports whether every word of query occurs in text, ignoring
+// case and diacritics
+func matches(text, query string) bool {
+    text = fold(text)
+    for _, word := range strings.Fields(fold(query)) {
+        if !strings.Contains(text, word) {
+            return false
+        }
+    }
+    return true
+}
+
+// applyFilters recomputes the visible items after the search or tag
+// filter changed
+func (ui *tui) applyFilters() {
+    ui.visible = ui.visible[:0]
+    for _, item := range ui.items {
+        text := strings.Join([]string{item.StableID, item.Title, item.Year, item.Publication, item.CitationKey}, " ")
+        if !matches(text, ui.search) {
+            continue
+        }
+        if ui.tag != "" && !matches(strings.Join(item.displayTags(), " "), ui.tag) {
+            continue
+        }
+        ui.visible = append(ui.visible, item)
+    }
+    ui.cursor = min(ui.cursor, max(len(ui.visible)-1, 0))
+}
+
+// selected returns the item under the cursor, or nil when none is visible
+func (ui *tui) selected() *Item {
+    if len(ui.visible) == 0 {
+        return nil
+    }
+    return ui.visible[ui.cursor]
+}
+
+// move shifts the cursor by delta, staying within the visible items
+func (ui *tui) move(delta int) {
+    ui.cursor = max(0, min(ui.cursor+delta, len(ui.visible)-1))
+}
+
+// handle applies a keystroke and reports whether the TUI should quit
+func (ui *tui) handle(key string) bool {
+    if ui.mode != tuiBrowse {
+        ui.edit(key)
+        return false
+    }
+
+    _, height := ui.size()
+    page := max(height-3, 1)
+    switch key {
+    case "q", "\x03":
+        return true
+    case "j", "\x1b[B", "\x0e":
+        ui.move(1)
+    case "k", "\x1b[A", "\x10":
+        ui.move(-1)
+    case "\x04", "\x1b[6~", " ":
+        ui.move(page)
+    case "\x15", "\x1b[5~":
+        ui.move(-page)
+    case "g", "\x1b[H":
+        ui.cursor = 0
+    case "G", "\x1b[F":
+        ui.move(len(ui.visible))
+    case "/":
+        ui.mode = tuiSearch
+    case "t":
+        ui.mode = tuiTag
+    case "\x1b":
+        ui.search, ui.tag = "", ""
+        ui.applyFilters()
+        ui.message = tuiHelp
+    case "o", "\r":
+        ui.act("open", ui.cli.Open)
+    case "b":
+        ui.act("open url", ui.cli.OpenURL)
+    case "y":
+        ui.act("reference", ui.copyReference)
+    }
+    return false
+}
+
+// edit applies a keystroke to the search or tag filter being typed
+func (ui *tui) edit(key string) {
+    field := &ui.search
+    if ui.mode == tuiTag {
+        field = &ui.tag
+    }
+    switch key {
+    case "\r":
+        ui.mode = tuiBrowse
+        ui.message = tuiHelp
+        return
+    case "\x1b", "\x03":
+        *field = ""
+        ui.mode = tuiBrowse
+        ui.message = tuiHelp
+    case "\x7f", "\b":
+        runes := []rune(*field)
+        if len(runes) > 0 {
+            *field = string(runes[:len(runes)-1])
+        }
+    default:
+        if strings.HasPrefix(key, "\x1b") {
+            return
+        }
+        for _, r := range key {
+            if unicode.IsPrint(r) {
+                *field += string(r)
+            }
+        }
+    }
+    ui.applyFilters()
+}
+
+// act runs an action on the selected item, reporting failures in the
+// status line
+func (ui *tui) act(action string, fn func(string) error) {
+    item := ui.selected()
+    if item == nil {
+        return
+    }
+    if err := fn(item.StableID); err != nil {
+        ui.message = "Error: " + err.Error()
+        return
+    }
+    ui.cli.recordHistory(action, item.StableID)
+    if ui.message == "" || strings.HasPrefix(ui.message, "Error: ") {
+        ui.message = tuiHelp
+    }
+}
+
+// copyReference puts the Markdown reference of the item on the clipboard
+// through the terminal (OSC 52), which also works over SSH
+func (ui *tui) copyReference(stableID string) error {
+    ref, err := ui.cli.reference(stableID)
+    if err != nil {
+        return err
+    }
+    fmt.Printf("\x1b]52;c;%s\a", base64.StdEncoding.EncodeToString([]byte(ref)))
+    ui.message = "Copied reference to " + stableID
+    return nil
+}
+
+// size returns the terminal size, assuming 80x24 when it is unknown
+func (ui *tui) size() (int, int) {
+    w, h, err := term.GetSize(int(os.Stdout.Fd()))
+    if err != nil || w <= 0 || h <= 0 {
+        return 80, 24
+    }
+    return w, h
+}
+
+// details returns the lines of the detail pane for item
+func (ui *tui) details(item *Item, w int) []string {
+    if item == nil {
+        return nil
+    }
+    lines := wrapText(item.Title, w)
+    meta := []string{item.StableID}
+    for _, part := range []string{item.Year, item.Publication, item.CitationKey} {
+        if part != "" {
+            meta = append(meta, part)
+        }
+    }
+    lines = append(lines, strings.Join(meta, " · "))
+    if tags := item.displayTags(); len(tags) > 0 {
+        lines = append(lines, wrapText("Tags: "+strings.Join(tags, ", "), w)...)
+    }
+    if path := ui.cli.getStoragePath(item); path != "" {
+        lines = append(lines, path)
+    }
+    if item.Abstract.String != "" {
+        lines = append(lines, "")
+        lines = append(lines, wrapText(item.Abstract.String, w)...)
+    }
+
+    annotations, ok := ui.annotations[item.ID]
+    if !ok {
+        // a failure only costs the annotations, the rest stays usable
+        annotations, _ = ui.cli.repo.Annotations(item.ID)
+        ui.annotations[item.ID] = annotations
+    }
+    if len(annotations) > 0 {
+        lines = append(lines, "", "Annotations:")
+    }
+    for _, annotation := range annotations {
+        text := annotation.Text
+        if annotation.PageLabel != "" {
+            text += " (p. " + annotation.PageLabel + ")"
+        }
+        for _, line := range wrapText(text, w-2) {
+            lines = append(lines, "▌ "+line)
+        }
+        if annotation.Comment != "" {
+            for _, line := range wrapText(annotation.Comment, w-2) {
+                lines = append(lines, "  "+line)
+            }
+        }
+    }
+    return lines
+}
+
+// render draws the whole screen: header, list and detail panes and the
+// status line
+func (ui *tui) render() {
+    w, h := ui.size()
+    listWidth := max(min(w*2/5, 60), 20)
+    detailWidth := max(w-listWidth-3, 10)
+    rows := max(h-2, 1)
+
+    if ui.cursor < ui.offset {
+        ui.offset = ui.cursor
+    }
+    if ui.cursor >= ui.offset+rows {
+        ui.offset = ui.cursor - rows + 1
+    }
+
+    var b strings.Builder
+    b.WriteString("\x1b[H")
+    header := fmt.Sprintf(" zotero-fetch  %d/%d items", len(ui.visible), len(ui.items))
+    if ui.search != "" {
+        header += "  /" + ui.search
+    }
+    if ui.tag != "" {
+        header += "  #" + ui.tag
+    }
+    b.WriteString("\x1b[7m" + fitCells(header, w) + "\x1b[0m\r\n")
+
+    details := ui.details(ui.selected(), detailWidth)
+    for row := 0; row < rows; row++ {
+        entry := ""
+        i := ui.offset + row
+        if i < len(ui.visible) {
+            item := ui.visible[i]
+            entry = fitCells(" "+item.Title, listWidth)
+            if i == ui.cursor {
+                entry = "\x1b[7m" + entry + "\x1b[0m"
+            }
+        } else {
+            entry = fitCells("", listWidth)
+        }
+        detail := ""
+        if row < len(details) {
+            detail = details[row]
+        }
+        b.WriteString(entry + " │ " + fitCells(detail, detailWidth) + "\r\n")
+    }
+
+    status := ui.message
+    switch ui.mode {
+    case tuiSearch:
+        status = "/" + ui.search + "▏"
+    case tuiTag:
+        status = "tag: " + ui.tag + "▏"
+    }
+    b.WriteString(fitCells(status, w))
+    fmt.Print(b.String())
+}
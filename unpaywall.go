@@ -0,0 +1,87 @@
+package main
+
+import (
+    "fmt"
+    "log/slog"
+    "net/http"
+    "net/url"
+    "path/filepath"
+    "strings"
+)
+
+// unpaywallLocation is an open-access copy reported by Unpaywall
+type unpaywallLocation struct {
+    URL       string `json:"url"`
+    URLForPDF string `json:"url_for_pdf"`
+    HostType  string `json:"host_type"`
+    Version   string `json:"version"`
+    License   string `json:"license"`
+}
+
+// unpaywallRecord is the part of an Unpaywall DOI record used here
+type unpaywallRecord struct {
+    IsOA           bool               `json:"is_oa"`
+    BestOALocation *unpaywallLocation `json:"best_oa_location"`
+}
+
+// OpenAccessPDF asks Unpaywall for the best open-access copy of an item
+func (c *CLI) OpenAccessPDF(stableID string) (*unpaywallLocation, error) {
+    if c.cfg.Email == "" {
+        return nil, fmt.Errorf("%w: Unpaywall requires email to be set", errConfig)
+    }
+    item, err := c.repo.GetByStableID(stableID)
+    if err != nil {
+        return nil, fmt.Errorf("getting item: %w", err)
+    }
+    if att := pdfAttachment(item); att != nil && att.Exists() {
+        slog.Info("item already has a local PDF", "path", att.Path)
+    }
+    doi, err := c.repo.Field(item.ID, "DOI")
+    if err != nil {
+        return nil, err
+    }
+    if doi == "" {
+        return nil, fmt.Errorf("item %s has no DOI", stableID)
+    }
+
+    var record unpaywallRecord
+    u := fmt.Sprintf("%s/v2/%s?%s",
+        strings.TrimSuffix(c.cfg.UnpaywallURL, "/"),
+        normalizeDOI(doi),
+        url.Values{"email": {c.cfg.Email}}.Encode())
+    if err := c.getJSON(u, &record); err != nil {
+        return nil, fmt.Errorf("looking up DOI: %w", err)
+    }
+    location := record.BestOALocation
+    if !record.IsOA || location == nil || location.URLForPDF == "" {
+        return nil, fmt.Errorf("open access PDF %w for item: %s", errNotFound, stableID)
+    }
+    return location, nil
+}
+
+// OpenAccess prints the URL of the best open-access PDF of an item and,
+// when dir is set, downloads it there as <stableid>.pdf and prints its path
+func (c *CLI) OpenAccess(stableID, dir string) error {
+    location, err := c.OpenAccessPDF(stableID)
+    if err != nil {
+        return err
+    }
+    fmt.Printf("%s\t%s\t%s\n", location.URLForPDF, location.HostType, location.Version)
+    if dir == "" {
+        return nil
+    }
+
+    req, err := http.NewRequest(http.MethodGet, location.URLForPDF, nil)
+    if err != nil {
+        return fmt.Errorf("creating request: %w", err)
+    }
+    path := filepath.Join(dir, stableID+".pdf")
+    if c.pretend("download %s to %s", location.URLForPDF, path) {
+        return nil
+    }
+    if err := downloadFile(req, path); err != nil {
+        return err
+    }
+    fmt.Println(path)
+    return nil
+}
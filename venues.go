@@ -0,0 +1,89 @@
+package main
+
+import "fmt"
+
+// Venue is a journal, conference or proceedings with the number of items
+// published in it
+type Venue struct {
+    Name      string
+    ItemCount int
+}
+
+// publicationCondition matches items whose journal, conference or
+// proceedings name contains the text
+const publicationCondition = `EXISTS (
+            SELECT 1 FROM itemData pd
+            JOIN itemDataValues pv ON pd.valueID = pv.valueID
+            JOIN fields pf ON pd.fieldID = pf.fieldID
+            WHERE pd.itemID = i.itemID
+            AND pf.fieldName IN ('publicationTitle', 'conferenceName', 'proceedingsTitle', 'journalAbbreviation')
+            AND fold(pv.value) LIKE fold(?))`
+
+// venuesQuery names every item's venue, preferring the conference over the
+// proceedings it was published in
+const venuesQuery = `
+    SELECT
+        i.itemID,
+        COALESCE(
+            (SELECT v.value FROM itemData d
+                JOIN itemDataValues v ON d.valueID = v.valueID
+                JOIN fields f ON d.fieldID = f.fieldID
+                WHERE d.itemID = i.itemID AND f.fieldName = 'conferenceName'),
+            (SELECT v.value FROM itemData d
+                JOIN itemDataValues v ON d.valueID = v.valueID
+                JOIN fields f ON d.fieldID = f.fieldID
+                WHERE d.itemID = i.itemID AND f.fieldName = 'publicationTitle'),
+            (SELECT v.value FROM itemData d
+                JOIN itemDataValues v ON d.valueID = v.valueID
+                JOIN fields f ON d.fieldID = f.fieldID
+                WHERE d.itemID = i.itemID AND f.fieldName = 'proceedingsTitle')) as venue
+    FROM items i
+    JOIN itemTypes it ON i.itemTypeID = it.itemTypeID
+    WHERE it.display = 1`
+
+// ListVenues retrieves the distinct venues, ignoring case and diacritics,
+// ordered by the number of items published in them
+func (r *Repository) ListVenues() ([]*Venue, error) {
+    query := venuesQuery
+    conditions, args := r.scopeConditions()
+    for _, condition := range conditions {
+        query += " AND " + condition
+    }
+    query = `SELECT MIN(venue), COUNT(*) as items FROM (` + query + `)
+        WHERE venue IS NOT NULL AND venue != ''
+        GROUP BY fold(venue) ORDER BY items DESC, fold(venue)`
+
+    rows, err := r.query(query, args...)
+    if err != nil {
+        return nil, fmt.Errorf("executing query: %w", err)
+    }
+    defer rows.Close()
+
+    var venues []*Venue
+    for rows.Next() {
+        var venue Venue
+        if err := rows.Scan(&venue.Name, &venue.ItemCount); err != nil {
+            return nil, fmt.Errorf("scanning row: %w", err)
+        }
+        venues = append(venues, &venue)
+    }
+
+    if err = rows.Err(); err != nil {
+        return nil, fmt.Errorf("iterating rows: %w", err)
+    }
+
+    return venues, nil
+}
+
+// Venues prints the venues along with their item counts
+func (c *CLI) Venues() error {
+    venues, err := c.repo.ListVenues()
+    if err != nil {
+        return fmt.Errorf("listing venues: %w", err)
+    }
+
+    for _, venue := range venues {
+        fmt.Printf("%d\t%s\n", venue.ItemCount, venue.Name)
+    }
+    return nil
+}
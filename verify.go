@@ -0,0 +1,130 @@
+package main
+
+import (
+    "crypto/md5"
+    "encoding/hex"
+    "fmt"
+    "io"
+    "os"
+)
+
+// Verification outcomes for a stored attachment
+const (
+    verifyOK       = "OK"
+    verifyModified = "MODIFIED"
+    verifyMissing  = "MISSING"
+    verifyNoHash   = "NOHASH"
+)
+
+// fileMD5 returns the hex encoded MD5 digest of the file at path
+func fileMD5(path string) (string, error) {
+    f, err := os.Open(path)
+    if err != nil {
+        return "", err
+    }
+    defer f.Close()
+
+    h := md5.New()
+    if _, err := io.Copy(h, f); err != nil {
+        return "", err
+    }
+    return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// verifyAttachment compares the attachment's file with the hash Zotero
+// recorded when it was last synced
+func verifyAttachment(att *Attachment) (string, error) {
+    if !att.Exists() {
+        return verifyMissing, nil
+    }
+    if att.StorageHash == "" {
+        return verifyNoHash, nil
+    }
+
+    sum, err := fileMD5(att.Path)
+    if err != nil {
+        return "", fmt.Errorf("hashing %s: %w", att.Path, err)
+    }
+    if sum != att.StorageHash {
+        return verifyModified, nil
+    }
+    return verifyOK, nil
+}
+
+// verification is the outcome of checking one attachment
+type verification struct {
+    status string
+    err    error
+}
+
+// verifyItems checks every stored attachment of items with jobs files
+// hashed at once, printing one line per file in item order, and returns
+// the number of missing or modified files
+func (c *CLI) verifyItems(items []*Item, jobs int) (int, error) {
+    type check struct {
+        item *Item
+        att  *Attachment
+    }
+    var checks []check
+    for _, item := range items {
+        for _, att := range item.Attachments {
+            if att.Path != "" {
+                checks = append(checks, check{item, att})
+            }
+        }
+    }
+
+    results := parallel(len(checks), jobs, func(i int) verification {
+        status, err := verifyAttachment(checks[i].att)
+        return verification{status, err}
+    })
+
+    failures := 0
+    for i, ch := range checks {
+        result := <-results[i]
+        if result.err != nil {
+            return failures, result.err
+        }
+        if result.status == verifyMissing || result.status == verifyModified {
+            failures++
+        }
+        fmt.Printf("%-8s\t%-8s\t%-8s\t%s\n",
+            ch.item.StableID,
+            ch.att.StableID,
+            result.status,
+            ch.att.Path)
+    }
+    return failures, nil
+}
+
+// Verify checks the attachments of a single item against their recorded
+// MD5 hashes
+func (c *CLI) Verify(stableID string, jobs int) error {
+    item, err := c.repo.GetByStableID(stableID)
+    if err != nil {
+        return fmt.Errorf("getting item: %w", err)
+    }
+    return c.reportVerification([]*Item{item}, jobs)
+}
+
+// VerifyAll checks the attachments of all items matching filter
+func (c *CLI) VerifyAll(filter Filter, jobs int) error {
+    items, err := c.repo.ListItems(filter)
+    if err != nil {
+        return fmt.Errorf("listing items: %w", err)
+    }
+    return c.reportVerification(items, jobs)
+}
+
+// reportVerification verifies items and turns failures into an error so
+// that scripts can rely on the exit status
+func (c *CLI) reportVerification(items []*Item, jobs int) error {
+    failures, err := c.verifyItems(items, jobs)
+    if err != nil {
+        return err
+    }
+    if failures > 0 {
+        return fmt.Errorf("%d attachment(s) missing or modified", failures)
+    }
+    return nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import "fmt"
+
+// view selects one of Zotero's built-in item views
+type view string
+
+const (
+    viewTrash      view = "trash"
+    viewUnfiled    view = "unfiled"
+    viewDuplicates view = "duplicates"
+    viewRecent     view = "recent"
+)
+
+// recentViewDays is how far back the recent view reaches
+const recentViewDays = 30
+
+// notTrashedCondition leaves out items in the trash, as every view but
+// the trash itself does in Zotero
+const notTrashedCondition = "i.itemID NOT IN (SELECT itemID FROM deletedItems)"
+
+// duplicatesCondition matches items sharing their title, DOI or ISBN with
+// another regular item of the same library, ignoring case and diacritics
+const duplicatesCondition = `i.itemID IN (
+            SELECT itemID FROM (
+                SELECT d.itemID, COUNT(*) OVER (
+                    PARTITION BY x.libraryID, d.fieldID, fold(v.value)) AS copies
+                FROM itemData d
+                JOIN itemDataValues v ON d.valueID = v.valueID
+                JOIN fields f ON d.fieldID = f.fieldID
+                JOIN items x ON d.itemID = x.itemID
+                JOIN itemTypes xt ON x.itemTypeID = xt.itemTypeID
+                WHERE f.fieldName IN ('title', 'DOI', 'ISBN')
+                    AND xt.typeName NOT IN ('attachment', 'note', 'annotation')
+                    AND x.itemID NOT IN (SELECT itemID FROM deletedItems))
+            WHERE copies > 1)`
+
+// String implements flag.Value
+func (v *view) String() string {
+    return string(*v)
+}
+
+// Set implements flag.Value
+func (v *view) Set(value string) error {
+    switch view(value) {
+    case viewTrash, viewUnfiled, viewDuplicates, viewRecent:
+        *v = view(value)
+        return nil
+    default:
+        return fmt.Errorf("expected trash, unfiled, duplicates or recent")
+    }
+}
+
+// conditions returns the SQL conditions selecting the view's items
+func (v view) conditions() []string {
+    switch v {
+    case viewTrash:
+        return []string{"i.itemID IN (SELECT itemID FROM deletedItems)"}
+    case viewUnfiled:
+        return []string{
+            notTrashedCondition,
+            "NOT EXISTS (SELECT 1 FROM collectionItems ci WHERE ci.itemID = i.itemID)",
+        }
+    case viewDuplicates:
+        return []string{notTrashedCondition, duplicatesCondition}
+    case viewRecent:
+        return []string{
+            notTrashedCondition,
+            fmt.Sprintf("i.dateAdded >= datetime('now', '-%d days')", recentViewDays),
+        }
+    default:
+        return nil
+    }
+}
+
+// sortOrders are the orders -sort picks from, each falling back to the
+// order items were added in
+var sortOrders = map[string]string{
+    "title":    " ORDER BY fold(" + itemTitle + "), i.itemID",
+    "year":     " ORDER BY " + whereFields["year"].sql + ", i.itemID",
+    "added":    " ORDER BY i.dateAdded, i.itemID",
+    "modified": " ORDER BY i.dateModified DESC, i.itemID",
+    "cited":    citedOrder,
+}
+
+// order returns the ORDER BY clause the view lists its items in
+func (v view) order() string {
+    switch v {
+    case viewDuplicates:
+        // copies of an item end up next to each other
+        return " ORDER BY fold(" + itemTitle + "), i.key"
+    case viewRecent:
+        return " ORDER BY i.dateAdded DESC, i.key"
+    default:
+        // the order items were added in, which stays put between runs
+        // unlike SQLite's unspecified row order
+        return " ORDER BY i.itemID"
+    }
+}
@@ -0,0 +1,401 @@
+package main
+
+import (
+    "bytes"
+    "crypto/md5"
+    "encoding/json"
+    "fmt"
+    "io"
+    "log/slog"
+    "net/http"
+    "net/url"
+    "os"
+    "path/filepath"
+    "slices"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// apiItem is an item as returned by the Zotero Web API
+type apiItem struct {
+    Key     string                 `json:"key"`
+    Version int                    `json:"version"`
+    Data    map[string]interface{} `json:"data"`
+}
+
+// apiRequest sends a Web API request with a JSON body. A non-zero version
+// is sent as If-Unmodified-Since-Version so that the server rejects writes
+// racing with changes made elsewhere.
+func (c *CLI) apiRequest(method, path string, version int, body, result interface{}) error {
+    var reader io.Reader
+    if body != nil {
+        encoded, err := json.Marshal(body)
+        if err != nil {
+            return fmt.Errorf("encoding request: %w", err)
+        }
+        reader = bytes.NewReader(encoded)
+    }
+    req, err := http.NewRequest(method, strings.TrimSuffix(c.cfg.APIURL, "/")+path, reader)
+    if err != nil {
+        return fmt.Errorf("building request: %w", err)
+    }
+    if body != nil {
+        req.Header.Set("Content-Type", "application/json")
+    }
+    if version != 0 {
+        req.Header.Set("If-Unmodified-Since-Version", strconv.Itoa(version))
+    }
+    return c.apiDo(req, version, result)
+}
+
+// apiForm posts a form to the file endpoints of the Web API, which only
+// accept new files
+func (c *CLI) apiForm(path string, form url.Values, result interface{}) error {
+    req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(c.cfg.APIURL, "/")+path,
+        strings.NewReader(form.Encode()))
+    if err != nil {
+        return fmt.Errorf("building request: %w", err)
+    }
+    req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+    req.Header.Set("If-None-Match", "*")
+    return c.apiDo(req, 0, result)
+}
+
+// apiDo authenticates and sends a Web API request and decodes its JSON
+// response into result, if any
+func (c *CLI) apiDo(req *http.Request, version int, result interface{}) error {
+    if c.cfg.APIKey == "" {
+        return fmt.Errorf("%w: apiKey is not set", errConfig)
+    }
+    req.Header.Set("Zotero-API-Key", c.cfg.APIKey)
+    req.Header.Set("Zotero-API-Version", "3")
+    req.Header.Set("User-Agent", "zotero-fetch/"+c.cfg.Version)
+
+    start := time.Now()
+    resp, err := httpClient.Do(req)
+    if err != nil {
+        return fmt.Errorf("requesting %s: %w", req.URL, err)
+    }
+    defer resp.Body.Close()
+    slog.Debug("http request",
+        "method", req.Method,
+        "url", req.URL.String(),
+        "status", resp.StatusCode,
+        "duration", time.Since(start))
+
+    switch {
+    case resp.StatusCode == http.StatusPreconditionFailed:
+        return fmt.Errorf("%w: the item changed on the server since version %d, run again", errConflict, version)
+    case resp.StatusCode == http.StatusNotFound:
+        return fmt.Errorf("%s %w", req.URL, errNotFound)
+    case resp.StatusCode >= 300:
+        message, _ := io.ReadAll(resp.Body)
+        return fmt.Errorf("requesting %s: %s: %s", req.URL, resp.Status, bytes.TrimSpace(message))
+    }
+    if result == nil {
+        return nil
+    }
+    if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+        return fmt.Errorf("decoding response of %s: %w", req.URL, err)
+    }
+    return nil
+}
+
+const itemSyncQuery = `SELECT version, synced FROM items WHERE key = ?`
+
+// remoteItem fetches the server copy of an item. Items with local changes
+// that were not synced yet are refused, since editing them on the server
+// would leave the Zotero client with a conflict to resolve.
+func (c *CLI) remoteItem(stableID string) (*apiItem, string, error) {
+    if _, err := c.repo.GetByStableID(stableID); err != nil {
+        return nil, "", fmt.Errorf("getting item: %w", err)
+    }
+    var localVersion int
+    var synced bool
+    if err := c.repo.queryRow(itemSyncQuery, stableID).Scan(&localVersion, &synced); err != nil {
+        return nil, "", fmt.Errorf("fetching sync state: %w", err)
+    }
+    if !synced {
+        return nil, "", fmt.Errorf("%w: %s has local changes, sync Zotero first", errConflict, stableID)
+    }
+
+    prefix, err := c.repo.apiLibraryPrefix(stableID)
+    if err != nil {
+        return nil, "", err
+    }
+    var item apiItem
+    if err := c.apiRequest(http.MethodGet, "/"+prefix+"/items/"+stableID, 0, nil, &item); err != nil {
+        return nil, "", fmt.Errorf("fetching item: %w", err)
+    }
+    if item.Version < localVersion {
+        return nil, "", fmt.Errorf("%w: server version %d of %s is older than the local version %d",
+            errConflict, item.Version, stableID, localVersion)
+    }
+    return &item, prefix, nil
+}
+
+// patchRemoteItem applies the fields returned by change to the server copy
+// of an item, guarded by the version change looked at
+func (c *CLI) patchRemoteItem(stableID string, change func(data map[string]interface{}) (map[string]interface{}, error)) error {
+    item, prefix, err := c.remoteItem(stableID)
+    if err != nil {
+        return err
+    }
+    patch, err := change(item.Data)
+    if err != nil {
+        return err
+    }
+    return c.apiRequest(http.MethodPatch, "/"+prefix+"/items/"+stableID, item.Version, patch, nil)
+}
+
+// apiTags returns the tag entries of Web API item data keyed by name,
+// along with the names in their original order
+func apiTags(data map[string]interface{}) (map[string]interface{}, []string) {
+    entries := make(map[string]interface{})
+    var names []string
+    list, _ := data["tags"].([]interface{})
+    for _, entry := range list {
+        if tag, ok := entry.(map[string]interface{}); ok {
+            if name, ok := tag["tag"].(string); ok {
+                entries[name] = entry
+                names = append(names, name)
+            }
+        }
+    }
+    return entries, names
+}
+
+// remoteEditTags removes and then adds tags of an item through the Web
+// API, returning the resulting tags. Tags kept keep their type, so
+// automatic tags stay automatic.
+func (c *CLI) remoteEditTags(stableID string, add, remove []string) ([]string, error) {
+    var result []string
+    err := c.patchRemoteItem(stableID, func(data map[string]interface{}) (map[string]interface{}, error) {
+        entries, names := apiTags(data)
+        result = names
+        for _, tag := range remove {
+            if i := slices.Index(result, tag); i >= 0 {
+                result = slices.Delete(result, i, i+1)
+            }
+        }
+        for _, tag := range add {
+            if !slices.Contains(result, tag) {
+                result = append(result, tag)
+                entries[tag] = map[string]string{"tag": tag}
+            }
+        }
+        list := []interface{}{}
+        for _, tag := range result {
+            list = append(list, entries[tag])
+        }
+        return map[string]interface{}{"tags": list}, nil
+    })
+    if err != nil {
+        return nil, err
+    }
+    return result, nil
+}
+
+// RemoteTagItem adds or removes tags of an item through the Web API and
+// prints the resulting tags
+func (c *CLI) RemoteTagItem(stableID string, tags []string, remove bool) error {
+    add, drop := tags, []string(nil)
+    if remove {
+        add, drop = nil, tags
+    }
+    if c.pretendTags(stableID, add, drop) {
+        return nil
+    }
+    result, err := c.remoteEditTags(stableID, add, drop)
+    if err != nil {
+        return err
+    }
+    fmt.Println(strings.Join(result, ", "))
+    return nil
+}
+
+// RemoteAddToCollection files an item into a collection through the Web
+// API. With move the item leaves every other collection.
+func (c *CLI) RemoteAddToCollection(stableID, collectionName string, move bool) error {
+    collection, err := c.repo.findCollection(collectionName)
+    if err != nil {
+        return err
+    }
+    if c.pretendFiling(stableID, collection.Name, move) {
+        return nil
+    }
+    return c.patchRemoteItem(stableID, func(data map[string]interface{}) (map[string]interface{}, error) {
+        keys := []string{collection.Key}
+        if !move {
+            list, _ := data["collections"].([]interface{})
+            for _, entry := range list {
+                if key, ok := entry.(string); ok && key != collection.Key {
+                    keys = append(keys, key)
+                }
+            }
+        }
+        return map[string]interface{}{"collections": keys}, nil
+    })
+}
+
+// RemoteRemoveFromCollection takes an item out of a collection through
+// the Web API, leaving it in the library
+func (c *CLI) RemoteRemoveFromCollection(stableID, collectionName string) error {
+    collection, err := c.repo.findCollection(collectionName)
+    if err != nil {
+        return err
+    }
+    if c.pretend("remove %s from collection %s", stableID, collection.Name) {
+        return nil
+    }
+    return c.patchRemoteItem(stableID, func(data map[string]interface{}) (map[string]interface{}, error) {
+        keys := []string{}
+        list, _ := data["collections"].([]interface{})
+        for _, entry := range list {
+            if key, ok := entry.(string); ok && key != collection.Key {
+                keys = append(keys, key)
+            }
+        }
+        return map[string]interface{}{"collections": keys}, nil
+    })
+}
+
+// RemoteSetField sets a metadata field of an item through the Web API
+func (c *CLI) RemoteSetField(stableID, field, value string) error {
+    if c.pretend("set %s of %s to %q", field, stableID, value) {
+        return nil
+    }
+    return c.patchRemoteItem(stableID, func(data map[string]interface{}) (map[string]interface{}, error) {
+        if _, ok := data[field]; !ok {
+            return nil, fmt.Errorf("%s items have no %s field", data["itemType"], field)
+        }
+        return map[string]interface{}{field: value}, nil
+    })
+}
+
+// RemoteSetCitationKey stores a citation key in the extra field through
+// the Web API
+func (c *CLI) RemoteSetCitationKey(stableID, key string) error {
+    if c.pretend("set the citation key of %s to %s", stableID, key) {
+        return nil
+    }
+    return c.patchRemoteItem(stableID, func(data map[string]interface{}) (map[string]interface{}, error) {
+        extra, _ := data["extra"].(string)
+        return map[string]interface{}{"extra": setExtraField(extra, citationKeyField, key)}, nil
+    })
+}
+
+// apiWriteResult is the response of the Web API to creating items
+type apiWriteResult struct {
+    Successful map[string]struct {
+        Key string `json:"key"`
+    } `json:"successful"`
+    Failed map[string]struct {
+        Code    int    `json:"code"`
+        Message string `json:"message"`
+    } `json:"failed"`
+}
+
+// remoteCreateItem creates a single item through the Web API and returns
+// its key
+func (c *CLI) remoteCreateItem(prefix string, data map[string]interface{}) (string, error) {
+    var result apiWriteResult
+    if err := c.apiRequest(http.MethodPost, "/"+prefix+"/items", 0, []interface{}{data}, &result); err != nil {
+        return "", err
+    }
+    if failed, ok := result.Failed["0"]; ok {
+        return "", fmt.Errorf("server rejected the item: %d %s", failed.Code, failed.Message)
+    }
+    created, ok := result.Successful["0"]
+    if !ok {
+        return "", fmt.Errorf("server did not create the item")
+    }
+    return created.Key, nil
+}
+
+// remoteUploadFile uploads the file of an imported attachment following
+// the Web API's authorize, upload and register steps
+func (c *CLI) remoteUploadFile(prefix, key, path string) error {
+    content, err := os.ReadFile(path)
+    if err != nil {
+        return fmt.Errorf("reading %s: %w", path, err)
+    }
+    info, err := os.Stat(path)
+    if err != nil {
+        return fmt.Errorf("reading %s: %w", path, err)
+    }
+
+    var auth struct {
+        Exists      int    `json:"exists"`
+        URL         string `json:"url"`
+        ContentType string `json:"contentType"`
+        Prefix      string `json:"prefix"`
+        Suffix      string `json:"suffix"`
+        UploadKey   string `json:"uploadKey"`
+    }
+    filePath := "/" + prefix + "/items/" + key + "/file"
+    err = c.apiForm(filePath, url.Values{
+        "md5":      {fmt.Sprintf("%x", md5.Sum(content))},
+        "filename": {filepath.Base(path)},
+        "filesize": {strconv.Itoa(len(content))},
+        "mtime":    {strconv.FormatInt(info.ModTime().UnixMilli(), 10)},
+    }, &auth)
+    if err != nil {
+        return fmt.Errorf("authorizing upload: %w", err)
+    }
+    if auth.Exists == 1 {
+        return nil
+    }
+
+    body := append([]byte(auth.Prefix), content...)
+    body = append(body, auth.Suffix...)
+    req, err := http.NewRequest(http.MethodPost, auth.URL, bytes.NewReader(body))
+    if err != nil {
+        return fmt.Errorf("building request: %w", err)
+    }
+    req.Header.Set("Content-Type", auth.ContentType)
+    resp, err := httpClient.Do(req)
+    if err != nil {
+        return fmt.Errorf("uploading %s: %w", path, err)
+    }
+    resp.Body.Close()
+    if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+        return fmt.Errorf("uploading %s: %s", path, resp.Status)
+    }
+
+    if err := c.apiForm(filePath, url.Values{"upload": {auth.UploadKey}}, nil); err != nil {
+        return fmt.Errorf("registering upload: %w", err)
+    }
+    return nil
+}
+
+// remoteAddItem creates an item with the PDF at path attached through the
+// Web API, in the selected library or else the user library
+func (c *CLI) remoteAddItem(data map[string]interface{}, path string) (string, error) {
+    prefix, err := c.repo.apiLibraryPrefixOf(c.repo.libraryID)
+    if err != nil {
+        return "", err
+    }
+    key, err := c.remoteCreateItem(prefix, data)
+    if err != nil {
+        return "", err
+    }
+
+    name := filepath.Base(path)
+    attachmentKey, err := c.remoteCreateItem(prefix, map[string]interface{}{
+        "itemType":    "attachment",
+        "parentItem":  key,
+        "linkMode":    "imported_file",
+        "title":       name,
+        "filename":    name,
+        "contentType": "application/pdf",
+    })
+    if err != nil {
+        return "", fmt.Errorf("creating attachment: %w", err)
+    }
+    if err := c.remoteUploadFile(prefix, attachmentKey, path); err != nil {
+        return "", err
+    }
+    return key, nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+    "archive/zip"
+    "fmt"
+    "io"
+    "net/http"
+    "os"
+    "path/filepath"
+    "strings"
+)
+
+// WebDAVConfig holds the location of a Zotero WebDAV file store. URL is
+// the address entered in Zotero's preferences, without the trailing
+// "zotero" folder.
+type WebDAVConfig struct {
+    URL      string `json:"url"`
+    Username string `json:"username"`
+    Password string `json:"password"`
+}
+
+// webdavCacheDir returns the folder where downloaded zips are kept
+func webdavCacheDir() (string, error) {
+    dir, err := os.UserCacheDir()
+    if err != nil {
+        return "", fmt.Errorf("locating cache folder: %w", err)
+    }
+    return filepath.Join(dir, "zotero-fetch", "webdav"), nil
+}
+
+// fetchFromWebDAV downloads the attachment's zip from the WebDAV store,
+// unless it is already cached, and unpacks it into the storage folder
+func (c *CLI) fetchFromWebDAV(att *Attachment) error {
+    cacheDir, err := webdavCacheDir()
+    if err != nil {
+        return err
+    }
+    zipPath := filepath.Join(cacheDir, att.StableID+".zip")
+
+    if _, err := os.Stat(zipPath); err != nil {
+        url := strings.TrimSuffix(c.cfg.WebDAV.URL, "/") + "/zotero/" + att.StableID + ".zip"
+        req, err := http.NewRequest(http.MethodGet, url, nil)
+        if err != nil {
+            return fmt.Errorf("building request: %w", err)
+        }
+        if c.cfg.WebDAV.Username != "" {
+            req.SetBasicAuth(c.cfg.WebDAV.Username, c.cfg.WebDAV.Password)
+        }
+        req.Header.Set("User-Agent", "zotero-fetch/"+c.cfg.Version)
+
+        if err := downloadFile(req, zipPath); err != nil {
+            return err
+        }
+    }
+
+    if err := unzip(zipPath, filepath.Join(c.cfg.StoragePath, att.StableID)); err != nil {
+        // a corrupt zip must not stick around in the cache
+        os.Remove(zipPath)
+        return fmt.Errorf("unpacking %s: %w", zipPath, err)
+    }
+    return nil
+}
+
+// unzip extracts every file of the archive at src into dir
+func unzip(src, dir string) error {
+    r, err := zip.OpenReader(src)
+    if err != nil {
+        return err
+    }
+    defer r.Close()
+
+    for _, f := range r.File {
+        target := filepath.Join(dir, f.Name)
+        if !strings.HasPrefix(target, filepath.Clean(dir)+string(os.PathSeparator)) {
+            return fmt.Errorf("illegal path in archive: %s", f.Name)
+        }
+        if f.FileInfo().IsDir() {
+            continue
+        }
+        if err := extractFile(f, target); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// extractFile writes a single archive entry to target
+func extractFile(f *zip.File, target string) error {
+    if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+        return err
+    }
+
+    in, err := f.Open()
+    if err != nil {
+        return err
+    }
+    defer in.Close()
+
+    out, err := os.Create(target)
+    if err != nil {
+        return err
+    }
+    if _, err := io.Copy(out, in); err != nil {
+        out.Close()
+        return err
+    }
+    return out.Close()
+}
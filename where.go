@@ -0,0 +1,231 @@
+package main
+
+import (
+    "fmt"
+    "slices"
+    "strconv"
+    "strings"
+    "unicode"
+)
+
+// whereField is a property of items -where expressions compare, as the
+// SQL computing it for the item i
+type whereField struct {
+    sql     string
+    numeric bool
+}
+
+// fieldValue is the SQL of the first of the named fields set on the item
+func fieldValue(names ...string) string {
+    return `(SELECT wv.value FROM itemData wd
+            JOIN itemDataValues wv ON wd.valueID = wv.valueID
+            JOIN fields wf ON wd.fieldID = wf.fieldID
+            WHERE wd.itemID = i.itemID AND wf.fieldName IN ('` + strings.Join(names, "', '") + `')
+            LIMIT 1)`
+}
+
+// whereFields are the names -where expressions can compare
+var whereFields = map[string]whereField{
+    "title":       {sql: itemTitle},
+    "type":        {sql: "it.typeName"},
+    "year":        {sql: "CAST(substr(" + fieldValue("date") + ", 1, 4) AS INTEGER)", numeric: true},
+    "publication": {sql: fieldValue("publicationTitle", "proceedingsTitle", "bookTitle", "websiteTitle", "blogTitle")},
+    "doi":         {sql: fieldValue("DOI")},
+    "abstract":    {sql: fieldValue("abstractNote")},
+    "language":    {sql: fieldValue("language")},
+    "added":       {sql: "i.dateAdded"},
+    "modified":    {sql: "i.dateModified"},
+    "authors": {sql: `(SELECT COUNT(*) FROM itemCreators wc
+            JOIN creatorTypes wt ON wc.creatorTypeID = wt.creatorTypeID
+            WHERE wc.itemID = i.itemID AND wt.creatorType = 'author')`, numeric: true},
+    "creators":    {sql: "(SELECT COUNT(*) FROM itemCreators wc WHERE wc.itemID = i.itemID)", numeric: true},
+    "tags":        {sql: "(SELECT COUNT(*) FROM itemTags wg WHERE wg.itemID = i.itemID)", numeric: true},
+    "attachments": {sql: "(SELECT COUNT(*) FROM itemAttachments wa WHERE wa.parentItemID = i.itemID)", numeric: true},
+    "notes":       {sql: "(SELECT COUNT(*) FROM itemNotes wn WHERE wn.parentItemID = i.itemID)", numeric: true},
+}
+
+// whereOperators are the comparisons of -where expressions; ~ tests
+// whether text contains the value, ignoring case and diacritics
+var whereOperators = []string{"<=", ">=", "!=", "=", "<", ">", "~"}
+
+// whereParser turns a -where expression such as
+//
+//	authors > 5 and (year < 2015 or title ~ "survey")
+//
+// into an SQL condition, passing every value as an argument
+type whereParser struct {
+    tokens []string
+    pos    int
+    args   []interface{}
+}
+
+// whereTokens splits an expression into words, operators, parentheses and
+// quoted strings, which keep their quotes
+func whereTokens(expr string) ([]string, error) {
+    var tokens []string
+    for i := 0; i < len(expr); {
+        r := rune(expr[i])
+        switch {
+        case unicode.IsSpace(r):
+            i++
+        case r == '(' || r == ')':
+            tokens = append(tokens, string(r))
+            i++
+        case r == '"' || r == '\'':
+            end := strings.IndexRune(expr[i+1:], r)
+            if end < 0 {
+                return nil, fmt.Errorf("unterminated string in %q", expr)
+            }
+            tokens = append(tokens, expr[i:i+end+2])
+            i += end + 2
+        default:
+            op := ""
+            for _, candidate := range whereOperators {
+                if strings.HasPrefix(expr[i:], candidate) {
+                    op = candidate
+                    break
+                }
+            }
+            if op != "" {
+                tokens = append(tokens, op)
+                i += len(op)
+                continue
+            }
+            start := i
+            for i < len(expr) && !unicode.IsSpace(rune(expr[i])) && !strings.ContainsAny(expr[i:i+1], "()<>=!~\"'") {
+                i++
+            }
+            if i == start {
+                return nil, fmt.Errorf("unexpected %q in %q", expr[i:i+1], expr)
+            }
+            tokens = append(tokens, expr[start:i])
+        }
+    }
+    return tokens, nil
+}
+
+// whereCondition compiles a -where expression into an SQL condition on
+// the item i and its arguments
+func whereCondition(expr string) (string, []interface{}, error) {
+    tokens, err := whereTokens(expr)
+    if err != nil {
+        return "", nil, err
+    }
+    p := &whereParser{tokens: tokens}
+    condition, err := p.or()
+    if err != nil {
+        return "", nil, err
+    }
+    if p.pos < len(p.tokens) {
+        return "", nil, fmt.Errorf("unexpected %q in %q", p.tokens[p.pos], expr)
+    }
+    return condition, p.args, nil
+}
+
+// peek returns the next token, empty at the end
+func (p *whereParser) peek() string {
+    if p.pos < len(p.tokens) {
+        return p.tokens[p.pos]
+    }
+    return ""
+}
+
+// next consumes the next token
+func (p *whereParser) next() (string, error) {
+    token := p.peek()
+    if token == "" {
+        return "", fmt.Errorf("expression ends early")
+    }
+    p.pos++
+    return token, nil
+}
+
+func (p *whereParser) or() (string, error) {
+    left, err := p.and()
+    for err == nil && strings.EqualFold(p.peek(), "or") {
+        p.pos++
+        var right string
+        if right, err = p.and(); err == nil {
+            left = "(" + left + " OR " + right + ")"
+        }
+    }
+    return left, err
+}
+
+func (p *whereParser) and() (string, error) {
+    left, err := p.unary()
+    for err == nil && strings.EqualFold(p.peek(), "and") {
+        p.pos++
+        var right string
+        if right, err = p.unary(); err == nil {
+            left = "(" + left + " AND " + right + ")"
+        }
+    }
+    return left, err
+}
+
+func (p *whereParser) unary() (string, error) {
+    switch token := p.peek(); {
+    case strings.EqualFold(token, "not"):
+        p.pos++
+        operand, err := p.unary()
+        return "NOT (" + operand + ")", err
+    case token == "(":
+        p.pos++
+        inner, err := p.or()
+        if err != nil {
+            return "", err
+        }
+        if closing, _ := p.next(); closing != ")" {
+            return "", fmt.Errorf("missing closing parenthesis")
+        }
+        return inner, nil
+    }
+    return p.comparison()
+}
+
+// comparison compiles "field operator value"
+func (p *whereParser) comparison() (string, error) {
+    name, err := p.next()
+    if err != nil {
+        return "", err
+    }
+    field, ok := whereFields[strings.ToLower(name)]
+    if !ok {
+        return "", fmt.Errorf("unknown field %q, expected one of %s", name, strings.Join(sortedKeys(whereFields), ", "))
+    }
+    op, err := p.next()
+    if err != nil {
+        return "", err
+    }
+    if !slices.Contains(whereOperators, op) {
+        return "", fmt.Errorf("expected a comparison after %s, got %q", name, op)
+    }
+    value, err := p.next()
+    if err != nil {
+        return "", err
+    }
+    if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') {
+        value = value[1 : len(value)-1]
+    }
+
+    if field.numeric {
+        n, err := strconv.ParseFloat(value, 64)
+        if err != nil || op == "~" {
+            return "", fmt.Errorf("%s compares numbers, not %s %s", name, op, value)
+        }
+        p.args = append(p.args, n)
+        return field.sql + " " + op + " ?", nil
+    }
+    switch op {
+    case "~":
+        p.args = append(p.args, "%"+value+"%")
+        return "fold(COALESCE(" + field.sql + ", '')) LIKE fold(?)", nil
+    case "=", "!=":
+        p.args = append(p.args, value)
+        return "fold(COALESCE(" + field.sql + ", '')) " + op + " fold(?)", nil
+    default:
+        p.args = append(p.args, value)
+        return "COALESCE(" + field.sql + ", '') " + op + " ?", nil
+    }
+}
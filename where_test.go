@@ -0,0 +1,77 @@
+package main
+
+import (
+    "reflect"
+    "strings"
+    "testing"
+)
+
+func TestWhereCondition(t *testing.T) {
+    year, tags, authors := whereFields["year"].sql, whereFields["tags"].sql, whereFields["authors"].sql
+    tests := []struct {
+        expr      string
+        condition string
+        args      []interface{}
+    }{
+        {"year < 2015", year + " < ?", []interface{}{2015.0}},
+        {"YEAR>=2020", year + " >= ?", []interface{}{2020.0}},
+        // and binds tighter than or
+        {"tags > 1 or year < 2015 and authors > 5",
+            "(" + tags + " > ? OR (" + year + " < ? AND " + authors + " > ?))",
+            []interface{}{1.0, 2015.0, 5.0}},
+        {"(tags > 1 OR year < 2015) AND authors > 5",
+            "((" + tags + " > ? OR " + year + " < ?) AND " + authors + " > ?)",
+            []interface{}{1.0, 2015.0, 5.0}},
+        {"not tags = 0 and year != 2000",
+            "(NOT (" + tags + " = ?) AND " + year + " != ?)",
+            []interface{}{0.0, 2000.0}},
+        {"not (tags = 0 or year = 2000)",
+            "NOT ((" + tags + " = ? OR " + year + " = ?))",
+            []interface{}{0.0, 2000.0}},
+        {"type = note", "fold(COALESCE(it.typeName, '')) = fold(?)", []interface{}{"note"}},
+        {`doi ~ "10.1000/a b"`,
+            "fold(COALESCE(" + whereFields["doi"].sql + ", '')) LIKE fold(?)",
+            []interface{}{"%10.1000/a b%"}},
+        {`type != 'say "hi"'`, "fold(COALESCE(it.typeName, '')) != fold(?)", []interface{}{`say "hi"`}},
+        {"added >= '2024-01-01 00:00:00'", "COALESCE(i.dateAdded, '') >= ?", []interface{}{"2024-01-01 00:00:00"}},
+    }
+    for _, test := range tests {
+        condition, args, err := whereCondition(test.expr)
+        if err != nil {
+            t.Errorf("whereCondition(%q): %v", test.expr, err)
+            continue
+        }
+        if condition != test.condition {
+            t.Errorf("whereCondition(%q) = %q, expected %q", test.expr, condition, test.condition)
+        }
+        if !reflect.DeepEqual(args, test.args) {
+            t.Errorf("whereCondition(%q) args = %v, expected %v", test.expr, args, test.args)
+        }
+    }
+}
+
+func TestWhereConditionErrors(t *testing.T) {
+    tests := []struct {
+        expr string
+        err  string
+    }{
+        {"", "expression ends early"},
+        {"year <", "expression ends early"},
+        {"year < 2015 and", "expression ends early"},
+        {`title ~ "open`, "unterminated string"},
+        {"colour = red", `unknown field "colour"`},
+        {"year 2015", "expected a comparison after year"},
+        {"year ~ 2015", "year compares numbers"},
+        {"tags > many", "tags compares numbers"},
+        {"(year < 2015", "missing closing parenthesis"},
+        {"year < 2015)", `unexpected ")"`},
+        {"year < 2015 tags > 1", `unexpected "tags"`},
+        {"title ! x", `unexpected "!"`},
+    }
+    for _, test := range tests {
+        _, _, err := whereCondition(test.expr)
+        if err == nil || !strings.Contains(err.Error(), test.err) {
+            t.Errorf("whereCondition(%q) error = %v, expected %q", test.expr, err, test.err)
+        }
+    }
+}
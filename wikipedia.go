@@ -0,0 +1,128 @@
+package main
+
+import (
+    "fmt"
+    "strings"
+)
+
+// wikipediaTemplates maps Zotero item types to Wikipedia citation
+// templates
+var wikipediaTemplates = map[string]string{
+    "journalArticle":   "cite journal",
+    "magazineArticle":  "cite magazine",
+    "newspaperArticle": "cite news",
+    "book":             "cite book",
+    "bookSection":      "cite book",
+    "conferencePaper":  "cite conference",
+    "thesis":           "cite thesis",
+    "report":           "cite report",
+    "webpage":          "cite web",
+    "blogPost":         "cite web",
+    "preprint":         "cite arXiv",
+}
+
+// wikipediaFields maps Zotero fields to citation template parameters, in
+// output order
+var wikipediaFields = [][2]string{
+    {"title", "title"},
+    {"bookTitle", "title"},
+    {"publicationTitle", "journal"},
+    {"proceedingsTitle", "book-title"},
+    {"websiteTitle", "website"},
+    {"date", "date"},
+    {"volume", "volume"},
+    {"issue", "issue"},
+    {"pages", "pages"},
+    {"edition", "edition"},
+    {"publisher", "publisher"},
+    {"place", "location"},
+    {"university", "publisher"},
+    {"language", "language"},
+    {"DOI", "doi"},
+    {"ISBN", "isbn"},
+    {"ISSN", "issn"},
+    {"url", "url"},
+    {"accessDate", "access-date"},
+}
+
+// wikiEscaper keeps values from breaking out of the template
+var wikiEscaper = strings.NewReplacer("|", "{{!}}", "{{", "{ {", "}}", "} }")
+
+// wikipediaReference renders the item as a Wikipedia citation template
+func (c *CLI) wikipediaReference(stableID string) (string, error) {
+    item, err := c.repo.GetByStableID(stableID)
+    if err != nil {
+        return "", fmt.Errorf("getting item: %w", err)
+    }
+    dumped := c.repo.dumpItem(item)
+
+    template, ok := wikipediaTemplates[dumped.ItemType]
+    if !ok {
+        template = "citation"
+    }
+    var params []string
+    add := func(name, value string) {
+        if value != "" {
+            params = append(params, name+"="+wikiEscaper.Replace(value))
+        }
+    }
+
+    // numbered parameters in the order Wikipedia lists creators
+    counts := make(map[string]int)
+    for _, creator := range dumped.Creators {
+        prefix := ""
+        switch creator.CreatorType {
+        case "author":
+        case "editor":
+            prefix = "editor"
+        case "translator":
+            prefix = "translator"
+        default:
+            continue
+        }
+        counts[prefix]++
+        n := counts[prefix]
+        if creator.FirstName == "" {
+            add(fmt.Sprintf("%s%d", wikiParam(prefix, "author"), n), creator.LastName)
+            continue
+        }
+        add(fmt.Sprintf("%s%d", wikiParam(prefix, "last"), n), creator.LastName)
+        add(fmt.Sprintf("%s%d", wikiParam(prefix, "first"), n), creator.FirstName)
+    }
+
+    for _, mapping := range wikipediaFields {
+        name := mapping[1]
+        value := dumped.Fields[mapping[0]]
+        switch {
+        case mapping[0] == "title" && dumped.ItemType == "bookSection":
+            // the book section's own title is the chapter of the book
+            name = "chapter"
+        case mapping[0] == "publicationTitle" && template != "cite journal":
+            name = map[string]string{
+                "cite magazine": "magazine",
+                "cite news":     "newspaper",
+            }[template]
+            if name == "" {
+                name = "work"
+            }
+        }
+        add(name, value)
+    }
+    if template == "cite arXiv" {
+        add("eprint", extraValue(dumped.Fields["extra"], "arXiv"))
+    }
+
+    ref := "{{" + template + " |" + strings.Join(params, " |") + "}}"
+    if item.Retraction != nil {
+        ref += "{{Retracted}}"
+    }
+    return ref, nil
+}
+
+// wikiParam names a creator parameter, e.g. "last" or "editor-last"
+func wikiParam(prefix, name string) string {
+    if prefix == "" {
+        return name
+    }
+    return prefix + "-" + name
+}